@@ -1,19 +1,40 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/mascotmascot1/go-todo/internal/config"
 	"github.com/mascotmascot1/go-todo/internal/db"
+	"github.com/mascotmascot1/go-todo/internal/holidays"
 	"github.com/mascotmascot1/go-todo/internal/server"
 
+	// The timezone database is embedded, so that TODO_TIMEZONE works in minimal containers too.
+	_ "time/tzdata"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "modernc.org/sqlite"
 )
 
+const (
+	// trashPurgeInterval is how often the tasks kept in the trash for longer than the retention period are purged.
+	trashPurgeInterval = time.Hour
+	// changesPruneInterval is how often the changes that can no longer be undone are pruned.
+	changesPruneInterval = time.Hour
+)
+
 // Main is the entry point of the program. It sets up the programme's parameters,
-// initialises the database, sets up and runs the server.
+// initialises and migrates the database, loads the holiday calendar, starts purging the trash and pruning the changes,
+// sets up and runs the server.
+// With the -migrate-status flag, it only reports the schema version and the pending migrations of the database.
 func main() {
+	migrateStatus := flag.Bool("migrate-status", false, "report the database schema version and the pending migrations without applying them")
+	flag.Parse()
+
 	logger := log.New(os.Stdout, "[GO-TODO] ", log.LstdFlags)
 
 	// Setting up of the programme's parameters.
@@ -23,20 +44,112 @@ func main() {
 		return
 	}
 
+	// A dry run only reports what the migrations would do.
+	if *migrateStatus {
+		if err := reportSchemaStatus(cfg, logger); err != nil {
+			logger.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Initialising the database.
-	if err := db.Init(cfg.Server.DBFile); err != nil {
+	store, err := openStore(cfg)
+	if err != nil {
 		logger.Println(err)
 		return
 	}
 	defer func() {
-		if err := db.Close(); err != nil {
+		if err := store.Close(); err != nil {
 			logger.Println(err)
 		}
 	}()
 
-	srv := server.New(cfg, logger)
+	// Loading the holiday calendar.
+	cal, err := holidays.Load(cfg.Calendar.HolidaysFile)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	if cfg.Calendar.HolidaysFile != "" {
+		logger.Printf("Loaded %d holidays from %s\n", cal.Len(), cfg.Calendar.HolidaysFile)
+	}
+
+	if cfg.Trash.Retention > 0 {
+		go purgeTrash(store, cfg.Trash.Retention, logger)
+	}
+	go pruneChanges(store, cfg.Limits.UndoWindow, logger)
+
+	srv := server.New(cfg, store, cal, logger)
 	logger.Printf("Starting server on %s\n", srv.HTTP.Addr)
 	if err := srv.Run(); err != nil {
 		logger.Println(err)
 	}
 }
+
+// purgeTrash permanently deletes the tasks kept in the trash for longer than the retention period,
+// at start and then every trashPurgeInterval, until the program exits.
+// Each deleted task is recorded in the audit log with no actor, as no one made the request.
+func purgeTrash(store db.TaskStore, retention time.Duration, logger *log.Logger) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := store.PurgeTrash(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			logger.Println(err)
+		} else if len(purged) > 0 {
+			logger.Printf("Purged %d tasks from the trash\n", len(purged))
+		}
+		<-ticker.C
+	}
+}
+
+// pruneChanges deletes the changes of tasks made longer ago than the undo window, as they can no longer be undone,
+// at start and then every changesPruneInterval, until the program exits.
+func pruneChanges(store db.TaskStore, window time.Duration, logger *log.Logger) {
+	ticker := time.NewTicker(changesPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := store.PruneChanges(context.Background(), time.Now().Add(-window)); err != nil {
+			logger.Println(err)
+		}
+		<-ticker.C
+	}
+}
+
+// openStore opens the PostgreSQL database if its connection string is configured, and the SQLite database file otherwise.
+func openStore(cfg *config.Config) (db.TaskStore, error) {
+	if cfg.Server.DSN != "" {
+		return db.NewPostgresStore(context.Background(), cfg.Server.DSN)
+	}
+	return db.NewSQLiteStore(context.Background(), cfg.Server.DBFile)
+}
+
+// reportSchemaStatus logs the schema version of the configured database and the migrations pending for it.
+func reportSchemaStatus(cfg *config.Config, logger *log.Logger) error {
+	var (
+		name   = cfg.Server.DBFile
+		status *db.SchemaStatus
+		err    error
+	)
+	if cfg.Server.DSN != "" {
+		name = "PostgreSQL"
+		status, err = db.ReadPostgresSchemaStatus(context.Background(), cfg.Server.DSN)
+	} else {
+		status, err = db.ReadSchemaStatus(context.Background(), cfg.Server.DBFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Database %s: schema version %d, latest version %d\n", name, status.Version, status.Latest)
+	if status.Version > status.Latest {
+		return fmt.Errorf("%w: version %d, latest known %d", db.ErrSchemaTooNew, status.Version, status.Latest)
+	}
+	for _, m := range status.Pending {
+		logger.Printf("Pending migration %d: %s\n", m.Version, m.Description)
+	}
+	return nil
+}
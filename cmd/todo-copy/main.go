@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/mascotmascot1/go-todo/internal/db"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// Main is the entry point of the one-shot tool copying the tasks of an SQLite database file into PostgreSQL.
+// The source file is given with the -from flag or TODO_DBFILE, and the PostgreSQL connection string
+// with the -to flag or TODO_DSN. Both databases are migrated to the latest schema first, the same way
+// the server does at startup, and the copy keeps the ids of the tasks.
+// The target database must have neither tasks nor audit entries.
+func main() {
+	logger := log.New(os.Stdout, "[GO-TODO-COPY] ", log.LstdFlags)
+
+	from := flag.String("from", envOr("TODO_DBFILE", "scheduler.db"), "path to the SQLite database file to copy")
+	to := flag.String("to", os.Getenv("TODO_DSN"), "PostgreSQL connection string to copy the tasks to")
+	flag.Parse()
+
+	if *to == "" {
+		logger.Println("the PostgreSQL connection string is required, set it with -to or TODO_DSN")
+		os.Exit(2)
+	}
+	if _, err := os.Stat(*from); err != nil {
+		logger.Printf("error checking database file '%s': %v\n", *from, err)
+		os.Exit(1)
+	}
+
+	if err := run(context.Background(), *from, *to, logger); err != nil {
+		logger.Println(err)
+		os.Exit(1)
+	}
+}
+
+// run opens both databases and copies the tasks.
+func run(ctx context.Context, from, to string, logger *log.Logger) error {
+	source, err := db.NewSQLiteStore(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := db.NewPostgresStore(ctx, to)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	copied, err := db.CopyTasks(ctx, source, target)
+	if err != nil {
+		return err
+	}
+	logger.Printf("Copied %d tasks from %s to PostgreSQL\n", copied, from)
+	return nil
+}
+
+// envOr returns the value of the environment variable, or the given default if it's empty.
+func envOr(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
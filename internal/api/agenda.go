@@ -0,0 +1,176 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mascotmascot1/go-todo/internal/db"
+	"github.com/mascotmascot1/go-todo/internal/holidays"
+)
+
+const (
+	defaultAgendaDays    = 7
+	maxAgendaDays        = 92
+	maxAgendaOccurrences = 1000
+)
+
+type agendaDay struct {
+	Date  string          `json:"date"`
+	Tasks []describedTask `json:"tasks"`
+}
+
+type agendaResponse struct {
+	Days []agendaDay `json:"days"`
+}
+
+// agendaHandler returns the tasks from one date to another, both included, grouped by day.
+// The dates are given by the 'from' and 'to' parameters in "YYYYMMDD" format; 'from' defaults to today
+// in the timezone of the request, and 'to' to a week from 'from'. The range can't be longer than maxAgendaDays.
+// A repeating task appears on every day it falls on within the range, see expandTask.
+// If any of the parameters is invalid, it will return an error with 400 status code.
+// The response will be in JSON format and will contain the list of days under the key "days", each one with
+// its date under "date" and its tasks, ordered by time, under "tasks". Every day of the range is listed,
+// even without tasks. Each repeating task comes with the description of its repeat rule.
+func (h *Handlers) agendaHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "agendaHandler"
+
+	now, err := h.requestNow(r)
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	from, to, err := agendaRange(r.FormValue("from"), r.FormValue("to"), now)
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	fromStr, toStr := from.Format(db.DateLayoutDB), to.Format(db.DateLayoutDB)
+	tasks, err := h.store.TasksUntil(r.Context(), toStr)
+	if err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+
+	lang := requestLang(r)
+	days := make([]agendaDay, 0, daysBetween(from, to)+1)
+	index := make(map[string]int, cap(days))
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		index[day.Format(db.DateLayoutDB)] = len(days)
+		days = append(days, agendaDay{Date: day.Format(db.DateLayoutDB), Tasks: []describedTask{}})
+	}
+
+	for _, task := range tasks {
+		list, err := expandTask(task, fromStr, toStr, now.Location(), h.holidays)
+		if err != nil {
+			// A task with a broken rule mustn't hide the rest of the agenda.
+			h.logger.Printf("%s: failed to expand task '%s': %v\n", caller, task.ID, err)
+			continue
+		}
+		for _, occurrence := range list {
+			i := index[occurrence.Date]
+			days[i].Tasks = append(days[i].Tasks, h.describeTask(occurrence, lang))
+		}
+	}
+
+	for _, day := range days {
+		// Whole day tasks come first, as their time is empty.
+		slices.SortStableFunc(day.Tasks, func(a, b describedTask) int {
+			return strings.Compare(a.Time, b.Time)
+		})
+	}
+	h.writeJSON(w, agendaResponse{Days: days}, http.StatusOK)
+}
+
+// agendaRange parses the dates of the agenda, applying the defaults described in agendaHandler.
+func agendaRange(fromStr, toStr string, now time.Time) (time.Time, time.Time, error) {
+	from := midnight(now)
+	if fromStr != "" {
+		var err error
+		if from, err = time.Parse(db.DateLayoutDB, fromStr); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid 'from' date '%s'", fromStr)
+		}
+	}
+	to := from.AddDate(0, 0, defaultAgendaDays-1)
+	if toStr != "" {
+		var err error
+		if to, err = time.Parse(db.DateLayoutDB, toStr); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid 'to' date '%s'", toStr)
+		}
+	}
+
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("'to' mustn't be before 'from'")
+	}
+	if daysBetween(from, to) >= maxAgendaDays {
+		return time.Time{}, time.Time{}, fmt.Errorf("the range can't be longer than %d days", maxAgendaDays)
+	}
+	return from, to, nil
+}
+
+// expandTask returns the occurrences of the task from one date to another in "YYYYMMDD" format, both included:
+// copies of the task with the date and time of each occurrence. A task that doesn't repeat occurs on its date.
+// A repeating task occurs on its date and then on the dates it would get if it were marked as done again and again,
+// so the occurrences follow its until date, remaining count, exceptions and working day option like advanceTask does.
+// Intraday occurrences are computed in the given timezone. At most maxAgendaOccurrences occurrences are returned.
+func expandTask(task *db.Task, from, to string, loc *time.Location, cal *holidays.Calendar) ([]*db.Task, error) {
+	if task.Repeat == "" {
+		if task.Date >= from && task.Date <= to {
+			return []*db.Task{task}, nil
+		}
+		return nil, nil
+	}
+
+	fromDate, err := time.Parse(db.DateLayoutDB, from)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the date '%s': %w", from, err)
+	}
+	toDate, err := time.Parse(db.DateLayoutDB, to)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the date '%s': %w", to, err)
+	}
+	// A task moved to the nearest working day may fall up to maxWorkdayShift days away from its rule's date.
+	skipUntil, end := fromDate, to
+	if task.Workday {
+		skipUntil = fromDate.AddDate(0, 0, -maxWorkdayShift)
+		end = toDate.AddDate(0, 0, maxWorkdayShift).Format(db.DateLayoutDB)
+	}
+
+	current := *task
+	current.Exceptions = slices.Clone(task.Exceptions)
+	next := true
+	// The occurrences before the range are skipped at once, as if the task were done just before it.
+	if current.Date < skipUntil.Format(db.DateLayoutDB) {
+		next, err = advanceTask(&current, inZone(skipUntil.Add(-time.Minute), loc), cal)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var list []*db.Task
+	for next && nominalDate(&current) <= end && len(list) < maxAgendaOccurrences {
+		if current.Date >= from && current.Date <= to {
+			occurrence := *task
+			occurrence.Date, occurrence.Time = current.Date, current.Time
+			list = append(list, &occurrence)
+		}
+
+		at, err := parseDateTime(current.Date, current.Time)
+		if err != nil {
+			return nil, err
+		}
+		date, tm := current.Date, current.Time
+		if next, err = advanceTask(&current, inZone(at, loc), cal); err != nil {
+			return nil, err
+		}
+		if next && current.Date == date && current.Time == tm {
+			return nil, fmt.Errorf("the repeat rule doesn't move the task from %s", date)
+		}
+	}
+	return list, nil
+}
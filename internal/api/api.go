@@ -7,19 +7,34 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/mascotmascot1/go-todo/internal/config"
 	"github.com/mascotmascot1/go-todo/internal/db"
+	"github.com/mascotmascot1/go-todo/internal/holidays"
 
 	"github.com/go-chi/chi/v5"
 )
 
+const (
+	maxExceptions      = 100
+	maxTags            = 20
+	maxTagLength       = 32
+	priorityNone       = "none"
+	defaultOccurrences = 10
+	timezoneParam      = "tz"
+	timezoneHeader     = "X-Timezone"
+)
+
 type Handlers struct {
-	logger *log.Logger
-	limits *config.Limits
-	auth   *config.Auth
+	store    db.TaskStore
+	logger   *log.Logger
+	limits   *config.Limits
+	auth     *config.Auth
+	holidays *holidays.Calendar
+	location *time.Location
 }
 
 type response struct {
@@ -28,23 +43,59 @@ type response struct {
 	Token string `json:"token,omitempty"`
 }
 
+// describedTask is a task together with the human-readable description of its repeat rule.
+type describedTask struct {
+	*db.Task
+	Description string `json:"description,omitempty"`
+}
+
 type tasksResponse struct {
-	Tasks []*db.Task `json:"tasks"`
+	Tasks      []describedTask `json:"tasks"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	Total      int             `json:"total"`
+}
+
+type purgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+type occurrencesResponse struct {
+	Dates []string `json:"dates"`
+}
+
+// addTaskRequest is a task to add, optionally given as a quick-add text, see ParseTask.
+type addTaskRequest struct {
+	db.Task
+	Text string `json:"text,omitempty"`
+}
+
+type parseRequest struct {
+	Text string `json:"text"`
 }
 
-// NewHandlers creates new Handlers instance with given limits, auth and logger.
+// NewHandlers creates new Handlers instance with given task store, limits, auth, holiday calendar, default timezone and logger.
 // It's used as a helper function to create handlers with required dependencies.
-func NewHandlers(limits *config.Limits, auth *config.Auth, logger *log.Logger) *Handlers {
+// A nil holiday calendar means that only weekends are days off, and a nil timezone means the server's local one.
+func NewHandlers(store db.TaskStore, limits *config.Limits, auth *config.Auth, cal *holidays.Calendar,
+	loc *time.Location, logger *log.Logger) *Handlers {
+	if loc == nil {
+		loc = time.Local
+	}
 	return &Handlers{
-		logger: logger,
-		limits: limits,
-		auth:   auth,
+		store:    store,
+		logger:   logger,
+		limits:   limits,
+		auth:     auth,
+		holidays: cal,
+		location: loc,
 	}
 }
 
 // Init initializes handlers with given router and handlers instance.
 // It sets up logging and size limit middlewares, then defines routes for
-// signin, nextdate, tasks, task, update, delete and task done handlers.
+// signin, nextdate, occurrences, tasks, agenda, task, update, delete, trash, purge, task restore, history,
+// history stats, changes, undo, audit, tags, tag rename, tag merge, lists, list, list add, list update, list delete,
+// task move, task parse, task done and task skip handlers.
 // All routes inside the group are protected with authentication middleware.
 func Init(r chi.Router, h *Handlers) {
 	r.Use(h.withLogging)
@@ -52,15 +103,36 @@ func Init(r chi.Router, h *Handlers) {
 
 	r.Post("/api/signin", h.signInHandler)
 	r.Get("/api/nextdate", h.nextDateHandler)
+	r.Get("/api/occurrences", h.occurrencesHandler)
 
 	r.Group(func(r chi.Router) {
 		r.Use(h.withAuth)
 		r.Get("/api/tasks", h.tasksHandler)
+		r.Get("/api/agenda", h.agendaHandler)
 		r.Post("/api/task", h.addTaskHandler)
 		r.Get("/api/task", h.taskHandler)
 		r.Put("/api/task", h.updateHandler)
 		r.Delete("/api/task", h.deleteTask)
+		r.Get("/api/trash", h.trashHandler)
+		r.Delete("/api/trash", h.purgeTrashHandler)
+		r.Post("/api/task/restore", h.restoreTaskHandler)
+		r.Get("/api/history", h.historyHandler)
+		r.Get("/api/history/stats", h.historyStatsHandler)
+		r.Get("/api/undo", h.changesHandler)
+		r.Post("/api/undo", h.undoHandler)
+		r.Get("/api/audit", h.auditHandler)
+		r.Get("/api/tags", h.tagsHandler)
+		r.Post("/api/tags/rename", h.renameTagHandler)
+		r.Post("/api/tags/merge", h.mergeTagsHandler)
+		r.Get("/api/lists", h.listsHandler)
+		r.Get("/api/list", h.listHandler)
+		r.Post("/api/list", h.addListHandler)
+		r.Put("/api/list", h.updateListHandler)
+		r.Delete("/api/list", h.deleteListHandler)
+		r.Post("/api/tasks/move", h.moveTasksHandler)
+		r.Post("/api/task/parse", h.parseTaskHandler)
 		r.Post("/api/task/done", h.taskDoneHandler)
+		r.Post("/api/task/skip", h.taskSkipHandler)
 	})
 }
 
@@ -83,39 +155,117 @@ func (h *Handlers) withSizeLimit(next http.Handler) http.Handler {
 	})
 }
 
-// tasksHandler returns a list of tasks based on the given search string.
-// It will return tasks that match the search string in either title or comment.
-// If the search string is empty, it will return all tasks up to the limit set in the configuration.
-// The response will be in JSON format and will contain a list of tasks under the key "tasks".
+// tasksHandler returns a page of tasks based on the given search query, see db.TaskFilter.
+// Overdue tasks are the ones dated before today in the timezone of the request, and an invalid query
+// is reported with 400 status code.
+// The optional parameters are:
+//
+// - limit   — the number of tasks on the page, up to the limit set in the configuration, which is the default
+// - sort    — the order of the tasks: date, title, id, created or priority, the highest first and then by date
+// - order   — asc (the default) or desc
+// - cursor  — the next_cursor of the previous page, given with the same search, sort and order
+// - tag     — a tag the tasks must have; it can be given several times, and the tasks must have all of them
+// - list_id — the id of the list of the tasks
+//
+// Without the sort parameter, the tasks are ordered by date, except that the overdue tasks of high and urgent priority
+// come first.
+// If the search query is empty, it will return all tasks.
+// The response will be in JSON format and will contain a list of tasks under the key "tasks",
+// the cursor of the next page under "next_cursor", absent on the last page, and the number of tasks
+// on all pages under "total".
+// Each repeating task comes with the description of its repeat rule in the language picked by requestLang.
 func (h *Handlers) tasksHandler(w http.ResponseWriter, r *http.Request) {
-	search := r.FormValue("search")
+	caller := "tasksHandler"
+
+	now, err := h.requestNow(r)
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	filter := db.TaskFilter{
+		Limit:  h.limits.TasksLimit,
+		Search: r.FormValue("search"),
+		Today:  now.Format(db.DateLayoutDB),
+		Sort:   r.FormValue("sort"),
+		Cursor: r.FormValue("cursor"),
+		Tags:   r.URL.Query()["tag"],
+		ListID: r.FormValue("list_id"),
+	}
+
+	if limitStr := r.FormValue("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			h.logger.Printf("%s: invalid 'limit' parameter '%s'\n", caller, limitStr)
+			h.writeJSON(w, response{Error: "'limit' must be a positive number"}, http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(limit, h.limits.TasksLimit)
+	}
+	if filter.Sort != "" && !db.IsSort(filter.Sort) {
+		h.logger.Printf("%s: invalid 'sort' parameter '%s'\n", caller, filter.Sort)
+		h.writeJSON(w, response{Error: "'sort' must be one of date, title, id, created and priority"}, http.StatusBadRequest)
+		return
+	}
+	switch order := r.FormValue("order"); order {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		h.logger.Printf("%s: invalid 'order' parameter '%s'\n", caller, order)
+		h.writeJSON(w, response{Error: "'order' must be asc or desc"}, http.StatusBadRequest)
+		return
+	}
 
-	tasks, err := db.Tasks(h.limits.TasksLimit, search)
+	page, err := h.store.Tasks(r.Context(), filter)
 	if err != nil {
-		h.failWithTaskError(w, "tasksHandler", err)
+		h.failWithTaskError(w, caller, err)
 		return
 	}
-	h.writeJSON(w, tasksResponse{Tasks: tasks}, http.StatusOK)
+
+	lang := requestLang(r)
+	described := make([]describedTask, 0, len(page.Tasks))
+	for _, task := range page.Tasks {
+		described = append(described, h.describeTask(task, lang))
+	}
+	h.writeJSON(w, tasksResponse{Tasks: described, NextCursor: page.NextCursor, Total: page.Total}, http.StatusOK)
 }
 
 // taskHandler returns a single task based on the given id.
 // If the task doesn't exist, it will return an error with 404 status code.
 // The response will be in JSON format and will contain the task under the key "task".
+// A repeating task comes with the description of its repeat rule in the language picked by requestLang.
 func (h *Handlers) taskHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.FormValue("id")
-	task, err := db.GetTask(id)
+	task, err := h.store.GetTask(r.Context(), id)
 	if err != nil {
 		h.failWithTaskError(w, "taskHandler", err)
 		return
 	}
 
-	h.writeJSON(w, task, http.StatusOK)
+	h.writeJSON(w, h.describeTask(task, requestLang(r)), http.StatusOK)
+}
+
+// describeTask attaches the description of the task's repeat rule in the given language.
+// A rule that can't be described is logged and left without a description, so the task is still returned.
+func (h *Handlers) describeTask(task *db.Task, lang string) describedTask {
+	if task.Repeat == "" {
+		return describedTask{Task: task}
+	}
+
+	description, err := DescribeRepeat(task.Repeat, lang)
+	if err != nil {
+		h.logger.Printf("failed to describe the repeat rule of task '%s': %v\n", task.ID, err)
+	}
+	return describedTask{Task: task, Description: description}
 }
 
 // updateHandler updates the task with the given id.
-// The request body must contain the task in JSON format.
+// The request body must contain the id of the task and the fields to change in JSON format.
+// The body is applied to the stored task, so the fields it omits keep their values.
 // If the task doesn't exist, it will return an error with 404 status code.
-// If the task exists, it will update the task and return an empty response with 200 status code.
+// If the task exists, it will update the task, record the update in the audit log and return an empty response
+// with 200 status code.
 // If the request body is invalid, it will return an error with 400 status code.
 // If the request body is too large, it will return an error with 413 status code.
 func (h *Handlers) updateHandler(w http.ResponseWriter, r *http.Request) {
@@ -128,19 +278,34 @@ func (h *Handlers) updateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var task db.Task
-	if err := json.Unmarshal(content, &task); err != nil {
+	var ref struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(content, &ref); err != nil {
 		h.logger.Printf("%s: json marshal error: %v\n", caller, err)
 		h.writeJSON(w, response{Error: fmt.Sprintf("JSON deserialization failed: %v", err)}, http.StatusBadRequest)
 		return
 	}
-	if err := validateTask(&task); err != nil {
-		h.logger.Printf("%s: validation failed: %v\n", caller, err)
+	now, err := h.requestNow(r)
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
 		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	if err := db.UpdateTask(&task); err != nil {
+	var invalid error
+	_, err = h.store.UpdateTask(r.Context(), ref.ID, func(task *db.Task) error {
+		if invalid = decodeTaskUpdate(content, task); invalid == nil {
+			invalid = validateTask(task, now, h.holidays)
+		}
+		return invalid
+	})
+	if invalid != nil {
+		h.logger.Printf("%s: validation failed: %v\n", caller, invalid)
+		h.writeJSON(w, response{Error: invalid.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
 		h.failWithTaskError(w, caller, err)
 		return
 	}
@@ -148,39 +313,114 @@ func (h *Handlers) updateHandler(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, struct{}{}, http.StatusOK)
 }
 
-// taskDoneHandler marks the task with the given id as done.
+// decodeTaskUpdate applies the JSON body of an update request to the stored task. The fields the body omits
+// keep their values, except for a task left without a repeat rule: its end conditions, exceptions and working day
+// option only make sense with a rule, so the ones the body doesn't give are cleared.
+func decodeTaskUpdate(content []byte, task *db.Task) error {
+	if err := json.Unmarshal(content, task); err != nil {
+		return fmt.Errorf("JSON deserialization failed: %w", err)
+	}
+	if task.Repeat != "" {
+		return nil
+	}
+
+	var given db.Task
+	if err := json.Unmarshal(content, &given); err != nil {
+		return fmt.Errorf("JSON deserialization failed: %w", err)
+	}
+	task.Until, task.Remaining, task.Exceptions, task.Workday = given.Until, given.Remaining, given.Exceptions, given.Workday
+	return nil
+}
+
+// taskDoneHandler marks the task with the given id as done, recording the completion in the history and the audit log.
 // If the task doesn't exist, it will return an error with 404 status code.
 // If the task exists, it will update the task date based on its repeat field.
-// If the task doesn't have a repeat field, it will delete the task instead.
+// If the task doesn't have a repeat field, or its series is over, it will move the task to the trash instead.
+// The series is over when the repeat rule has no further occurrences, the next date is after the task's until date
+// or no occurrences remain.
 // If the request body is invalid, it will return an error with 400 status code.
 // If the request body is too large, it will return an error with 413 status code.
 func (h *Handlers) taskDoneHandler(w http.ResponseWriter, r *http.Request) {
 	caller := "taskDoneHandler"
 
 	id := r.FormValue("id")
-	task, err := db.GetTask(id)
+	task, err := h.store.GetTask(r.Context(), id)
+	if err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+
+	now, err := h.requestNow(r)
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	done := &db.Completion{TaskID: task.ID, Title: task.Title, Date: task.Date, Time: task.Time}
+	next, err := advanceTask(task, now, h.holidays)
+	if err != nil {
+		h.logger.Printf("%s: failed to compute the new date: %v\n", caller, err)
+		h.writeJSON(w, response{Error: fmt.Sprintf("failed to compute the new date: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	// A one-off task, as well as a task whose series is over, is done for good, so it goes to the trash.
+	if !next {
+		task = nil
+	}
+	if err := h.store.CompleteTask(r.Context(), done, task); err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+
+	h.writeJSON(w, struct{}{}, http.StatusOK)
+}
+
+// taskSkipHandler moves the repeating task with the given id to its following occurrence
+// without marking it as done.
+// If the task doesn't exist, it will return an error with 404 status code.
+// If the task doesn't have a repeat field, it will return an error with 400 status code.
+// If the task's series is over after the skipped occurrence, it will delete the task.
+// Otherwise, it will update the task date and return an empty response with 200 status code.
+// Either way, the change is recorded in the audit log.
+func (h *Handlers) taskSkipHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "taskSkipHandler"
+
+	id := r.FormValue("id")
+	task, err := h.store.GetTask(r.Context(), id)
 	if err != nil {
 		h.failWithTaskError(w, caller, err)
 		return
 	}
 
 	if task.Repeat == "" {
-		if err := db.DeleteTask(id); err != nil {
-			h.failWithTaskError(w, caller, err)
-			return
-		}
-		h.writeJSON(w, struct{}{}, http.StatusOK)
+		h.logger.Printf("%s: task '%s' doesn't repeat\n", caller, id)
+		h.writeJSON(w, response{Error: "only repeating tasks can be skipped"}, http.StatusBadRequest)
 		return
 	}
 
-	nextDate, err := NextDate(time.Now(), task.Date, task.Repeat)
+	now, err := h.requestNow(r)
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	next, err := advanceTask(task, now, h.holidays)
 	if err != nil {
 		h.logger.Printf("%s: failed to compute the new date: %v\n", caller, err)
 		h.writeJSON(w, response{Error: fmt.Sprintf("failed to compute the new date: %v", err)}, http.StatusBadRequest)
 		return
 	}
 
-	if err := db.UpdateDate(id, nextDate); err != nil {
+	if !next {
+		err = h.store.DeleteTask(r.Context(), id)
+	} else {
+		_, err = h.store.UpdateTask(r.Context(), id, func(stored *db.Task) error {
+			*stored = *task
+			return nil
+		})
+	}
+	if err != nil {
 		h.failWithTaskError(w, caller, err)
 		return
 	}
@@ -188,27 +428,92 @@ func (h *Handlers) taskDoneHandler(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, struct{}{}, http.StatusOK)
 }
 
-// deleteTask deletes a task with the given id.
+// deleteTask moves a task with the given id to the trash.
 // If the task doesn't exist, it will return an error with 404 status code.
-// If the task exists, it will delete the task and return an empty response with 200 status code.
+// If the task exists, it will move the task to the trash, record the deletion in the audit log and return
+// an empty response with 200 status code.
 // If the request body is invalid, it will return an error with 400 status code.
 // If the request body is too large, it will return an error with 413 status code.
 func (h *Handlers) deleteTask(w http.ResponseWriter, r *http.Request) {
+	caller := "deleteTask"
+
+	id := r.FormValue("id")
+	if err := h.store.DeleteTask(r.Context(), id); err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+
+	h.writeJSON(w, struct{}{}, http.StatusOK)
+}
+
+// trashHandler returns the tasks in the trash, the most recently deleted first.
+// The response will be in JSON format and will contain a list of tasks under the key "tasks",
+// each with the time it was deleted under "deleted".
+func (h *Handlers) trashHandler(w http.ResponseWriter, r *http.Request) {
+	tasks, err := h.store.TrashedTasks(r.Context())
+	if err != nil {
+		h.failWithTaskError(w, "trashHandler", err)
+		return
+	}
+
+	lang := requestLang(r)
+	described := make([]describedTask, 0, len(tasks))
+	for _, task := range tasks {
+		described = append(described, h.describeTask(task, lang))
+	}
+	h.writeJSON(w, tasksResponse{Tasks: described, Total: len(described)}, http.StatusOK)
+}
+
+// restoreTaskHandler takes the task with the given id out of the trash and records it in the audit log.
+// If the task isn't in the trash, it will return an error with 404 status code.
+// Otherwise, it will return an empty response with 200 status code.
+func (h *Handlers) restoreTaskHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "restoreTaskHandler"
+
 	id := r.FormValue("id")
-	if err := db.DeleteTask(id); err != nil {
-		h.failWithTaskError(w, "deleteTask", err)
+	if err := h.store.RestoreTask(r.Context(), id); err != nil {
+		h.failWithTaskError(w, caller, err)
 		return
 	}
 
 	h.writeJSON(w, struct{}{}, http.StatusOK)
 }
 
+// purgeTrashHandler permanently deletes the task with the given id from the trash,
+// or empties the whole trash if no id is given, recording each deleted task in the audit log.
+// If the task isn't in the trash, it will return an error with 404 status code.
+// The response will be in JSON format and will contain the number of the tasks deleted under the key "purged".
+func (h *Handlers) purgeTrashHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "purgeTrashHandler"
+
+	var (
+		purged []*db.Task
+		err    error
+	)
+	if id := r.FormValue("id"); id != "" {
+		var task *db.Task
+		if task, err = h.store.PurgeTask(r.Context(), id); err == nil {
+			purged = []*db.Task{task}
+		}
+	} else {
+		purged, err = h.store.PurgeTrash(r.Context(), time.Time{})
+	}
+	if err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+
+	h.writeJSON(w, purgeResponse{Purged: len(purged)}, http.StatusOK)
+}
+
 // addTaskHandler adds a new task to the database.
 // The request body must contain the task in JSON format.
 // If the request body is invalid, it will return an error with 400 status code.
 // If the request body is too large, it will return an error with 413 status code.
 // If the task exists, it will return an error with 409 status code.
-// If the task doesn't exist, it will add the task and return an empty response with 200 status code.
+// If the task doesn't exist, it will add the task, record it in the audit log and return its id with 200 status code.
+// Instead of the title, date, time and repeat fields, the task can be given as a quick-add text under the key "text";
+// the fields given explicitly take precedence over the ones parsed from the text.
 func (h *Handlers) addTaskHandler(w http.ResponseWriter, r *http.Request) {
 	caller := "addTaskHandler"
 
@@ -219,19 +524,36 @@ func (h *Handlers) addTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var task db.Task
-	if err := json.Unmarshal(content, &task); err != nil {
+	var req addTaskRequest
+	if err := json.Unmarshal(content, &req); err != nil {
 		h.logger.Printf("%s: json marshal error: %v\n", caller, err)
 		h.writeJSON(w, response{Error: fmt.Sprintf("JSON deserialization failed: %v", err)}, http.StatusBadRequest)
 		return
 	}
-	if err := validateTask(&task); err != nil {
+	now, err := h.requestNow(r)
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	task := req.Task
+	if req.Text != "" {
+		parsed, err := ParseTask(req.Text, now, h.holidays)
+		if err != nil {
+			h.logger.Printf("%s: failed to parse the text: %v\n", caller, err)
+			h.writeJSON(w, response{Error: fmt.Sprintf("failed to parse the text: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		mergeParsed(&task, parsed)
+	}
+	if err := validateTask(&task, now, h.holidays); err != nil {
 		h.logger.Printf("%s: validation failed: %v\n", caller, err)
 		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	id, err := db.AddTask(&task)
+	id, err := h.store.AddTask(r.Context(), &task)
 	if err != nil {
 		h.failWithTaskError(w, caller, err)
 		return
@@ -239,45 +561,105 @@ func (h *Handlers) addTaskHandler(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, response{ID: strconv.FormatInt(id, 10)}, http.StatusOK)
 }
 
+// parseTaskHandler previews the task parsed from a quick-add text, see ParseTask.
+// The request body must contain the text in JSON format under the key "text".
+// The optional 'now' query parameter is the current date, in the same format as for the nextdate endpoint.
+// The task is validated the same way addTaskHandler does, but it isn't saved.
+// If the request body or the text is invalid, it will return an error with 400 status code.
+// The response will be in JSON format and will contain the task the text stands for,
+// with the description of its repeat rule in the language picked by requestLang.
+func (h *Handlers) parseTaskHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "parseTaskHandler"
+
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.Printf("%s: failed to read body: %v\n", caller, err)
+		h.writeJSON(w, response{Error: "failed to read request body"}, http.StatusBadRequest)
+		return
+	}
+
+	var req parseRequest
+	if err := json.Unmarshal(content, &req); err != nil {
+		h.logger.Printf("%s: json marshal error: %v\n", caller, err)
+		h.writeJSON(w, response{Error: fmt.Sprintf("JSON deserialization failed: %v", err)}, http.StatusBadRequest)
+		return
+	}
+	loc, err := h.requestLocation(r)
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	now, err := parseNow(r.URL.Query().Get("now"), loc)
+	if err != nil {
+		h.logger.Printf("%s: invalid 'now' parameter: %v\n", caller, err)
+		h.writeJSON(w, response{Error: fmt.Sprintf("invalid 'now' parameter: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	task, err := ParseTask(req.Text, now, h.holidays)
+	if err != nil {
+		h.logger.Printf("%s: failed to parse the text: %v\n", caller, err)
+		h.writeJSON(w, response{Error: fmt.Sprintf("failed to parse the text: %v", err)}, http.StatusBadRequest)
+		return
+	}
+	if err := validateTask(task, now, h.holidays); err != nil {
+		h.logger.Printf("%s: validation failed: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	h.writeJSON(w, h.describeTask(task, requestLang(r)), http.StatusOK)
+}
+
 // nextDateHandler returns the next date given a date and repeat rule.
 // The request query must contain the following parameters:
 // date: the date in the format "YYYY-MM-DD"
-// repeat: the repeat rule in the format "d <number>|y <number>|w <number>,<number>,..."
-// now: the current date in the format "YYYY-MM-DD", optional
+// repeat: the repeat rule in the format "d <number>|y <number>|w <number>,<number>,..." or an iCalendar RRULE
+// now: the current date in the format "YYYY-MM-DD" or "YYYY-MM-DD HH:MM", optional
+// time: the time of day of the task in the format "HH:MM", optional
 // If the 'now' parameter is not provided, the current date will be used.
-// If the 'now' parameter is invalid, it will return an error with 400 status code.
+// If the 'now' or 'time' parameters are invalid, it will return an error with 400 status code.
 // If the 'date' or 'repeat' parameters are invalid, it will return an error with 400 status code.
 // If the server failed to compute the next date, it will return an error with 400 status code.
 // The response will be in plain text format and will contain the next date in the format "YYYY-MM-DD".
+// If the time is given or the repeat rule is an intraday one, the time of day follows the date: "YYYY-MM-DD HH:MM".
 func (h *Handlers) nextDateHandler(w http.ResponseWriter, r *http.Request) {
 	caller := "nextDayHandler"
 
 	date := r.FormValue("date")
 	repeat := r.FormValue("repeat")
-	nowStr := r.FormValue("now")
+	tm := r.FormValue("time")
 
-	var (
-		now time.Time
-		err error
-	)
-
-	if nowStr == "" {
-		now = time.Now()
-	} else {
-		now, err = time.Parse(db.DateLayoutDB, nowStr)
-		if err != nil {
-			h.logger.Printf("%s: invalid 'now' parameter: %v\n", caller, err)
-			http.Error(w, fmt.Sprintf("invalid 'now' parameter: %v", err), http.StatusBadRequest)
+	loc, err := h.requestLocation(r)
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now, err := parseNow(r.FormValue("now"), loc)
+	if err != nil {
+		h.logger.Printf("%s: invalid 'now' parameter: %v\n", caller, err)
+		http.Error(w, fmt.Sprintf("invalid 'now' parameter: %v", err), http.StatusBadRequest)
+		return
+	}
+	if tm != "" {
+		if _, err := time.Parse(db.TimeLayout, tm); err != nil {
+			h.logger.Printf("%s: invalid 'time' parameter: %v\n", caller, err)
+			http.Error(w, fmt.Sprintf("invalid 'time' parameter: %v", err), http.StatusBadRequest)
 			return
 		}
 	}
 
-	newDate, err := NextDate(now, date, repeat)
+	newDate, newTime, err := NextDateTime(now, date, tm, repeat, h.holidays)
 	if err != nil {
 		h.logger.Printf("%s: failed to compute the new date: %v\n", caller, err)
 		http.Error(w, fmt.Sprintf("failed to compute the new date: %v", err), http.StatusBadRequest)
 		return
 	}
+	if newTime != "" {
+		newDate += " " + newTime
+	}
 
 	w.Header().Add("Content-Type", "text/plain; charset=UTF-8")
 	if _, err := w.Write([]byte(newDate)); err != nil {
@@ -285,24 +667,124 @@ func (h *Handlers) nextDateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// occurrencesHandler returns the upcoming dates of a task with the given date and repeat rule.
+// The request query must contain the following parameters:
+// date: the date in the format "YYYY-MM-DD"
+// repeat: the repeat rule, in any of the formats accepted by the nextdate endpoint
+// The following parameters are optional:
+// count: the number of dates to return, 10 by default and at most the occurrences limit set in the configuration
+// until: the date in the format "YYYY-MM-DD" after which the task doesn't repeat
+// time: the time of day of the task in the format "HH:MM"
+// now: the current date in the format "YYYY-MM-DD" or "YYYY-MM-DD HH:MM", the current date is used if it's not provided
+// The dates are the ones the task would get if it were saved and then marked as done again and again:
+// a date in the past is moved to the first occurrence after now, and each following date is computed from the previous one.
+// If any of the parameters is invalid, it will return an error with 400 status code.
+// The response will be in JSON format and will contain the list of dates under the key "dates".
+func (h *Handlers) occurrencesHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "occurrencesHandler"
+
+	loc, err := h.requestLocation(r)
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	now, err := parseNow(r.FormValue("now"), loc)
+	if err != nil {
+		h.logger.Printf("%s: invalid 'now' parameter: %v\n", caller, err)
+		h.writeJSON(w, response{Error: fmt.Sprintf("invalid 'now' parameter: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	count := defaultOccurrences
+	if countStr := r.FormValue("count"); countStr != "" {
+		count, err = strconv.Atoi(countStr)
+		if err != nil || count <= 0 {
+			h.logger.Printf("%s: invalid 'count' parameter '%s'\n", caller, countStr)
+			h.writeJSON(w, response{Error: "'count' must be a positive number"}, http.StatusBadRequest)
+			return
+		}
+	}
+	count = min(count, h.limits.OccurrencesLimit)
+
+	dates, err := occurrences(now, r.FormValue("date"), r.FormValue("time"), r.FormValue("repeat"), r.FormValue("until"),
+		count, h.holidays)
+	if err != nil {
+		h.logger.Printf("%s: failed to compute the dates: %v\n", caller, err)
+		h.writeJSON(w, response{Error: fmt.Sprintf("failed to compute the dates: %v", err)}, http.StatusBadRequest)
+		return
+	}
+	h.writeJSON(w, occurrencesResponse{Dates: dates}, http.StatusOK)
+}
+
+// parseNow parses the 'now' query parameter, given either as a date or as a date and a time of day in the given timezone.
+// An empty value stands for the current time.
+func parseNow(value string, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Now().In(loc), nil
+	}
+	if now, err := time.ParseInLocation(db.DateTimeLayout, value, loc); err == nil {
+		return now, nil
+	}
+	return time.ParseInLocation(db.DateLayoutDB, value, loc)
+}
+
+// requestLocation returns the timezone of the given request: an IANA name such as "Europe/Moscow"
+// from the "tz" query parameter or the X-Timezone header, or the default timezone of the server.
+// It returns an error if the timezone is unknown.
+func (h *Handlers) requestLocation(r *http.Request) (*time.Location, error) {
+	name := r.URL.Query().Get(timezoneParam)
+	if name == "" {
+		name = r.Header.Get(timezoneHeader)
+	}
+	if name == "" {
+		return h.location, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone '%s'", name)
+	}
+	return loc, nil
+}
+
+// requestNow returns the current time in the timezone of the given request, see requestLocation.
+func (h *Handlers) requestNow(r *http.Request) (time.Time, error) {
+	loc, err := h.requestLocation(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().In(loc), nil
+}
+
 // failWithTaskError writes an error to the writer with the given status code and message.
 // It also logs the error with the given caller string.
-// If the error is db.ErrEmptyID, it will write the error with 400 status code.
-// If the error is db.ErrTaskNotFound, it will write the error with 404 status code.
+// If the error is db.ErrEmptyID, db.ErrInvalidQuery, db.ErrInvalidCursor or db.ErrMoveToSameList, it will write
+// the error with 400 status code.
+// If the error is db.ErrTaskNotFound, db.ErrNothingToUndo, db.ErrTagNotFound or db.ErrListNotFound, it will write
+// the error with 404 status code.
+// If the error is db.ErrUndoConflict, db.ErrTagExists or db.ErrListExists, it will write the error
+// with 409 status code.
 // Otherwise, it will write the error with 500 status code.
 func (h *Handlers) failWithTaskError(w http.ResponseWriter, caller string, err error) {
 	var (
 		status = http.StatusInternalServerError
 		msg    = "internal server error"
 	)
-	if errors.Is(err, db.ErrEmptyID) {
+	if errors.Is(err, db.ErrEmptyID) || errors.Is(err, db.ErrInvalidQuery) || errors.Is(err, db.ErrInvalidCursor) ||
+		errors.Is(err, db.ErrMoveToSameList) {
 		status = http.StatusBadRequest
 		msg = err.Error()
 	}
-	if errors.Is(err, db.ErrTaskNotFound) {
+	if errors.Is(err, db.ErrTaskNotFound) || errors.Is(err, db.ErrNothingToUndo) || errors.Is(err, db.ErrTagNotFound) ||
+		errors.Is(err, db.ErrListNotFound) {
 		status = http.StatusNotFound
 		msg = err.Error()
 	}
+	if errors.Is(err, db.ErrUndoConflict) || errors.Is(err, db.ErrTagExists) || errors.Is(err, db.ErrListExists) {
+		status = http.StatusConflict
+		msg = err.Error()
+	}
 
 	h.logger.Printf("%s: %v\n", caller, err)
 	h.writeJSON(w, response{Error: msg}, status)
@@ -322,36 +804,99 @@ func (h *Handlers) writeJSON(w http.ResponseWriter, data any, code int) {
 	}
 }
 
-// validateTask validates a task by checking its title and date.
-// It returns an error if the task's title is empty, or if the date is in the wrong format.
-// It also updates the task's date if it's in the past and the task has a repeat field.
+// validateTask validates a task by checking its title, date, end conditions and exceptions.
+// It returns an error if the task's title is empty, or if the date, the until date or an exception is in the wrong format.
+// End conditions, exceptions and the working day option are only accepted together with a repeat field,
+// and the remaining count mustn't be negative. Exceptions are sorted and deduplicated, and so are tags, see normalizeTag.
+// The priority must be one of none, low, medium, high and urgent, none being stored as no priority.
+// It also updates the task's date if it's in the past or excluded and the task has a repeat field.
+// A task in the past whose repeat rule has no further occurrences is rejected, as well as a task dated after its until date.
 // If the task's date is in the past and it doesn't have a repeat field, it sets the task's date to today.
-func validateTask(task *db.Task) error {
+// Finally, a task with the working day option is moved to the nearest working day of the given calendar.
+// The optional time of day is kept as it is, unless the task has an intraday repeat rule; see validateIntraday.
+func validateTask(task *db.Task, now time.Time, cal *holidays.Calendar) error {
 	if task.Title == "" {
 		return fmt.Errorf("title is required")
 	}
+	if task.Repeat == "" && (task.Until != "" || task.Remaining != 0 || len(task.Exceptions) > 0 || task.Workday) {
+		return fmt.Errorf("until, remaining, exceptions and workday require a repeat rule")
+	}
+	if task.Remaining < 0 {
+		return fmt.Errorf("remaining mustn't be negative")
+	}
+	if task.Until != "" {
+		if _, err := time.Parse(db.DateLayoutDB, task.Until); err != nil {
+			return fmt.Errorf("invalid until date format")
+		}
+	}
+	if task.Time != "" {
+		tm, err := time.Parse(db.TimeLayout, task.Time)
+		if err != nil {
+			return fmt.Errorf("invalid time format")
+		}
+		// Times like "9:30" are accepted, but stored zero-padded so that tasks sort by time.
+		task.Time = tm.Format(db.TimeLayout)
+	}
+	if len(task.Exceptions) > maxExceptions {
+		return fmt.Errorf("too many exceptions, at most %d are allowed", maxExceptions)
+	}
+	for _, ex := range task.Exceptions {
+		if _, err := time.Parse(db.DateLayoutDB, ex); err != nil {
+			return fmt.Errorf("invalid exception date format '%s'", ex)
+		}
+	}
+	slices.Sort(task.Exceptions)
+	task.Exceptions = slices.Compact(task.Exceptions)
+	if len(task.Tags) > maxTags {
+		return fmt.Errorf("too many tags, at most %d are allowed", maxTags)
+	}
+	for i, tag := range task.Tags {
+		var err error
+		if task.Tags[i], err = normalizeTag(tag); err != nil {
+			return err
+		}
+	}
+	slices.Sort(task.Tags)
+	task.Tags = slices.Compact(task.Tags)
+	if task.Priority == priorityNone {
+		task.Priority = db.PriorityNone
+	}
+	if !db.IsPriority(task.Priority) {
+		return fmt.Errorf("priority must be one of none, low, medium, high and urgent")
+	}
 
-	now := midnight(time.Now())
-	today := now.Format(db.DateLayoutDB)
+	today := midnight(now).Format(db.DateLayoutDB)
 
 	if task.Date == "" {
 		task.Date = today
 	}
+	task.Shift = 0
 
 	parsedDate, err := time.Parse(db.DateLayoutDB, task.Date)
 	if err != nil {
 		return fmt.Errorf("invalid date format")
 	}
 
+	if isIntraday(task.Repeat) {
+		return validateIntraday(task, now)
+	}
+
+	// A past task has to be moved, and so does a repeating task falling on one of its exceptions.
+	needMove := parsedDate.Before(midnight(now)) || slices.Contains(task.Exceptions, task.Date)
+
 	var nextDate string
 	if task.Repeat != "" {
-		nextDate, err = NextDate(now, task.Date, task.Repeat)
-		if err != nil {
+		nextDate, err = NextDateExcept(now, task.Date, task.Repeat, task.Exceptions, cal)
+		// A rule that has run out is still fine for a task that doesn't have to be moved.
+		if errors.Is(err, ErrRepeatEnded) && needMove {
+			return fmt.Errorf("repeat rule has no occurrences after %s", max(today, task.Date))
+		}
+		if err != nil && !errors.Is(err, ErrRepeatEnded) {
 			return err
 		}
 	}
 
-	if parsedDate.Before(now) {
+	if needMove {
 		if task.Repeat != "" {
 			task.Date = nextDate
 		} else {
@@ -359,5 +904,137 @@ func validateTask(task *db.Task) error {
 		}
 	}
 
+	if task.Until != "" && task.Date > task.Until {
+		return fmt.Errorf("task date '%s' is after the until date '%s'", task.Date, task.Until)
+	}
+
+	if task.Workday {
+		return shiftToWorkday(task, now, cal)
+	}
 	return nil
 }
+
+// advanceTask moves a repeating task to its first occurrence after now, and after its current date, that isn't excluded.
+// Tasks with the working day option are moved to the nearest working day of that occurrence.
+// The occurrences passed over, the current and the excluded ones included, are subtracted from the remaining count,
+// and COUNT of an RRULE repeat rule is adjusted the same way. Exceptions left behind are dropped.
+// It returns false, leaving the task untouched, if the task doesn't repeat or its series is over:
+// the repeat rule has no further occurrences, the next date is after the until date or no occurrences remain.
+// Tasks with an intraday repeat rule are moved by advanceIntraday.
+func advanceTask(task *db.Task, now time.Time, cal *holidays.Calendar) (bool, error) {
+	if task.Repeat == "" {
+		return false, nil
+	}
+	if isIntraday(task.Repeat) {
+		return advanceIntraday(task, now)
+	}
+
+	after := midnight(now)
+	if current, err := time.Parse(db.DateLayoutDB, task.Date); err == nil && current.After(after) {
+		after = current
+	}
+
+	start := nominalDate(task)
+	nextDate, date, err := nextOccurrence(task, after, cal)
+	if errors.Is(err, ErrRepeatEnded) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if task.Until != "" && nextDate > task.Until {
+		return false, nil
+	}
+
+	remaining := task.Remaining
+	if remaining > 0 {
+		passed, err := countOccurrences(start, nextDate, task.Repeat, cal)
+		if err != nil {
+			return false, err
+		}
+		if passed >= remaining {
+			return false, nil
+		}
+		remaining -= passed
+	}
+
+	repeat, err := advanceRepeat(task.Repeat, start, nextDate)
+	if err != nil {
+		return false, err
+	}
+
+	shift, err := shiftDays(nextDate, date)
+	if err != nil {
+		return false, err
+	}
+
+	task.Date, task.Shift = date, shift
+	task.Repeat, task.Remaining = repeat, remaining
+	task.Exceptions = slices.DeleteFunc(task.Exceptions, func(ex string) bool {
+		return ex < nextDate
+	})
+	return true, nil
+}
+
+// occurrences returns up to count dates a task with the given date, time, repeat rule and until date falls on,
+// starting from now. Dates of a task with a time of day, or with an intraday repeat rule, come with the time, e.g. "20240126 09:30".
+// It moves the task the same way validateTask and advanceTask do, so the dates match the ones the task would actually get.
+// The list is shorter than count if the series ends earlier, and empty if the task's date is already after the until date.
+func occurrences(now time.Time, date, tm, repeat, until string, count int, cal *holidays.Calendar) ([]string, error) {
+	if until != "" {
+		if _, err := time.Parse(db.DateLayoutDB, until); err != nil {
+			return nil, fmt.Errorf("invalid until date format")
+		}
+	}
+	if tm != "" {
+		parsed, err := time.Parse(db.TimeLayout, tm)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time format")
+		}
+		tm = parsed.Format(db.TimeLayout)
+	}
+
+	// The rule is checked up front, as a task dated in the future won't be moved before the second date.
+	if _, _, err := NextDateTime(now, date, tm, repeat, cal); err != nil && !errors.Is(err, ErrRepeatEnded) {
+		return nil, err
+	}
+
+	task := &db.Task{Date: date, Time: tm, Repeat: repeat, Until: until}
+	past := task.Date < midnight(now).Format(db.DateLayoutDB)
+	if isIntraday(repeat) {
+		if task.Time == "" {
+			task.Time = defaultTaskTime
+		}
+		start, err := parseDateTime(task.Date, task.Time)
+		if err != nil {
+			return nil, err
+		}
+		past = start.Before(wallClock(now))
+	}
+
+	next := true
+	if past {
+		var err error
+		if next, err = advanceTask(task, now, cal); err != nil {
+			return nil, err
+		}
+	}
+	if until != "" && task.Date > until {
+		next = false
+	}
+
+	dates := make([]string, 0, count)
+	for next && len(dates) < count {
+		if task.Time != "" {
+			dates = append(dates, task.Date+" "+task.Time)
+		} else {
+			dates = append(dates, task.Date)
+		}
+
+		var err error
+		if next, err = advanceTask(task, now, cal); err != nil {
+			return nil, err
+		}
+	}
+	return dates, nil
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mascotmascot1/go-todo/internal/db"
+)
+
+const (
+	auditFormatJSON = "json"
+	auditFormatCSV  = "csv"
+)
+
+type auditResponse struct {
+	Entries    []*db.AuditEntry `json:"entries"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// auditHandler returns the entries of the audit log, the most recent first.
+// The 'actor', 'operation' and 'task_id' parameters select the entries with those values, and the 'from' and 'to'
+// parameters in "YYYYMMDD" format select the entries logged from one date to another, both included,
+// in the timezone of the request.
+// With the 'format' parameter set to "csv", all the selected entries are exported as a CSV file.
+// Otherwise, they're returned page by page like the task list: the 'limit' parameter is the size of the page,
+// capped at the tasks limit, which is also the default, and the 'cursor' parameter is the "next_cursor"
+// of the previous page.
+// If any of the parameters is invalid, it will return an error with 400 status code.
+// The actor of an entry is the name given at sign-in, which isn't checked: everyone signs in with the same password,
+// so anyone can sign in under any name, and the actor only tells who claimed to make the change.
+// The JSON response will contain the list of entries under the key "entries", each one with the task
+// before and after the operation under "before" and "after", and the cursor of the next page, if any,
+// under "next_cursor".
+func (h *Handlers) auditHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "auditHandler"
+
+	filter, format, err := h.auditFilter(r)
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.store.Audit(r.Context(), filter)
+	if err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+
+	if format == auditFormatCSV {
+		h.writeAuditCSV(w, entries)
+		return
+	}
+	resp := auditResponse{Entries: entries}
+	if len(entries) > 0 && len(entries) == filter.Limit {
+		resp.NextCursor = entries[len(entries)-1].ID
+	}
+	h.writeJSON(w, resp, http.StatusOK)
+}
+
+// auditFilter parses the parameters of auditHandler.
+func (h *Handlers) auditFilter(r *http.Request) (db.AuditFilter, string, error) {
+	filter := db.AuditFilter{
+		Actor:     r.FormValue("actor"),
+		Operation: r.FormValue("operation"),
+		TaskID:    r.FormValue("task_id"),
+		AfterID:   r.FormValue("cursor"),
+	}
+	if filter.Operation != "" && !db.IsAuditOperation(filter.Operation) {
+		return filter, "", fmt.Errorf("unknown operation '%s'", filter.Operation)
+	}
+
+	loc, err := h.requestLocation(r)
+	if err != nil {
+		return filter, "", err
+	}
+	if from := r.FormValue("from"); from != "" {
+		if filter.From, err = time.ParseInLocation(db.DateLayoutDB, from, loc); err != nil {
+			return filter, "", fmt.Errorf("invalid 'from' date '%s'", from)
+		}
+	}
+	if to := r.FormValue("to"); to != "" {
+		day, err := time.ParseInLocation(db.DateLayoutDB, to, loc)
+		if err != nil {
+			return filter, "", fmt.Errorf("invalid 'to' date '%s'", to)
+		}
+		filter.To = day.AddDate(0, 0, 1)
+	}
+
+	format := r.FormValue("format")
+	switch format {
+	case "", auditFormatJSON:
+		format, filter.Limit = auditFormatJSON, h.limits.TasksLimit
+	case auditFormatCSV:
+	default:
+		return filter, "", fmt.Errorf("unknown format '%s'", format)
+	}
+	if limitStr := r.FormValue("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return filter, "", fmt.Errorf("'limit' must be a positive number")
+		}
+		filter.Limit = limit
+		if format == auditFormatJSON {
+			filter.Limit = min(limit, h.limits.TasksLimit)
+		}
+	}
+	return filter, format, nil
+}
+
+// writeAuditCSV writes the audit log entries as a CSV file with a header row,
+// the states of the task being JSON texts.
+func (h *Handlers) writeAuditCSV(w http.ResponseWriter, entries []*db.AuditEntry) {
+	w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "logged", "actor", "remote", "operation", "task_id", "before", "after"})
+	for _, e := range entries {
+		cw.Write([]string{e.ID, e.Logged, e.Actor, e.Remote, e.Operation, e.TaskID, string(e.Before), string(e.After)})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		h.logger.Printf("csv encode error: %v\n", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/mascotmascot1/go-todo/internal/config"
+	"github.com/mascotmascot1/go-todo/internal/db"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -17,8 +18,11 @@ type claims struct {
 	PassHash string `json:"pass_hash"`
 }
 
+// authRequest is a sign-in request. Name is optional: it becomes the subject of the token,
+// which the audit log records as who made the changes. It isn't checked, see auditHandler.
 type authRequest struct {
 	Password string `json:"password"`
+	Name     string `json:"name,omitempty"`
 }
 
 // signInHandler authenticates the user and returns a JWT token
@@ -60,7 +64,7 @@ func (h *Handlers) signInHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newToken, err := createToken(h.auth)
+	newToken, err := createToken(h.auth, req.Name)
 	if err != nil {
 		h.logger.Printf("%s: %v\n", caller, err)
 		h.writeJSON(w, response{Error: "failed to create token"}, http.StatusInternalServerError)
@@ -72,11 +76,13 @@ func (h *Handlers) signInHandler(w http.ResponseWriter, r *http.Request) {
 // withAuth returns a middleware that checks if the JWT token is provided in the cookies.
 // If the token is not provided, it will return an error with 401 status code.
 // If the token is invalid, it will return an error with 401 status code.
-// If the token is valid, it will call the next handler in the chain.
+// Otherwise, it will call the next handler in the chain with the actor of the request in its context, see db.WithActor:
+// the subject of the token, empty without a password, and the remote address of the request.
 func (h *Handlers) withAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		caller := "auth middleware"
 
+		var subject string
 		if h.auth.Password != "" {
 			cookie, err := r.Cookie("token")
 			if err != nil {
@@ -91,23 +97,25 @@ func (h *Handlers) withAuth(next http.Handler) http.Handler {
 			}
 
 			tokenString := cookie.Value
-			if err := validateToken(tokenString, h.auth.PasswordHash, h.auth.SecretKey); err != nil {
+			subject, err = validateToken(tokenString, h.auth.PasswordHash, h.auth.SecretKey)
+			if err != nil {
 				h.logger.Printf("%s: %v\n", caller, err)
 				h.writeJSON(w, "invalid JWT token", http.StatusUnauthorized)
 				return
 			}
 		}
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(db.WithActor(r.Context(), subject, r.RemoteAddr)))
 	})
 }
 
 // createToken creates a JWT token that can be used for authentication.
-// It takes authentication configuration as an argument and returns a signed token.
+// It takes authentication configuration and the subject of the token as arguments and returns a signed token.
 // If there is an error while creating the token, it will return an error with a description.
 // The token will contain the password hash from the authentication configuration and expire after the TokenTTL has passed.
-func createToken(auth *config.Auth) (string, error) {
+func createToken(auth *config.Auth, subject string) (string, error) {
 	c := claims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(auth.TokenTTL)),
 
 			IssuedAt: jwt.NewNumericDate(time.Now()),
@@ -125,8 +133,8 @@ func createToken(auth *config.Auth) (string, error) {
 // validateToken validates the given JWT token.
 // It takes the token string, password hash from the authentication configuration and secret key as arguments.
 // If the token is invalid, it will return an error with a description.
-// If the token is valid, it will return nil.
-func validateToken(tokenString, passwordHash string, secretKey []byte) error {
+// If the token is valid, it will return the subject of the token, empty if it has none.
+func validateToken(tokenString, passwordHash string, secretKey []byte) (string, error) {
 	var c claims
 
 	parsedToken, err := jwt.ParseWithClaims(tokenString, &c, func(t *jwt.Token) (any, error) {
@@ -136,15 +144,15 @@ func validateToken(tokenString, passwordHash string, secretKey []byte) error {
 		return secretKey, nil
 	})
 	if err != nil {
-		return fmt.Errorf("failed to parse token: %w", err)
+		return "", fmt.Errorf("failed to parse token: %w", err)
 	}
 
 	if !parsedToken.Valid {
-		return fmt.Errorf("token is invalid")
+		return "", fmt.Errorf("token is invalid")
 	}
 
 	if c.PassHash != passwordHash {
-		return fmt.Errorf("invalid password hash")
+		return "", fmt.Errorf("invalid password hash")
 	}
-	return nil
+	return c.Subject, nil
 }
@@ -0,0 +1,605 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	LangEnglish = "en"
+	LangRussian = "ru"
+)
+
+type repeatUnit int
+
+const (
+	unitDay repeatUnit = iota
+	unitWeek
+	unitMonth
+	unitYear
+	unitBusinessDay
+	unitHour
+	unitMinute
+)
+
+var (
+	ruMonthsGen = [...]string{"января", "февраля", "марта", "апреля", "мая", "июня",
+		"июля", "августа", "сентября", "октября", "ноября", "декабря"}
+	ruMonthsPrep = [...]string{"январе", "феврале", "марте", "апреле", "мае", "июне",
+		"июле", "августе", "сентябре", "октябре", "ноябре", "декабре"}
+
+	// Weekdays are indexed by time.Weekday, so Sunday goes first.
+	ruWeekdaysNom = [...]string{"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"}
+	ruWeekdaysAcc = [...]string{"воскресенье", "понедельник", "вторник", "среду", "четверг", "пятницу", "субботу"}
+	ruWeekdaysDat = [...]string{"воскресеньям", "понедельникам", "вторникам", "средам", "четвергам", "пятницам", "субботам"}
+	// ruWeekdayGender holds the accusative endings of ordinals agreeing with each weekday:
+	// masculine "-й", feminine "-ю" and neuter "-е".
+	ruWeekdayGender = [...]string{"е", "й", "й", "ю", "й", "ю", "ю"}
+)
+
+// repeatSpec is a repeat rule broken down for describing, whatever syntax it was written in.
+// Empty lists mean the rule doesn't restrict the dates by that part.
+type repeatSpec struct {
+	unit            repeatUnit
+	interval        int
+	weekdays        []time.Weekday
+	monthDays       []int
+	monthWeekdays   []monthWeekday
+	months          []int
+	lastBusinessDay bool
+	window          string
+	setPos          []int
+	count           int
+	until           time.Time
+}
+
+// DescribeRepeat turns a repeat rule into a human-readable sentence in the given language,
+// e.g. "m -1,15 3,6,9,12" becomes "on the 15th and last day of Mar, Jun, Sep, Dec".
+// Any rule accepted by NextDateTime can be described. The supported languages are LangEnglish and LangRussian.
+// It returns an error if the repeat rule is invalid or the language is not supported.
+func DescribeRepeat(repeat, lang string) (string, error) {
+	spec, err := parseRepeatSpec(strings.TrimSpace(repeat))
+	if err != nil {
+		return "", err
+	}
+
+	switch lang {
+	case LangEnglish:
+		return describeEN(spec), nil
+	case LangRussian:
+		return describeRU(spec), nil
+	default:
+		return "", fmt.Errorf("unsupported language '%s'", lang)
+	}
+}
+
+// requestLang returns the language of the descriptions for the given request.
+// The "lang" query parameter goes first, then the Accept-Language header.
+// Russian is the default, since it's the language of the bundled web UI.
+func requestLang(r *http.Request) string {
+	if lang := r.FormValue("lang"); lang == LangEnglish || lang == LangRussian {
+		return lang
+	}
+
+	for tag := range strings.SplitSeq(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ = strings.Cut(strings.TrimSpace(tag), ";")
+		tag, _, _ = strings.Cut(strings.ToLower(tag), "-")
+		if tag == LangEnglish || tag == LangRussian {
+			return tag
+		}
+	}
+	return LangRussian
+}
+
+// parseRepeatSpec breaks the given repeat rule down into a repeatSpec.
+// It accepts the same rules as NextDate and returns an error for anything else.
+func parseRepeatSpec(repeat string) (*repeatSpec, error) {
+	if repeat == "" {
+		return nil, fmt.Errorf("repeat rule is empty")
+	}
+	if isRRule(repeat) {
+		return rruleSpec(repeat)
+	}
+
+	spec := &repeatSpec{interval: 1}
+	parts := strings.Split(repeat, " ")
+
+	var err error
+	switch {
+	case reDay.MatchString(repeat):
+		spec.unit = unitDay
+		spec.interval, err = strconv.Atoi(parts[1])
+		if err == nil && (spec.interval <= 0 || spec.interval > maxDaysInterval) {
+			err = fmt.Errorf("invalid day interval '%d'", spec.interval)
+		}
+
+	case reYear.MatchString(repeat):
+		spec.unit = unitYear
+		if len(parts) == 2 {
+			spec.interval, err = strconv.Atoi(parts[1])
+			if err == nil && (spec.interval <= 0 || spec.interval > maxPeriodsInterval) {
+				err = fmt.Errorf("invalid year interval '%d'", spec.interval)
+			}
+		}
+
+	case reWeek.MatchString(repeat):
+		spec.unit = unitWeek
+		if spec.interval, err = parseInterval(parts[0]); err != nil {
+			break
+		}
+		var weekDaysInt []int
+		if weekDaysInt, err = parseWeekdays(parts[1]); err != nil {
+			break
+		}
+		// Monday goes first, as in the rule itself.
+		for _, wd := range weekDaysInt {
+			spec.weekdays = append(spec.weekdays, time.Weekday(wd%sundayNum))
+		}
+
+	case reMonth.MatchString(repeat), reMonthWd.MatchString(repeat):
+		spec.unit = unitMonth
+		if spec.interval, err = parseInterval(parts[0]); err != nil {
+			break
+		}
+		if strings.HasPrefix(parts[1], "w") {
+			spec.monthWeekdays, err = parseMonthWeekdays(parts[1])
+		} else {
+			spec.monthDays, err = parseMonthDays(parts[1])
+		}
+		if err == nil && len(parts) == expectedPartsWithMonths {
+			spec.months, err = parseMonths(parts)
+		}
+
+	case isIntraday(repeat):
+		spec.unit = unitMinute
+		if parts[0] == ruleHours {
+			spec.unit = unitHour
+		}
+		var r *intradayRule
+		if r, err = parseIntraday(repeat); err != nil {
+			break
+		}
+		spec.interval = r.step
+		if spec.unit == unitHour {
+			spec.interval /= 60
+		}
+		if r.windowed {
+			spec.window = parts[2]
+		}
+
+	case reBizDay.MatchString(repeat):
+		spec.unit = unitBusinessDay
+		if parts[1] == lastBusinessDay {
+			spec.lastBusinessDay = true
+			break
+		}
+		spec.interval, err = strconv.Atoi(parts[1])
+		if err == nil && (spec.interval <= 0 || spec.interval > maxDaysInterval) {
+			err = fmt.Errorf("invalid business day interval '%d'", spec.interval)
+		}
+
+	default:
+		err = fmt.Errorf("unsupported interval format '%s'", repeat)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// rruleSpec breaks an RRULE repeat rule down into a repeatSpec.
+// BYDAY values go to monthWeekdays if any of them is numbered, and to weekdays otherwise.
+func rruleSpec(repeat string) (*repeatSpec, error) {
+	r, err := parseRRule(repeat)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &repeatSpec{
+		interval:  r.interval,
+		monthDays: r.byMonthDay,
+		months:    r.byMonth,
+		setPos:    r.bySetPos,
+		count:     r.count,
+		until:     r.until,
+	}
+	switch r.freq {
+	case freqDaily:
+		spec.unit = unitDay
+	case freqWeekly:
+		spec.unit = unitWeek
+	case freqMonthly:
+		spec.unit = unitMonth
+	case freqYearly:
+		spec.unit = unitYear
+	}
+
+	numbered := false
+	for _, wd := range r.byDay {
+		numbered = numbered || wd.ordinal != 0
+	}
+	for _, wd := range r.byDay {
+		if numbered {
+			spec.monthWeekdays = append(spec.monthWeekdays, monthWeekday{ordinal: wd.ordinal, weekday: wd.weekday})
+		} else {
+			spec.weekdays = append(spec.weekdays, wd.weekday)
+		}
+	}
+	return spec, nil
+}
+
+// hasDays reports whether the rule picks particular days of the month or of the year.
+func (s *repeatSpec) hasDays() bool {
+	return len(s.monthDays) > 0 || len(s.monthWeekdays) > 0
+}
+
+// orderedMonthDays returns the month days with the ones counted from the start of the month first,
+// so that "-1,15" reads as "the 15th and last day".
+func (s *repeatSpec) orderedMonthDays() []int {
+	days := make([]int, 0, len(s.monthDays))
+	for _, md := range s.monthDays {
+		if md > 0 {
+			days = append(days, md)
+		}
+	}
+	for _, md := range s.monthDays {
+		if md < 0 {
+			days = append(days, md)
+		}
+	}
+	return days
+}
+
+// describeEN describes the rule in English.
+func describeEN(s *repeatSpec) string {
+	var b strings.Builder
+
+	switch {
+	case s.lastBusinessDay:
+		b.WriteString("on the last business day of every month")
+
+	case s.unit == unitMonth && s.hasDays():
+		b.WriteString("on " + enDays(s) + " of ")
+		if len(s.months) > 0 {
+			b.WriteString(enMonths(s.months))
+			if s.interval > 1 {
+				b.WriteString(", " + enEvery(s.unit, s.interval))
+			}
+		} else {
+			b.WriteString(enEvery(s.unit, s.interval))
+		}
+		if len(s.weekdays) > 0 {
+			b.WriteString(" if it's a " + joinList(enWeekdays(s.weekdays), "or"))
+		}
+
+	default:
+		b.WriteString(enEvery(s.unit, s.interval))
+		switch {
+		case s.hasDays():
+			b.WriteString(" on " + enDays(s))
+			if len(s.months) > 0 {
+				b.WriteString(" of " + enMonths(s.months))
+			} else if s.unit == unitYear && len(s.monthDays) == 0 {
+				b.WriteString(" of the year")
+			} else if s.unit == unitYear {
+				b.WriteString(" of every month")
+			}
+			if len(s.weekdays) > 0 {
+				b.WriteString(" if it's a " + joinList(enWeekdays(s.weekdays), "or"))
+			}
+		case len(s.weekdays) > 0:
+			b.WriteString(" on " + joinList(enWeekdays(s.weekdays), "and"))
+			if len(s.months) > 0 {
+				b.WriteString(" in " + enMonths(s.months))
+			}
+		case len(s.months) > 0:
+			b.WriteString(" in " + enMonths(s.months))
+		case s.window != "":
+			from, to, _ := strings.Cut(s.window, "-")
+			b.WriteString(" from " + from + " to " + to)
+		}
+	}
+
+	if len(s.setPos) > 0 {
+		positions := make([]string, 0, len(s.setPos))
+		for _, pos := range s.setPos {
+			positions = append(positions, enPosition(pos))
+		}
+		b.WriteString(", only the " + joinList(positions, "and") + " of them")
+	}
+	if s.count > 0 {
+		if s.count == 1 {
+			b.WriteString(", once")
+		} else {
+			fmt.Fprintf(&b, ", %d times", s.count)
+		}
+	}
+	if !s.until.IsZero() {
+		b.WriteString(", until " + s.until.Format("Jan 2, 2006"))
+	}
+	return b.String()
+}
+
+// enEvery describes the period of the rule, e.g. "every day" or "every 3 weeks".
+func enEvery(unit repeatUnit, n int) string {
+	name := [...]string{"day", "week", "month", "year", "business day", "hour", "minute"}[unit]
+	if n == 1 {
+		return "every " + name
+	}
+	return fmt.Sprintf("every %d %ss", n, name)
+}
+
+// enDays describes the days of the month or the numbered weekdays of the rule,
+// e.g. "the 15th and last day" or "the 2nd Tue and last Fri".
+func enDays(s *repeatSpec) string {
+	items := make([]string, 0, len(s.monthDays)+len(s.monthWeekdays))
+
+	fromEnd := false
+	for _, md := range s.orderedMonthDays() {
+		items = append(items, enPosition(md))
+		fromEnd = fromEnd || md < 0
+	}
+	days := ""
+	if len(items) > 0 {
+		days = "the " + joinList(items, "and")
+		if fromEnd {
+			days += " day"
+		}
+	}
+
+	items = items[:0]
+	for _, e := range s.monthWeekdays {
+		wd := enWeekdays([]time.Weekday{e.weekday})[0]
+		if e.ordinal == 0 {
+			items = append(items, "every "+wd)
+		} else {
+			items = append(items, "the "+enPosition(e.ordinal)+" "+wd)
+		}
+	}
+	if len(items) > 0 {
+		if days != "" {
+			days += " and "
+		}
+		days += joinList(items, "and")
+	}
+	return days
+}
+
+// enPosition returns the English ordinal of the given position;
+// negative positions count from the end: "last", "second to last", "3rd to last".
+func enPosition(n int) string {
+	switch {
+	case n == -1:
+		return "last"
+	case n == -2:
+		return "second to last"
+	case n < 0:
+		return enOrdinal(-n) + " to last"
+	default:
+		return enOrdinal(n)
+	}
+}
+
+// enOrdinal returns the English ordinal number, e.g. "1st", "12th" or "23rd".
+func enOrdinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// enWeekdays returns the English abbreviations of the given weekdays.
+func enWeekdays(weekdays []time.Weekday) []string {
+	names := make([]string, 0, len(weekdays))
+	for _, wd := range weekdays {
+		names = append(names, wd.String()[:3])
+	}
+	return names
+}
+
+// enMonths returns the comma-separated English abbreviations of the given months.
+func enMonths(months []int) string {
+	names := make([]string, 0, len(months))
+	for _, m := range months {
+		names = append(names, time.Month(m).String()[:3])
+	}
+	return strings.Join(names, ", ")
+}
+
+// describeRU describes the rule in Russian.
+func describeRU(s *repeatSpec) string {
+	var b strings.Builder
+
+	switch {
+	case s.lastBusinessDay:
+		b.WriteString("в последний рабочий день каждого месяца")
+
+	case s.unit == unitMonth && s.hasDays():
+		b.WriteString(ruDays(s) + " ")
+		switch {
+		case len(s.months) > 0:
+			b.WriteString(ruMonths(s.months, ruMonthsGen[:]))
+			if s.interval > 1 {
+				b.WriteString(" " + ruOnceIn(s.interval))
+			}
+		case s.interval > 1:
+			b.WriteString(ruOnceIn(s.interval))
+		default:
+			b.WriteString("каждого месяца")
+		}
+		if len(s.weekdays) > 0 {
+			b.WriteString(", если это " + ruWeekdays(s.weekdays, ruWeekdaysNom[:], "или"))
+		}
+
+	default:
+		b.WriteString(ruEvery(s.unit, s.interval))
+		switch {
+		case s.hasDays():
+			b.WriteString(" " + ruDays(s))
+			if len(s.months) > 0 {
+				b.WriteString(" " + ruMonths(s.months, ruMonthsGen[:]))
+			} else if s.unit == unitYear && len(s.monthDays) == 0 {
+				b.WriteString(" года")
+			} else if s.unit == unitYear {
+				b.WriteString(" каждого месяца")
+			}
+			if len(s.weekdays) > 0 {
+				b.WriteString(", если это " + ruWeekdays(s.weekdays, ruWeekdaysNom[:], "или"))
+			}
+		case len(s.weekdays) > 0:
+			b.WriteString(" по " + ruWeekdays(s.weekdays, ruWeekdaysDat[:], "и"))
+			if len(s.months) > 0 {
+				b.WriteString(" в " + ruMonths(s.months, ruMonthsPrep[:]))
+			}
+		case len(s.months) > 0:
+			b.WriteString(" в " + ruMonths(s.months, ruMonthsPrep[:]))
+		case s.window != "":
+			from, to, _ := strings.Cut(s.window, "-")
+			b.WriteString(" с " + from + " до " + to)
+		}
+	}
+
+	if len(s.setPos) > 0 {
+		positions := make([]string, 0, len(s.setPos))
+		for _, pos := range s.setPos {
+			positions = append(positions, ruPosition(pos, "е", "последнее", "предпоследнее"))
+		}
+		b.WriteString(", из них только " + joinList(positions, "и"))
+	}
+	if s.count > 0 {
+		fmt.Fprintf(&b, ", %d %s", s.count, ruPlural(s.count, "раз", "раза", "раз"))
+	}
+	if !s.until.IsZero() {
+		b.WriteString(", до " + s.until.Format("02.01.2006"))
+	}
+	return b.String()
+}
+
+// ruEvery describes the period of the rule, e.g. "каждый день" or "каждые 3 недели".
+func ruEvery(unit repeatUnit, n int) string {
+	forms := [...][3]string{
+		{"день", "дня", "дней"},
+		{"неделю", "недели", "недель"},
+		{"месяц", "месяца", "месяцев"},
+		{"год", "года", "лет"},
+		{"рабочий день", "рабочих дня", "рабочих дней"},
+		{"час", "часа", "часов"},
+		{"минуту", "минуты", "минут"},
+	}[unit]
+
+	each := "каждый"
+	if unit == unitWeek || unit == unitMinute {
+		each = "каждую"
+	}
+	if n == 1 {
+		return each + " " + forms[0]
+	}
+	if n%10 == 1 && n%100 != 11 {
+		return fmt.Sprintf("%s %d %s", each, n, forms[0])
+	}
+	return fmt.Sprintf("каждые %d %s", n, ruPlural(n, forms[0], forms[1], forms[2]))
+}
+
+// ruOnceIn describes an interval of months, e.g. "раз в 2 месяца".
+func ruOnceIn(n int) string {
+	return fmt.Sprintf("раз в %d %s", n, ruPlural(n, "месяц", "месяца", "месяцев"))
+}
+
+// ruDays describes the days of the month or the numbered weekdays of the rule,
+// e.g. "15-го и последнего числа" or "во 2-й вторник и в последнюю пятницу".
+func ruDays(s *repeatSpec) string {
+	items := make([]string, 0, len(s.monthDays)+len(s.monthWeekdays))
+	for _, md := range s.orderedMonthDays() {
+		items = append(items, ruPosition(md, "го", "последнего", "предпоследнего"))
+	}
+	days := ""
+	if len(items) > 0 {
+		days = joinList(items, "и") + " числа"
+	}
+
+	items = items[:0]
+	for _, e := range s.monthWeekdays {
+		gender := ruWeekdayGender[e.weekday]
+		switch {
+		case e.ordinal == 0:
+			items = append(items, "по "+ruWeekdaysDat[e.weekday])
+		case e.ordinal == 2:
+			items = append(items, "во 2-"+gender+" "+ruWeekdaysAcc[e.weekday])
+		default:
+			last := map[string]string{"й": "последний", "ю": "последнюю", "е": "последнее"}[gender]
+			items = append(items, "в "+ruPosition(e.ordinal, gender, last, "пред"+last)+" "+ruWeekdaysAcc[e.weekday])
+		}
+	}
+	if len(items) > 0 {
+		if days != "" {
+			days += " и "
+		}
+		days += joinList(items, "и")
+	}
+	return days
+}
+
+// ruPosition returns the Russian ordinal of the given position with the given ending,
+// or the given words for the last and the second to last ones; other negative positions count from the end.
+func ruPosition(n int, ending, last, beforeLast string) string {
+	switch {
+	case n == -1:
+		return last
+	case n == -2:
+		return beforeLast
+	case n < 0:
+		return fmt.Sprintf("%d-%s с конца", -n, ending)
+	default:
+		return fmt.Sprintf("%d-%s", n, ending)
+	}
+}
+
+// ruWeekdays joins the names of the given weekdays taken from the given declension.
+func ruWeekdays(weekdays []time.Weekday, names []string, conj string) string {
+	items := make([]string, 0, len(weekdays))
+	for _, wd := range weekdays {
+		items = append(items, names[wd])
+	}
+	return joinList(items, conj)
+}
+
+// ruMonths returns the comma-separated names of the given months taken from the given declension.
+func ruMonths(months []int, names []string) string {
+	items := make([]string, 0, len(months))
+	for _, m := range months {
+		items = append(items, names[m-1])
+	}
+	return strings.Join(items, ", ")
+}
+
+// ruPlural picks the Russian plural form that agrees with n: one for 1, 21, ..., few for 2-4, 22-24, ...
+// and many for the rest.
+func ruPlural(n int, one, few, many string) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return one
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return few
+	default:
+		return many
+	}
+}
+
+// joinList joins the items as "a, b and c" with the given conjunction.
+func joinList(items []string, conj string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " " + conj + " " + items[len(items)-1]
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mascotmascot1/go-todo/internal/db"
+)
+
+// statsDays is how far back, in days, the stats of repeating tasks look.
+const statsDays = 365
+
+type historyResponse struct {
+	Completions []*db.Completion `json:"completions"`
+}
+
+type taskStats struct {
+	ID         string  `json:"id"`
+	Title      string  `json:"title"`
+	Repeat     string  `json:"repeat"`
+	Completed  int     `json:"completed"`
+	Expected   int     `json:"expected"`
+	Rate       float64 `json:"rate"`
+	Streak     int     `json:"streak"`
+	BestStreak int     `json:"best_streak"`
+}
+
+type historyStatsResponse struct {
+	Stats []taskStats `json:"stats"`
+}
+
+// historyHandler returns the completions of tasks, the most recent first.
+// The 'task_id' parameter selects the completions of a single task, and the 'from' and 'to' parameters
+// in "YYYYMMDD" format select the completions of occurrences scheduled from one date to another, both included.
+// The 'limit' parameter is the maximum number of completions, capped at the tasks limit, which is also the default.
+// If any of the parameters is invalid, it will return an error with 400 status code.
+// The response will be in JSON format and will contain the list of completions under the key "completions".
+func (h *Handlers) historyHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "historyHandler"
+
+	filter := db.HistoryFilter{
+		TaskID: r.FormValue("task_id"),
+		From:   r.FormValue("from"),
+		To:     r.FormValue("to"),
+		Limit:  h.limits.TasksLimit,
+	}
+	for name, value := range map[string]string{"from": filter.From, "to": filter.To} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(db.DateLayoutDB, value); err != nil {
+			h.logger.Printf("%s: invalid '%s' date '%s'\n", caller, name, value)
+			h.writeJSON(w, response{Error: fmt.Sprintf("invalid '%s' date '%s'", name, value)}, http.StatusBadRequest)
+			return
+		}
+	}
+	if limitStr := r.FormValue("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			h.logger.Printf("%s: invalid 'limit' parameter '%s'\n", caller, limitStr)
+			h.writeJSON(w, response{Error: "'limit' must be a positive number"}, http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(limit, h.limits.TasksLimit)
+	}
+
+	completions, err := h.store.History(r.Context(), filter)
+	if err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+	h.writeJSON(w, historyResponse{Completions: completions}, http.StatusOK)
+}
+
+// historyStatsHandler returns the stats of the repeating tasks completed within the last statsDays days,
+// or of the single task given by the 'task_id' parameter, see completionStats.
+// Tasks that were deleted, no longer repeat or repeat within a day have no stats.
+// The response will be in JSON format and will contain the list of stats under the key "stats".
+// Each one has the id, title and repeat rule of the task, the number of completed and expected occurrences,
+// the completion rate, the current streak and the best streak.
+func (h *Handlers) historyStatsHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "historyStatsHandler"
+
+	now, err := h.requestNow(r)
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	today := midnight(now)
+
+	completions, err := h.store.History(r.Context(), db.HistoryFilter{
+		TaskID: r.FormValue("task_id"),
+		From:   today.AddDate(0, 0, -statsDays).Format(db.DateLayoutDB),
+	})
+	if err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+
+	// The completions are the most recent first, so the tasks are ordered by their latest completion.
+	var ids []string
+	dates := map[string][]string{}
+	for _, c := range completions {
+		if _, ok := dates[c.TaskID]; !ok {
+			ids = append(ids, c.TaskID)
+		}
+		dates[c.TaskID] = append(dates[c.TaskID], c.Date)
+	}
+
+	stats := []taskStats{}
+	for _, id := range ids {
+		task, err := h.store.GetTask(r.Context(), id)
+		if errors.Is(err, db.ErrTaskNotFound) {
+			continue
+		}
+		if err != nil {
+			h.failWithTaskError(w, caller, err)
+			return
+		}
+		if task.Repeat == "" || isIntraday(task.Repeat) {
+			continue
+		}
+
+		s, err := h.completionStats(task, dates[id], today)
+		if err != nil {
+			// A task with a broken rule mustn't hide the stats of the others.
+			h.logger.Printf("%s: failed to compute the stats of task '%s': %v\n", caller, id, err)
+			continue
+		}
+		stats = append(stats, s)
+	}
+	h.writeJSON(w, historyStatsResponse{Stats: stats}, http.StatusOK)
+}
+
+// completionStats computes the stats of a repeating task from the scheduled dates of its completions.
+// The expected occurrences are those of the series from the earliest completed one up to the task's date or today,
+// whichever is later, excluding it: that one is still to be done. An expected occurrence is completed
+// if there's a completion for its date, and the rate is the share of completed occurrences.
+// The current streak counts the completed occurrences back from the latest expected one.
+func (h *Handlers) completionStats(task *db.Task, dates []string, today time.Time) (taskStats, error) {
+	stats := taskStats{ID: task.ID, Title: task.Title, Repeat: task.Repeat}
+
+	done := make(map[string]bool, len(dates))
+	first := task.Date
+	for _, date := range dates {
+		done[date] = true
+		first = min(first, date)
+	}
+	end := today.Format(db.DateLayoutDB)
+	if task.Date > end {
+		end = task.Date
+	}
+	endDate, err := time.Parse(db.DateLayoutDB, end)
+	if err != nil {
+		return taskStats{}, fmt.Errorf("error parsing the date '%s': %w", end, err)
+	}
+	last := endDate.AddDate(0, 0, -1).Format(db.DateLayoutDB)
+	if last < first {
+		return stats, nil
+	}
+
+	// The series is replayed from the earliest completion, whose date is an occurrence of the rule.
+	series := *task
+	series.Date, series.Shift, series.Remaining = first, 0, 0
+	occurrences, err := expandTask(&series, first, last, h.location, h.holidays)
+	if err != nil {
+		return taskStats{}, err
+	}
+
+	run := 0
+	for _, occurrence := range occurrences {
+		if !done[occurrence.Date] {
+			run = 0
+			continue
+		}
+		stats.Completed++
+		run++
+		stats.BestStreak = max(stats.BestStreak, run)
+	}
+	stats.Streak = run
+	stats.Expected = len(occurrences)
+	if stats.Expected > 0 {
+		stats.Rate = float64(stats.Completed) / float64(stats.Expected)
+	}
+	return stats, nil
+}
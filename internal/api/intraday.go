@@ -0,0 +1,261 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mascotmascot1/go-todo/internal/db"
+	"github.com/mascotmascot1/go-todo/internal/holidays"
+)
+
+const (
+	ruleHours       = "h"
+	maxHoursStep    = 24
+	maxMinutesStep  = 24 * 60
+	minutesInDay    = 24 * 60
+	defaultTaskTime = "00:00"
+)
+
+var reIntraday = regexp.MustCompile(`^(h|min) \d{1,4}( \d{2}:\d{2}-\d{2}:\d{2})?$`)
+
+// intradayRule is a parsed "h" or "min" repeat rule. All values are in minutes,
+// from and to being the minutes of the day the window starts and ends at.
+type intradayRule struct {
+	step     int
+	from, to int
+	windowed bool
+}
+
+// isIntraday reports whether the given repeat rule repeats a task several times a day
+// rather than on particular dates.
+func isIntraday(repeat string) bool {
+	return reIntraday.MatchString(strings.TrimSpace(repeat))
+}
+
+// NextDateTime computes the next date and time of day of a task given its date, time and repeat rule.
+// Besides the rules accepted by NextDate, the following intraday rules are supported:
+//
+// - "h <number> [HH:MM-HH:MM]"   — repeat every <number> hours (1..24)
+// - "min <number> [HH:MM-HH:MM]" — repeat every <number> minutes (1..1440)
+//
+// Without a window, an intraday rule repeats around the clock, counting from the task's date and time.
+// With a window, it repeats every day from the start of the window up to its end, both included.
+// The next occurrence of an intraday rule is the first one after both now and the initial date and time;
+// an empty time stands for midnight.
+// Date rules keep the time of day as it is, so their next date is computed by NextDate.
+// The returned date and time are in "YYYYMMDD" and "HH:MM" formats.
+func NextDateTime(now time.Time, dStart, tStart, repeat string, cal *holidays.Calendar) (string, string, error) {
+	repeat = strings.TrimSpace(repeat)
+	if !isIntraday(repeat) {
+		date, err := NextDate(now, dStart, repeat, cal)
+		return date, tStart, err
+	}
+
+	r, err := parseIntraday(repeat)
+	if err != nil {
+		return "", "", err
+	}
+	start, err := parseDateTime(strings.TrimSpace(dStart), tStart)
+	if err != nil {
+		return "", "", err
+	}
+
+	next := r.next(start, now)
+	return next.Format(db.DateLayoutDB), next.Format(db.TimeLayout), nil
+}
+
+// parseIntraday parses an intraday repeat rule of the format "h|min <number> [HH:MM-HH:MM]".
+func parseIntraday(repeat string) (*intradayRule, error) {
+	parts := strings.Split(repeat, " ")
+
+	step, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid step value: %w", err)
+	}
+	maxStep := maxMinutesStep
+	if parts[0] == ruleHours {
+		maxStep = maxHoursStep
+	}
+	if step <= 0 || step > maxStep {
+		return nil, fmt.Errorf("invalid step '%d', must be between 1 and %d", step, maxStep)
+	}
+	if parts[0] == ruleHours {
+		step *= 60
+	}
+
+	r := &intradayRule{step: step, to: minutesInDay - 1}
+	if len(parts) == 3 {
+		fromStr, toStr, _ := strings.Cut(parts[2], "-")
+		if r.from, err = minuteOfDay(fromStr); err != nil {
+			return nil, err
+		}
+		if r.to, err = minuteOfDay(toStr); err != nil {
+			return nil, err
+		}
+		if r.from > r.to {
+			return nil, fmt.Errorf("invalid window '%s': it must start before it ends", parts[2])
+		}
+		r.windowed = true
+	}
+	return r, nil
+}
+
+// next returns the first occurrence of the rule anchored at start that is after both now and start.
+// Start and the result are wall clock times, see wallClock, while now is taken in its own timezone.
+// The steps of a rule without a window are measured in real time, so they keep their length across DST changes.
+func (r *intradayRule) next(start, now time.Time) time.Time {
+	step := time.Duration(r.step) * time.Minute
+	if !r.windowed {
+		startAt := inZone(start, now.Location())
+		base := later(now, startAt)
+		next := startAt.Add((base.Sub(startAt)/step + 1) * step)
+		// When DST ends, the wall clock goes back, so an occurrence may fall on the same wall clock time as start.
+		for !wallClock(next).After(start) {
+			next = next.Add(step)
+		}
+		return wallClock(next)
+	}
+
+	base := later(wallClock(now), start)
+	day := midnight(base)
+	// The first slot of the window that is after base, if there's one left on that day.
+	if slot := r.slot(base) + 1; r.from+slot*r.step <= r.to {
+		return day.Add(time.Duration(r.from+slot*r.step) * time.Minute)
+	}
+	return day.AddDate(0, 0, 1).Add(time.Duration(r.from) * time.Minute)
+}
+
+// count returns the number of occurrences from the current one, which is counted in, up to but not including next.
+// Both are wall clock times in the given timezone.
+func (r *intradayRule) count(current, next time.Time, loc *time.Location) int {
+	if !r.windowed {
+		step := time.Duration(r.step) * time.Minute
+		return int((inZone(next, loc).Sub(inZone(current, loc)) + step - 1) / step)
+	}
+
+	// Occurrences are numbered across days, so the count is the difference of their numbers.
+	perDay := (r.to-r.from)/r.step + 1
+	index := func(t time.Time) int {
+		return daysBetween(midnight(current), midnight(t))*perDay + min(r.slot(t), perDay-1)
+	}
+	return index(next) - index(current)
+}
+
+// slot returns the number of the last slot of the window at or before the given time on its day,
+// or -1 if the time is before the window starts.
+func (r *intradayRule) slot(t time.Time) int {
+	minute := t.Hour()*60 + t.Minute()
+	if minute < r.from {
+		return -1
+	}
+	return (minute - r.from) / r.step
+}
+
+// advanceIntraday moves a task with an intraday repeat rule to its first occurrence after now, and after its current
+// date and time. The occurrences passed over, the current one included, are subtracted from the remaining count.
+// It returns false, leaving the task untouched, if the next date is after the until date or no occurrences remain.
+func advanceIntraday(task *db.Task, now time.Time) (bool, error) {
+	r, err := parseIntraday(task.Repeat)
+	if err != nil {
+		return false, err
+	}
+	current, err := parseDateTime(task.Date, task.Time)
+	if err != nil {
+		return false, err
+	}
+
+	next := r.next(current, now)
+	date := next.Format(db.DateLayoutDB)
+	if task.Until != "" && date > task.Until {
+		return false, nil
+	}
+
+	if task.Remaining > 0 {
+		passed := r.count(current, next, now.Location())
+		if passed >= task.Remaining {
+			return false, nil
+		}
+		task.Remaining -= passed
+	}
+
+	task.Date, task.Time = date, next.Format(db.TimeLayout)
+	return true, nil
+}
+
+// validateIntraday finishes the validation of a task with an intraday repeat rule started by validateTask.
+// Exceptions and the working day option aren't supported by intraday rules, and an empty time stands for midnight.
+// A task whose date and time are in the past is moved to the first occurrence after now,
+// and a task dated after its until date is rejected.
+func validateIntraday(task *db.Task, now time.Time) error {
+	if len(task.Exceptions) > 0 || task.Workday {
+		return fmt.Errorf("exceptions and workday aren't supported with intraday repeat rules")
+	}
+	if task.Time == "" {
+		task.Time = defaultTaskTime
+	}
+	if _, err := parseIntraday(task.Repeat); err != nil {
+		return err
+	}
+
+	start, err := parseDateTime(task.Date, task.Time)
+	if err != nil {
+		return err
+	}
+	if start.Before(wallClock(now)) {
+		task.Date, task.Time, err = NextDateTime(now, task.Date, task.Time, task.Repeat, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if task.Until != "" && task.Date > task.Until {
+		return fmt.Errorf("task date '%s' is after the until date '%s'", task.Date, task.Until)
+	}
+	return nil
+}
+
+// parseDateTime parses a task's date and time of day; an empty time stands for midnight.
+func parseDateTime(date, tm string) (time.Time, error) {
+	if tm == "" {
+		tm = defaultTaskTime
+	}
+	t, err := time.Parse(db.DateTimeLayout, date+" "+tm)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing the date '%s' and time '%s': %w", date, tm, err)
+	}
+	return t, nil
+}
+
+// minuteOfDay parses a time of day in "HH:MM" format into the number of minutes since midnight.
+func minuteOfDay(value string) (int, error) {
+	t, err := time.Parse(db.TimeLayout, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day '%s': %w", value, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// wallClock returns the wall clock time of the given time in its own timezone to the minute,
+// expressed in the UTC timezone like midnight does.
+func wallClock(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// inZone returns the moment the given wall clock time happens at in the given timezone.
+// A wall clock time skipped by a DST change is moved forward, and a repeated one is taken the first time.
+func inZone(wall time.Time, loc *time.Location) time.Time {
+	y, m, d := wall.Date()
+	return time.Date(y, m, d, wall.Hour(), wall.Minute(), 0, 0, loc)
+}
+
+// later returns the later of the two given times.
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
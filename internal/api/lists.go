@@ -0,0 +1,197 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mascotmascot1/go-todo/internal/db"
+)
+
+const (
+	maxListNameLength = 64
+	listTasksDelete   = "delete"
+	listTasksMove     = "move"
+)
+
+type listsResponse struct {
+	Lists []*db.List `json:"lists"`
+}
+
+type listDeleteResponse struct {
+	Deleted int `json:"deleted"`
+	Moved   int `json:"moved"`
+}
+
+type moveTasksRequest struct {
+	IDs    []string `json:"ids"`
+	ListID string   `json:"list_id"`
+}
+
+type moveTasksResponse struct {
+	Moved int `json:"moved"`
+}
+
+// validateList trims the name of the list and checks that it's neither empty nor longer than maxListNameLength.
+func validateList(list *db.List) error {
+	list.Name = strings.TrimSpace(list.Name)
+	if list.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if utf8.RuneCountInString(list.Name) > maxListNameLength {
+		return fmt.Errorf("name is too long, at most %d characters are allowed", maxListNameLength)
+	}
+	return nil
+}
+
+// listsHandler returns all the lists ordered by name.
+// The response will be in JSON format and will contain the lists under the key "lists",
+// each with the number of its tasks, except the ones in the trash, under "count".
+func (h *Handlers) listsHandler(w http.ResponseWriter, r *http.Request) {
+	lists, err := h.store.Lists(r.Context())
+	if err != nil {
+		h.failWithTaskError(w, "listsHandler", err)
+		return
+	}
+	h.writeJSON(w, listsResponse{Lists: lists}, http.StatusOK)
+}
+
+// listHandler returns the list with the given id.
+// If the list doesn't exist, it will return an error with 404 status code.
+func (h *Handlers) listHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := h.store.GetList(r.Context(), r.FormValue("id"))
+	if err != nil {
+		h.failWithTaskError(w, "listHandler", err)
+		return
+	}
+	h.writeJSON(w, list, http.StatusOK)
+}
+
+// addListHandler adds a new list given in JSON format in the request body and returns its id under the key "id".
+// If the name is empty or too long, it will return an error with 400 status code,
+// and if there's already a list with the same name, it will return an error with 409 status code.
+func (h *Handlers) addListHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "addListHandler"
+
+	var list db.List
+	if !h.readJSON(w, r, caller, &list) {
+		return
+	}
+	if err := validateList(&list); err != nil {
+		h.logger.Printf("%s: validation failed: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	id, err := h.store.AddList(r.Context(), &list)
+	if err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+	h.writeJSON(w, response{ID: strconv.FormatInt(id, 10)}, http.StatusOK)
+}
+
+// updateListHandler renames the list given in JSON format in the request body with its id.
+// If the list doesn't exist, it will return an error with 404 status code, and if another list has the new name,
+// it will return an error with 409 status code.
+// If the name is empty or too long, it will return an error with 400 status code.
+// On success, it will return an empty response with 200 status code.
+func (h *Handlers) updateListHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "updateListHandler"
+
+	var list db.List
+	if !h.readJSON(w, r, caller, &list) {
+		return
+	}
+	if err := validateList(&list); err != nil {
+		h.logger.Printf("%s: validation failed: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.UpdateList(r.Context(), &list); err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+	h.writeJSON(w, struct{}{}, http.StatusOK)
+}
+
+// deleteListHandler deletes the list with the given id. The 'tasks' parameter tells what happens to its tasks:
+// with "delete", the default, they're moved to the trash along with the list; with "move", they're moved
+// to the list with the id given by the 'to' parameter, or out of any list if it's empty.
+// Either way, each task is recorded in the audit log and can be brought back with the undo endpoint.
+// If either list doesn't exist, it will return an error with 404 status code,
+// and if the parameters are invalid or the tasks would be moved to the list being deleted,
+// it will return an error with 400 status code.
+// The response will be in JSON format and will contain the number of the tasks moved to the trash
+// under the key "deleted" and the number of the tasks moved to the other list under "moved".
+func (h *Handlers) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "deleteListHandler"
+
+	id, to := r.FormValue("id"), r.FormValue("to")
+	cascade := true
+	switch mode := r.FormValue("tasks"); mode {
+	case "", listTasksDelete:
+		if to != "" {
+			h.logger.Printf("%s: 'to' parameter given without moving the tasks\n", caller)
+			h.writeJSON(w, response{Error: "'to' requires tasks=move"}, http.StatusBadRequest)
+			return
+		}
+	case listTasksMove:
+		cascade = false
+	default:
+		h.logger.Printf("%s: invalid 'tasks' parameter '%s'\n", caller, mode)
+		h.writeJSON(w, response{Error: "'tasks' must be delete or move"}, http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.store.DeleteList(r.Context(), id, to, cascade)
+	if err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+
+	var resp listDeleteResponse
+	if cascade {
+		resp.Deleted = len(tasks)
+	} else {
+		resp.Moved = len(tasks)
+	}
+	h.writeJSON(w, resp, http.StatusOK)
+}
+
+// moveTasksHandler moves the tasks with the ids listed under "ids" in the JSON request body to the list
+// with the id under "list_id", or out of any list if it's empty, all at once: if the list or one of the tasks
+// doesn't exist, it will return an error with 404 status code and move nothing.
+// Each moved task is recorded in the audit log and can be brought back with the undo endpoint.
+// If the request body is invalid or there are no tasks to move, it will return an error with 400 status code.
+// The response will be in JSON format and will contain the number of the moved tasks under the key "moved",
+// which doesn't count the tasks that were already in the list.
+func (h *Handlers) moveTasksHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "moveTasksHandler"
+
+	var req moveTasksRequest
+	if !h.readJSON(w, r, caller, &req) {
+		return
+	}
+	if len(req.IDs) == 0 {
+		h.logger.Printf("%s: no tasks to move\n", caller)
+		h.writeJSON(w, response{Error: "no tasks to move"}, http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > h.limits.TasksLimit {
+		h.logger.Printf("%s: too many tasks to move: %d\n", caller, len(req.IDs))
+		h.writeJSON(w, response{Error: fmt.Sprintf("at most %d tasks can be moved at once", h.limits.TasksLimit)},
+			http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.store.MoveTasks(r.Context(), req.IDs, req.ListID)
+	if err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+	h.writeJSON(w, moveTasksResponse{Moved: len(tasks)}, http.StatusOK)
+}
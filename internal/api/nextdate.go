@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"slices"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/mascotmascot1/go-todo/internal/db"
+	"github.com/mascotmascot1/go-todo/internal/holidays"
 )
 
 const (
@@ -17,31 +19,58 @@ const (
 	expectedPartsWithMonths = 3
 	lastDayOfMonth          = -1
 	beforeLastDayOfMonth    = -2
+	maxWeekdayOrdinal       = 5
+	maxMonthLookupYears     = 40
+	maxPeriodsInterval      = 100
 )
 
 var (
 	reDay     = regexp.MustCompile(`^d \d{1,3}$`)
-	reYear    = regexp.MustCompile(`^y$`)
-	reWeek    = regexp.MustCompile(`^w \d(,[\d])*$`)
-	reMonth   = regexp.MustCompile(`^m -?\d{1,2}(,-?\d{1,2})*( \d{1,2}(,\d{1,2})*)?$`)
+	reYear    = regexp.MustCompile(`^y( \d{1,3})?$`)
+	reWeek    = regexp.MustCompile(`^w(/\d{1,3})? \d(,[\d])*$`)
+	reMonth   = regexp.MustCompile(`^m(/\d{1,3})? -?\d{1,2}(,-?\d{1,2})*( \d{1,2}(,\d{1,2})*)?$`)
+	reMonthWd = regexp.MustCompile(`^m(/\d{1,3})? w-?\d:\d(,-?\d:\d)*( \d{1,2}(,\d{1,2})*)?$`)
+	reBizDay  = regexp.MustCompile(`^b (\d{1,3}|last)$`)
 	allMonths = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+
+	ErrRepeatEnded = errors.New("repeat rule has no further occurrences")
 )
 
+// monthWeekday is a single entry of the "m w..." repeat rule: the n-th given weekday of a month.
+// A negative ordinal counts from the end of the month.
+type monthWeekday struct {
+	ordinal int
+	weekday time.Weekday
+}
+
 // NextDate computes the next date given a date and a repeat rule.
 // The repeat rule can be one of the following formats:
 //
 // - "d <number>"           — daily repeat every <number> days
-// - "y"                    — yearly repeat
-// - "w <num1,num2,...>"    — weekly repeat on specified weekdays (1=Monday, 7=Sunday)
-// - "m <day1,day2,...> <month1,month2,...>" — monthly repeat on specified days and months;
+// - "y [number]"           — yearly repeat, every [number] years if given
+// - "w[/n] <num1,num2,...>" — weekly repeat on specified weekdays (1=Monday, 7=Sunday), every n weeks if given
+// - "m[/n] <day1,day2,...> <month1,month2,...>" — monthly repeat on specified days and months, every n months if given;
 //                                             days can be 1..31 or negative (-1 for last day, -2 for second to last, etc.),
 //                                             months can be 1..12
+// - "m[/n] w<n>:<weekday>,... <month1,month2,...>" — monthly repeat on the n-th weekday of specified months;
+//                                                n can be 1..5 or negative (-1 for the last one, etc.),
+//                                                weekday can be 1..7 (1=Monday, 7=Sunday), months are optional
+// - "b <number>"           — repeat every <number> business days
+// - "b last"               — repeat on the last business day of every month
+// - "FREQ=...;INTERVAL=...;..." — iCalendar RRULE (RFC 5545) with FREQ, INTERVAL, COUNT, UNTIL, WKST,
+//                                 BYDAY, BYMONTHDAY, BYMONTH and BYSETPOS parts; the initial date is used as DTSTART
 //
+// Intervals of the "w", "m" and "y" rules are counted from the week, month or year of the initial date,
+// so the cadence stays the same however late the task is marked as done.
+// Business days are the days that are neither weekends nor holidays of the given calendar;
+// a nil calendar has no holidays.
+// If COUNT or UNTIL of an RRULE leave no occurrence after the initial date, it returns ErrRepeatEnded.
+// Intraday "h" and "min" rules are rejected, since they need a time of day; see NextDateTime.
 // If the repeat rule is empty, it returns a 400 error.
 // If the initial date is invalid, it returns a 400 error.
 // If the server fails to compute the next date, it returns a 400 error.
 // The response is in plain text format and contains the next date in "YYYY-MM-DD".
-func NextDate(now time.Time, dStart string, repeat string) (string, error) {
+func NextDate(now time.Time, dStart string, repeat string, cal *holidays.Calendar) (string, error) {
 	repeat, dStart = strings.TrimSpace(repeat), strings.TrimSpace(dStart)
 	if repeat == "" {
 		return "", fmt.Errorf("repeat rule is empty")
@@ -53,11 +82,14 @@ func NextDate(now time.Time, dStart string, repeat string) (string, error) {
 	now = midnight(now)
 
 	switch {
+	case isRRule(repeat):
+		return nextRRule(now, startDate, repeat)
+
 	case reDay.MatchString(repeat):
 		return nextDaily(now, startDate, repeat)
 
 	case reYear.MatchString(repeat):
-		return nextYearly(now, startDate)
+		return nextYearly(now, startDate, repeat)
 
 	case reWeek.MatchString(repeat):
 		return nextWeekly(now, startDate, repeat)
@@ -65,11 +97,61 @@ func NextDate(now time.Time, dStart string, repeat string) (string, error) {
 	case reMonth.MatchString(repeat):
 		return nextMonthly(now, startDate, repeat)
 
+	case reMonthWd.MatchString(repeat):
+		return nextMonthlyWeekday(now, startDate, repeat)
+
+	case reBizDay.MatchString(repeat):
+		return nextBusinessDay(now, startDate, repeat, cal)
+
+	case isIntraday(repeat):
+		return "", fmt.Errorf("repeat rule '%s' needs a time of day, use NextDateTime", repeat)
+
 	default:
 		return "", fmt.Errorf("unsupported interval format '%s'", repeat)
 	}
 }
 
+// NextDateExcept computes the next date like NextDate, but skips the given excluded dates.
+// Excluded dates are expected in the "YYYYMMDD" format; dates that aren't occurrences of the rule are ignored.
+func NextDateExcept(now time.Time, dStart string, repeat string, excluded []string, cal *holidays.Calendar) (string, error) {
+	// Every excluded date can be hit at most once, so the loop is bounded by their number.
+	for range len(excluded) + 1 {
+		nextDate, err := NextDate(now, dStart, repeat, cal)
+		if err != nil {
+			return "", err
+		}
+		if !slices.Contains(excluded, nextDate) {
+			return nextDate, nil
+		}
+
+		now, err = time.Parse(db.DateLayoutDB, nextDate)
+		if err != nil {
+			return "", fmt.Errorf("error parsing the next date '%s': %w", nextDate, err)
+		}
+	}
+	return "", fmt.Errorf("failed to find a date that isn't excluded")
+}
+
+// countOccurrences returns the number of occurrences of the repeat rule from dStart, which counts as the first one,
+// up to but not including dEnd. It walks the series with NextDate, so the cost is linear in the number of occurrences.
+func countOccurrences(dStart, dEnd, repeat string, cal *holidays.Calendar) (int, error) {
+	count := 0
+	for date := dStart; date < dEnd; count++ {
+		current, err := time.Parse(db.DateLayoutDB, date)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing the date '%s': %w", date, err)
+		}
+		date, err = NextDate(current, dStart, repeat, cal)
+		if errors.Is(err, ErrRepeatEnded) {
+			return count + 1, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
 // nextDaily computes the next date given a date and a daily repeat rule.
 // The repeat rule is of the format "d <number>" where <number> is the number of days to repeat.
 // The function will return an error if the repeat rule is invalid or if the server fails to compute the next date.
@@ -94,12 +176,24 @@ func nextDaily(now, startDate time.Time, repeat string) (string, error) {
 }
 
 // nextYearly computes the next date given a date and a yearly repeat rule.
-// The repeat rule is of the format "y".
+// The repeat rule is of the format "y [number]" where the optional <number> is the number of years to repeat.
 // The function will return an error if the repeat rule is invalid or if the server fails to compute the next date.
 // The response is in plain text format and contains the next date in "YYYY-MM-DD".
-func nextYearly(now, startDate time.Time) (string, error) {
+func nextYearly(now, startDate time.Time, repeat string) (string, error) {
+	years := 1
+	if parts := strings.Split(repeat, " "); len(parts) == 2 {
+		var err error
+		years, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return "", fmt.Errorf("invalid year value: %w", err)
+		}
+		if years <= 0 || years > maxPeriodsInterval {
+			return "", fmt.Errorf("invalid year interval '%d'", years)
+		}
+	}
+
 	for {
-		startDate = startDate.AddDate(1, 0, 0)
+		startDate = startDate.AddDate(years, 0, 0)
 		if afterNow(now, startDate) {
 			break
 		}
@@ -108,26 +202,21 @@ func nextYearly(now, startDate time.Time) (string, error) {
 }
 
 // nextWeekly computes the next date given a date and a weekly repeat rule.
-// The repeat rule is of the format "w <number>,<number>,..."
-// where <number> is the day of the week to repeat (1 for Monday, 2 for Tuesday, etc.).
+// The repeat rule is of the format "w[/n] <number>,<number>,..."
+// where <number> is the day of the week to repeat (1 for Monday, 2 for Tuesday, etc.)
+// and the optional n is the number of weeks between repeats, counted from the week of the initial date.
 // The function will return an error if the repeat rule is invalid or if the server fails to compute the next date.
 // The response is in plain text format and contains the next date in "YYYY-MM-DD".
 func nextWeekly(now, startDate time.Time, repeat string) (string, error) {
 	parts := strings.Split(repeat, " ")
-	weekDaysStr := strings.Split(parts[1], ",")
-
-	weekDaysInt := make([]int, 0, len(weekDaysStr))
-	for _, wd := range weekDaysStr {
-		wdNum, err := strconv.Atoi(wd)
-		if err != nil {
-			return "", fmt.Errorf("invalid weekday value: %w", err)
-		}
-		if wdNum <= 0 || wdNum > 7 {
-			return "", fmt.Errorf("invalid weekday interval: %w", err)
-		}
-		weekDaysInt = append(weekDaysInt, wdNum)
+	interval, err := parseInterval(parts[0])
+	if err != nil {
+		return "", err
+	}
+	weekDaysInt, err := parseWeekdays(parts[1])
+	if err != nil {
+		return "", err
 	}
-	weekDaysInt = sortUniqueInts(weekDaysInt)
 
 	baseTime := computeBaseTime(now, startDate)
 	currentWeekDay := int(baseTime.Weekday())
@@ -147,51 +236,48 @@ func nextWeekly(now, startDate time.Time, repeat string) (string, error) {
 	}
 
 	newDate := baseTime.AddDate(0, 0, daysToAdd)
+
+	// Moving on to the first listed weekday of the next week that keeps the cadence.
+	if week := daysBetween(mondayOf(startDate), mondayOf(newDate)) / 7; week%interval != 0 {
+		week += interval - week%interval
+		newDate = mondayOf(startDate).AddDate(0, 0, week*7+weekDaysInt[0]-1)
+	}
 	return newDate.Format(db.DateLayoutDB), nil
 }
 
 // nextMonthly computes the next date given a date and a monthly repeat rule.
-// The repeat rule is of the format "m <day1,day2,...> <month1,month2,...>" where <day1,day2,...> is the comma-separated list of days in the month to repeat, and <month1,month2,...> is the comma-separated list of months to repeat.
+// The repeat rule is of the format "m[/n] <day1,day2,...> <month1,month2,...>" where <day1,day2,...> is the comma-separated list of days in the month to repeat, and <month1,month2,...> is the comma-separated list of months to repeat.
 // If the <month1,month2,...> part is not provided, the function will consider all months from 1 to 12.
 // If the <day1,day2,...> part is not provided, the function will consider all days in the month from 1 to 31.
+// The optional n is the number of months between repeats, counted from the month of the initial date.
 // If the repeat rule is invalid or if the server fails to compute the next date, the function will return an error.
 // The response is in plain text format and contains the next date in "YYYY-MM-DD".
 
 func nextMonthly(now, startDate time.Time, repeat string) (string, error) {
 	parts := strings.Split(repeat, " ")
-	monthDaysStr := strings.Split(parts[1], ",")
-
-	monthDaysInt := make([]int, 0, len(monthDaysStr))
-	for _, md := range monthDaysStr {
-		mdNum, err := strconv.Atoi(md)
-		if err != nil {
-			return "", fmt.Errorf("invalid monthday value: %w", err)
-		}
-		if (mdNum != lastDayOfMonth && mdNum != beforeLastDayOfMonth) && (mdNum <= 0 || mdNum > 31) {
-			return "", fmt.Errorf("invalid monthday interval '%d'", mdNum)
-		}
-		monthDaysInt = append(monthDaysInt, mdNum)
+	interval, err := parseInterval(parts[0])
+	if err != nil {
+		return "", err
+	}
+	monthDaysInt, err := parseMonthDays(parts[1])
+	if err != nil {
+		return "", err
 	}
-	monthDaysInt = sortUniqueInts(monthDaysInt)
 
-	monthsInt := make([]int, 0, 12)
-	if len(parts) == expectedPartsWithMonths {
-		monthsStr := strings.Split(parts[2], ",")
+	monthsInt, err := parseMonths(parts)
+	if err != nil {
+		return "", err
+	}
 
-		for _, m := range monthsStr {
-			mNum, err := strconv.Atoi(m)
-			if err != nil {
-				return "", fmt.Errorf("invalid monthd value: %w", err)
-			}
-			if mNum <= 0 || mNum > 12 {
-				return "", fmt.Errorf("invalid month interval '%d'", mNum)
-			}
-			monthsInt = append(monthsInt, mNum)
+	if interval > 1 {
+		baseTime := computeBaseTime(now, startDate)
+		newDate, ok := nextMonthDate(baseTime, startDate, interval, monthsInt, func(y, m int) []int {
+			return resolveDays(monthDaysInt, y, m, baseTime.Location())
+		})
+		if !ok {
+			return "", fmt.Errorf("invalid repeat rule: there aren't these days in submitted months '%s'", repeat)
 		}
-		monthsInt = sortUniqueInts(monthsInt)
-	}
-	if len(monthsInt) == 0 {
-		monthsInt = allMonths
+		return newDate.Format(db.DateLayoutDB), nil
 	}
 
 	var (
@@ -241,6 +327,207 @@ func nextMonthly(now, startDate time.Time, repeat string) (string, error) {
 	return "", fmt.Errorf("invalid repeat rule: there aren't these days in submitted months '%s'", repeat)
 }
 
+// nextMonthlyWeekday computes the next date given a date and a monthly weekday repeat rule.
+// The repeat rule is of the format "m[/n] w<n>:<weekday>,<n>:<weekday>,... <month1,month2,...>",
+// e.g. "m w2:2,-1:5" means the second Tuesday and the last Friday of every month.
+// If the <month1,month2,...> part is not provided, the function will consider all months from 1 to 12.
+// The optional n is the number of months between repeats, counted from the month of the initial date.
+// If the repeat rule is invalid or if the server fails to compute the next date, the function will return an error.
+// The response is in plain text format and contains the next date in "YYYY-MM-DD".
+func nextMonthlyWeekday(now, startDate time.Time, repeat string) (string, error) {
+	parts := strings.Split(repeat, " ")
+	interval, err := parseInterval(parts[0])
+	if err != nil {
+		return "", err
+	}
+	entries, err := parseMonthWeekdays(parts[1])
+	if err != nil {
+		return "", err
+	}
+
+	monthsInt, err := parseMonths(parts)
+	if err != nil {
+		return "", err
+	}
+
+	baseTime := computeBaseTime(now, startDate)
+	newDate, ok := nextMonthDate(baseTime, startDate, interval, monthsInt, func(y, m int) []int {
+		monthDays := make([]int, 0, len(entries))
+		for _, e := range entries {
+			if md, ok := nthWeekdayOfMonth(y, m, e, baseTime.Location()); ok {
+				monthDays = append(monthDays, md)
+			}
+		}
+		return sortUniqueInts(monthDays)
+	})
+	if !ok {
+		return "", fmt.Errorf("invalid repeat rule: there aren't these weekdays in submitted months '%s'", repeat)
+	}
+	return newDate.Format(db.DateLayoutDB), nil
+}
+
+// nextMonthDate walks the months from the month of baseTime on and returns the first date after baseTime.
+// Only the submitted months that are a multiple of interval months away from the month of startDate are considered.
+// The days of each month are given by resolve in ascending order; days missing from the month are skipped.
+// It returns false if no date is found within maxMonthLookupYears of repeats.
+func nextMonthDate(baseTime, startDate time.Time, interval int, monthsInt []int, resolve func(y, m int) []int) (time.Time, bool) {
+	firstMonth := time.Date(baseTime.Year(), baseTime.Month(), 1, 0, 0, 0, 0, baseTime.Location())
+
+	for i := 0; i <= maxMonthLookupYears*12*interval; i++ {
+		month := firstMonth.AddDate(0, i, 0)
+		y, m := month.Year(), int(month.Month())
+		if !slices.Contains(monthsInt, m) || monthsBetween(startDate, month)%interval != 0 {
+			continue
+		}
+
+		maxMonthDay := computeLastMonthDay(y, m, baseTime.Location())
+		for _, md := range resolve(y, m) {
+			if md > maxMonthDay {
+				break
+			}
+			newDate := time.Date(y, time.Month(m), md, 0, 0, 0, 0, baseTime.Location())
+			if afterNow(baseTime, newDate) {
+				return newDate, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseInterval parses the optional interval of the "w" and "m" rules from the rule name,
+// e.g. "w/2" gives 2 and a bare "w" gives 1.
+func parseInterval(name string) (int, error) {
+	_, intervalStr, found := strings.Cut(name, "/")
+	if !found {
+		return 1, nil
+	}
+
+	interval, err := strconv.Atoi(intervalStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval value: %w", err)
+	}
+	if interval <= 0 || interval > maxPeriodsInterval {
+		return 0, fmt.Errorf("invalid interval '%d'", interval)
+	}
+	return interval, nil
+}
+
+// parseWeekdays parses the comma-separated list of weekdays of the weekly repeat rule (1=Monday, 7=Sunday).
+// It returns the weekdays sorted in ascending order with no duplicates.
+func parseWeekdays(list string) ([]int, error) {
+	weekDaysStr := strings.Split(list, ",")
+
+	weekDaysInt := make([]int, 0, len(weekDaysStr))
+	for _, wd := range weekDaysStr {
+		wdNum, err := strconv.Atoi(wd)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weekday value: %w", err)
+		}
+		if wdNum <= 0 || wdNum > 7 {
+			return nil, fmt.Errorf("invalid weekday interval '%d'", wdNum)
+		}
+		weekDaysInt = append(weekDaysInt, wdNum)
+	}
+	return sortUniqueInts(weekDaysInt), nil
+}
+
+// parseMonthDays parses the comma-separated list of days of the monthly repeat rule.
+// Days can be 1..31, -1 for the last day of the month or -2 for the second to last one.
+// It returns the days sorted in ascending order with no duplicates.
+func parseMonthDays(list string) ([]int, error) {
+	monthDaysStr := strings.Split(list, ",")
+
+	monthDaysInt := make([]int, 0, len(monthDaysStr))
+	for _, md := range monthDaysStr {
+		mdNum, err := strconv.Atoi(md)
+		if err != nil {
+			return nil, fmt.Errorf("invalid monthday value: %w", err)
+		}
+		if (mdNum != lastDayOfMonth && mdNum != beforeLastDayOfMonth) && (mdNum <= 0 || mdNum > 31) {
+			return nil, fmt.Errorf("invalid monthday interval '%d'", mdNum)
+		}
+		monthDaysInt = append(monthDaysInt, mdNum)
+	}
+	return sortUniqueInts(monthDaysInt), nil
+}
+
+// parseMonthWeekdays parses the "w<n>:<weekday>,<n>:<weekday>,..." list of the monthly weekday repeat rule.
+func parseMonthWeekdays(list string) ([]monthWeekday, error) {
+	entriesStr := strings.Split(strings.TrimPrefix(list, "w"), ",")
+
+	entries := make([]monthWeekday, 0, len(entriesStr))
+	for _, e := range entriesStr {
+		ordStr, wdStr, _ := strings.Cut(e, ":")
+
+		ord, err := strconv.Atoi(ordStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weekday ordinal value: %w", err)
+		}
+		if ord == 0 || ord < -maxWeekdayOrdinal || ord > maxWeekdayOrdinal {
+			return nil, fmt.Errorf("invalid weekday ordinal interval '%d'", ord)
+		}
+
+		wdNum, err := strconv.Atoi(wdStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weekday value: %w", err)
+		}
+		if wdNum <= 0 || wdNum > 7 {
+			return nil, fmt.Errorf("invalid weekday interval '%d'", wdNum)
+		}
+		entries = append(entries, monthWeekday{ordinal: ord, weekday: time.Weekday(wdNum % sundayNum)})
+	}
+	return entries, nil
+}
+
+// parseMonths parses the optional comma-separated list of months of the monthly repeat rules.
+// If the list is not provided, it returns all months from 1 to 12.
+// It returns the months sorted in ascending order with no duplicates.
+func parseMonths(parts []string) ([]int, error) {
+	monthsInt := make([]int, 0, 12)
+	if len(parts) == expectedPartsWithMonths {
+		monthsStr := strings.Split(parts[2], ",")
+
+		for _, m := range monthsStr {
+			mNum, err := strconv.Atoi(m)
+			if err != nil {
+				return nil, fmt.Errorf("invalid monthd value: %w", err)
+			}
+			if mNum <= 0 || mNum > 12 {
+				return nil, fmt.Errorf("invalid month interval '%d'", mNum)
+			}
+			monthsInt = append(monthsInt, mNum)
+		}
+		monthsInt = sortUniqueInts(monthsInt)
+	}
+	if len(monthsInt) == 0 {
+		monthsInt = allMonths
+	}
+	return monthsInt, nil
+}
+
+// nthWeekdayOfMonth returns the day of the month of the n-th given weekday of the given month and year.
+// A negative ordinal counts from the end of the month, so -1 is the last such weekday.
+// It returns false if the month doesn't have that many such weekdays.
+func nthWeekdayOfMonth(year, month int, e monthWeekday, loc *time.Location) (int, bool) {
+	lastDay := computeLastMonthDay(year, month, loc)
+
+	var day int
+	if e.ordinal > 0 {
+		first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, loc)
+		offset := (int(e.weekday) - int(first.Weekday()) + 7) % 7
+		day = 1 + offset + (e.ordinal-1)*7
+	} else {
+		last := time.Date(year, time.Month(month), lastDay, 0, 0, 0, 0, loc)
+		offset := (int(last.Weekday()) - int(e.weekday) + 7) % 7
+		day = lastDay - offset + (e.ordinal+1)*7
+	}
+
+	if day < 1 || day > lastDay {
+		return 0, false
+	}
+	return day, true
+}
+
 // afterNow checks if the given date is after the given now time.
 // It returns true if the date is after now, and false otherwise.
 func afterNow(now, date time.Time) bool {
@@ -294,9 +581,25 @@ func resolveDays(monthDaysInt []int, y, m int, loc *time.Location) []int {
 	return sortUniqueInts(resolved)
 }
 
+// mondayOf returns the Monday of the week the given date belongs to.
+func mondayOf(date time.Time) time.Time {
+	return date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+}
+
+// daysBetween returns the number of days from one date to another. Both dates are expected at midnight.
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// monthsBetween returns the number of months from the month of one date to the month of another.
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
+
 // midnight returns a new time that represents midnight of the given time.
 // It takes the given time as an argument and returns a new time with the same year, month and day, but with the hour, minute, second and timezone offset set to zero.
 // The returned time is in the UTC timezone.
+// The date is taken in the timezone of the given time, so "today" depends on the timezone the caller passes the time in.
 func midnight(t time.Time) time.Time {
 	y, m, d := t.Date()
 	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
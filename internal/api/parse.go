@@ -0,0 +1,554 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mascotmascot1/go-todo/internal/db"
+	"github.com/mascotmascot1/go-todo/internal/holidays"
+)
+
+const (
+	enWeekdayName   = `(?:monday|tuesday|wednesday|thursday|friday|saturday|sunday|mon|tues?|wed|thu(?:rs?)?|fri|sat|sun)`
+	enWeekdayList   = enWeekdayName + `s?(?:(?:\s*,\s*|\s*,?\s+and\s+|\s*&\s*)` + enWeekdayName + `s?)*`
+	enWeekdaysPl    = `(?:monday|tuesday|wednesday|thursday|friday|saturday|sunday)s`
+	enWeekdayPlList = enWeekdaysPl + `(?:(?:\s*,\s*|\s*,?\s+and\s+|\s*&\s*)` + enWeekdaysPl + `)*`
+	ruWeekdayName   = `(?:понедельник|вторник|сред|четверг|пятниц|суббот|воскресень)[а-я]*`
+	ruWeekdayList   = ruWeekdayName + `(?:(?:\s*,\s*|\s+и\s+)` + ruWeekdayName + `)*`
+	enMonthName     = `(?:january|february|march|april|may|june|july|august|september|october|november|december|` +
+		`jan|feb|mar|apr|jun|jul|aug|sept?|oct|nov|dec)`
+	ruMonthName = `(?:(?:январ|феврал|апрел|июн|июл|сентябр|октябр|ноябр|декабр)я|(?:март|август)а|мая)`
+	enOrdSuffix = `(?:st|nd|rd|th)?`
+	datePrefix  = `(?:(?:starting|starts|from|beginning|on|начиная\s+с|начиная\s+со|с|со)\s+)?`
+)
+
+var (
+	reWeekdayName = regexp.MustCompile(`(?i)` + enWeekdayName + `|` + ruWeekdayName)
+
+	// Month and weekday names are recognized by their first letters, in English and in Russian.
+	enMonthStems   = [...]string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	ruMonthStems   = [...]string{"янв", "фев", "мар", "апр", "мая", "июн", "июл", "авг", "сен", "окт", "ноя", "дек"}
+	enWeekdayStems = [...]string{"mo", "tu", "we", "th", "fr", "sa", "su"}
+	ruWeekdayStems = [...]string{"пон", "вт", "ср", "чет", "пят", "суб", "вос"}
+
+	// titleFillers are the words left dangling at the ends of a title once the phrases around them are cut out.
+	titleFillers = []string{"on", "at", "and", "every", "starting", "from", "в", "во", "с", "со", "и", "начиная", "каждый"}
+)
+
+// quickPhrase is a phrase of a quick-add text: a pattern and the function recording what it means.
+// The function gets the submatches of the pattern, the whole match first.
+type quickPhrase struct {
+	re    *regexp.Regexp
+	apply func(q *quickTask, m []string) error
+}
+
+// quickTask collects the meaning of the phrases found in a quick-add text.
+// The repeat rule is built once the start date is known, since monthly rules depend on it;
+// snap means the start date must be moved to the first occurrence of the rule.
+type quickTask struct {
+	today  time.Time
+	date   time.Time
+	time   string
+	repeat func(start time.Time) string
+	snap   bool
+}
+
+var (
+	repeatPhrases = []quickPhrase{
+		phrase(`(?:on\s+)?(?:the\s+)?last\s+(?:business|working)\s+day\s+of\s+(?:the|every|each)\s+month|`+
+			`(?:в\s+)?последний\s+рабочий\s+день\s+(?:каждого\s+)?месяца`, fixedRepeat("b last", true)),
+		phrase(`(?:every|each)\s+(?:(\d+)\s+)?(?:business|working)\s+days?|`+
+			`кажд(?:ый|ые)\s+(?:(\d+)\s+)?рабоч(?:ий|их)\s+(?:день|дня|дней)`, businessDaysRepeat),
+		phrase(`(?:on\s+)?(?:the\s+)?last\s+day\s+of\s+(?:the|every|each)\s+month|`+
+			`(?:в\s+)?последн(?:ий|ее)\s+(?:день|число)\s+(?:каждого\s+)?месяца`, fixedRepeat("m -1", true)),
+		phrase(`(?:on\s+)?(?:the\s+)?(\d{1,2})`+enOrdSuffix+`\s+(?:day\s+)?of\s+(?:the|every|each)\s+month|`+
+			`(?:every|each)\s+month\s+on\s+the\s+(\d{1,2})`+enOrdSuffix+`|`+
+			`(\d{1,2})(?:-?го)?\s+числа\s+(?:каждого\s+)?месяца|`+
+			`каждое\s+(\d{1,2})(?:-?е)?\s+число`, monthDayRepeat),
+		phrase(`(?:every|each)\s+weekday|on\s+weekdays|(?:каждый\s+)?будний\s+день|по\s+будням`,
+			fixedRepeat("w 1,2,3,4,5", true)),
+		phrase(`(?:every|each)\s+weekend|on\s+weekends|каждые\s+выходные|по\s+выходным`,
+			fixedRepeat("w 6,7", true)),
+		phrase(`(?:every|each)\s+(\d+|other)\s+weeks?\s+on\s+(`+enWeekdayList+`)|`+
+			`(?:каждые|раз\s+в)\s+(\d+)\s+недел[иь]\s+(?:в|во|по)\s+(`+ruWeekdayList+`)`, weeksOnRepeat),
+		phrase(`(?:every|each)\s+(`+enWeekdayList+`)|on\s+(`+enWeekdayPlList+`)|`+
+			`(?:кажд(?:ый|ую|ое)|по)\s+(`+ruWeekdayList+`)`, weekdaysRepeat),
+		phrase(`(?:every|each)\s+(?:(\d+|other)\s+)?(minute|min|hour|day|week|fortnight|month|year)s?|`+
+			`(hourly|daily|weekly|fortnightly|monthly|yearly|annually)|`+
+			`(?:кажд(?:ый|ую|ое|ые)|раз\s+в)\s+(?:(\d+)\s+)?`+
+			`(минут[уы]?|час(?:а|ов)?|день|дня|дней|недел[юиь]|месяц(?:а|ев)?|год(?:а)?|лет)|`+
+			`(ежеминутно|ежечасно|ежедневно|еженедельно|ежемесячно|ежегодно)`, intervalRepeat),
+	}
+
+	timePhrases = []quickPhrase{
+		phrase(`(?:(?:at|в)\s+|@\s*)?(\d{1,2}):(\d{2})\s*(am|pm|a\.m\.|p\.m\.)?`, clockTime),
+		phrase(`(?:at\s+|@\s*)?(\d{1,2})\s*(am|pm|a\.m\.|p\.m\.)`, clockTime12),
+		phrase(`в\s+(\d{1,2})\s+(утра|дня|вечера|ночи)`, clockTime12),
+		phrase(`в\s+(\d{1,2})\s+час(?:а|ов)?|at\s+(\d{1,2})`, hourTime),
+		phrase(`(?:at\s+)?(noon|midday|midnight)|в\s+(полдень|полночь)`, namedTime),
+	}
+
+	datePhrases = []quickPhrase{
+		phrase(datePrefix+`(\d{4})-(\d{2})-(\d{2})`, isoDate),
+		phrase(datePrefix+`(\d{1,2})\.(\d{1,2})(?:\.(\d{4}))?`, dottedDate),
+		phrase(datePrefix+`(?:the\s+day\s+after\s+tomorrow|day\s+after\s+tomorrow|послезавтра)`, daysFromToday(2)),
+		phrase(datePrefix+`(?:tomorrow|завтра|завтрашнего\s+дня)`, daysFromToday(1)),
+		phrase(datePrefix+`(?:today|tonight|сегодня|сегодняшнего\s+дня)`, daysFromToday(0)),
+		phrase(`in\s+(\d+|a|an|one)\s+(day|week|month)s?|`+
+			`через\s+(?:(\d+)\s+)?(день|дня|дней|неделю|недели|недель|месяц|месяца|месяцев)`, dateIn),
+		phrase(`(?:(?:starting|from|on)\s+)?(?:next\s+|this\s+)?(`+enWeekdayName+`)|`+
+			`(?:начиная\s+)?(?:с|со|в|во)\s+(?:следующ[а-я]*\s+|эт[а-я]*\s+)?(`+ruWeekdayName+`)`, weekdayDate),
+		phrase(datePrefix+`(?:the\s+)?(\d{1,2})`+enOrdSuffix+`\s+(?:of\s+)?(`+enMonthName+`)(?:,?\s+(\d{4}))?`, dayMonthDate),
+		phrase(datePrefix+`(`+enMonthName+`)\s+(\d{1,2})`+enOrdSuffix+`(?:,?\s+(\d{4}))?`, monthDayDate),
+		phrase(datePrefix+`(\d{1,2})(?:-?го)?\s+(`+ruMonthName+`)(?:\s+(\d{4})(?:\s+года)?)?`, dayMonthDate),
+	}
+)
+
+// ParseTask parses a quick-add text such as "Pay rent on the last day of every month"
+// or "планёрка по будням начиная с завтра" into a task with the title, date, time and repeat rule filled in.
+// English and Russian phrases are recognized:
+//
+// - repeat rules: "every day", "every 2 weeks", "monthly", "every weekday", "every Mon and Thu", "every 3 hours",
+// "on the 15th of every month", "on the last day of every month", "every business day", "каждый день",
+// "по понедельникам и пятницам", "15-го числа каждого месяца", "в последний рабочий день месяца" and the like;
+// - dates: "today", "tomorrow", "in 3 days", "next Friday", "October 20", "2026-10-20", "20.10.2026", "завтра",
+// "через неделю", "в пятницу", "20 октября", optionally preceded by "starting", "from", "on", "начиная с" or "с";
+// - times of day: "at 9:30", "at 5pm", "at noon", "в 9:30", "в 7 вечера", "в полдень".
+//
+// Whatever isn't recognized makes up the title. The date is today if no date is given;
+// a repeating task is dated on the first occurrence of its rule that isn't before that date.
+// A day and month without a year stand for their first date that isn't in the past.
+// It returns an error if the text is empty or one of its dates or times doesn't exist.
+// The task isn't validated, see validateTask.
+func ParseTask(text string, now time.Time, cal *holidays.Calendar) (*db.Task, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("text is empty")
+	}
+
+	q := &quickTask{today: midnight(now)}
+	for _, phrases := range [][]quickPhrase{repeatPhrases, timePhrases, datePhrases} {
+		// Only the first phrase of each kind counts; the rest stay in the title.
+		for _, p := range phrases {
+			loc := p.re.FindStringSubmatchIndex(text)
+			if loc == nil {
+				continue
+			}
+			m := make([]string, len(loc)/2)
+			for i := range m {
+				if loc[2*i] >= 0 {
+					m[i] = text[loc[2*i]:loc[2*i+1]]
+				}
+			}
+			if err := p.apply(q, m); err != nil {
+				return nil, err
+			}
+			text = text[:loc[0]] + " " + text[loc[1]:]
+			break
+		}
+	}
+
+	start := q.today
+	if !q.date.IsZero() {
+		start = q.date
+	}
+
+	task := &db.Task{Title: quickTitle(text), Time: q.time}
+	if q.repeat != nil {
+		task.Repeat = q.repeat(start)
+	}
+	if q.snap {
+		// The interval is dropped, as it's counted from the date the task ends up with anyway.
+		unit, days, _ := strings.Cut(task.Repeat, " ")
+		unit, _, _ = strings.Cut(unit, "/")
+		before := start.AddDate(0, 0, -1)
+		date, err := NextDate(before, before.Format(db.DateLayoutDB), unit+" "+days, cal)
+		if err != nil {
+			return nil, err
+		}
+		task.Date = date
+	} else {
+		task.Date = start.Format(db.DateLayoutDB)
+	}
+	return task, nil
+}
+
+// mergeParsed fills the title, date, time and repeat rule of the task that weren't given with the parsed ones.
+func mergeParsed(task, parsed *db.Task) {
+	if task.Title == "" {
+		task.Title = parsed.Title
+	}
+	if task.Date == "" {
+		task.Date = parsed.Date
+	}
+	if task.Time == "" {
+		task.Time = parsed.Time
+	}
+	if task.Repeat == "" {
+		task.Repeat = parsed.Repeat
+	}
+}
+
+// phrase compiles the pattern of a quick-add phrase. The phrase must be a whole word or words of the text,
+// which is checked by hand, since \b of Go regular expressions only knows ASCII letters.
+func phrase(pattern string, apply func(q *quickTask, m []string) error) quickPhrase {
+	re := regexp.MustCompile(`(?i)(?:^|[\s,;(])(?:` + pattern + `)(?:$|[\s,;.!?)])`)
+	return quickPhrase{re: re, apply: apply}
+}
+
+// quickTitle cleans up what's left of a quick-add text once the phrases are cut out.
+func quickTitle(text string) string {
+	words := strings.Fields(text)
+	isFiller := func(word string) bool {
+		return slices.Contains(titleFillers, strings.ToLower(strings.Trim(word, ",;:-—")))
+	}
+	for len(words) > 0 && isFiller(words[len(words)-1]) {
+		words = words[:len(words)-1]
+	}
+	for len(words) > 0 && isFiller(words[0]) {
+		words = words[1:]
+	}
+	return strings.Trim(strings.Join(words, " "), " ,;:-—")
+}
+
+// firstGroup returns the first non-empty submatch, the whole match excluded.
+func firstGroup(m []string) string {
+	for _, s := range m[1:] {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// fixedRepeat records a repeat rule that doesn't depend on the phrase or the start date.
+func fixedRepeat(repeat string, snap bool) func(q *quickTask, m []string) error {
+	return func(q *quickTask, _ []string) error {
+		q.repeat = func(time.Time) string { return repeat }
+		q.snap = snap
+		return nil
+	}
+}
+
+// businessDaysRepeat records a "b <number>" rule; the number of days is optional.
+func businessDaysRepeat(q *quickTask, m []string) error {
+	n, err := quickInterval(firstGroup(m))
+	if err != nil {
+		return err
+	}
+	return fixedRepeat(fmt.Sprintf("b %d", n), false)(q, m)
+}
+
+// monthDayRepeat records a "m <day>" rule for the day of the month in the phrase.
+func monthDayRepeat(q *quickTask, m []string) error {
+	day, err := strconv.Atoi(firstGroup(m))
+	if err != nil || day < 1 || day > 31 {
+		return fmt.Errorf("invalid day of the month in '%s'", strings.TrimSpace(m[0]))
+	}
+	return fixedRepeat(fmt.Sprintf("m %d", day), true)(q, m)
+}
+
+// weeksOnRepeat records a "w/<n> <weekdays>" rule: the interval goes first, the weekdays second.
+func weeksOnRepeat(q *quickTask, m []string) error {
+	n, list := m[1], m[2]
+	if n == "" {
+		n, list = m[3], m[4]
+	}
+	weeks, err := quickInterval(n)
+	if err != nil {
+		return err
+	}
+	rule := "w"
+	if weeks > 1 {
+		rule = fmt.Sprintf("w/%d", weeks)
+	}
+	return fixedRepeat(rule+" "+quickWeekdays(list), true)(q, m)
+}
+
+// weekdaysRepeat records a "w <weekdays>" rule for the listed weekdays.
+func weekdaysRepeat(q *quickTask, m []string) error {
+	return fixedRepeat("w "+quickWeekdays(firstGroup(m)), true)(q, m)
+}
+
+// intervalRepeat records a rule repeating every given number of minutes, hours, days, weeks, months or years.
+// Weeks are counted in days, and a monthly rule repeats on the day of the month of the start date.
+func intervalRepeat(q *quickTask, m []string) error {
+	number, word := m[1], m[2]
+	switch {
+	case m[3] != "":
+		word = m[3]
+	case m[5] != "":
+		number, word = m[4], m[5]
+	case m[6] != "":
+		word = m[6]
+	}
+	n, err := quickInterval(number)
+	if err != nil {
+		return err
+	}
+
+	word = strings.TrimPrefix(strings.ToLower(word), "еже")
+	if strings.HasPrefix(word, "fortnight") {
+		word, n = "week", n*2
+	}
+	unit, ok := quickUnit(word)
+	if !ok {
+		return fmt.Errorf("unsupported period '%s'", word)
+	}
+
+	q.repeat = func(start time.Time) string {
+		switch unit {
+		case unitMinute:
+			return fmt.Sprintf("min %d", n)
+		case unitHour:
+			return fmt.Sprintf("h %d", n)
+		case unitWeek:
+			return fmt.Sprintf("d %d", n*7)
+		case unitMonth:
+			if n > 1 {
+				return fmt.Sprintf("m/%d %d", n, start.Day())
+			}
+			return fmt.Sprintf("m %d", start.Day())
+		case unitYear:
+			if n > 1 {
+				return fmt.Sprintf("y %d", n)
+			}
+			return "y"
+		default:
+			return fmt.Sprintf("d %d", n)
+		}
+	}
+	q.snap = false
+	return nil
+}
+
+// quickUnit recognizes the period of an interval by the beginning of its English or Russian name.
+func quickUnit(word string) (repeatUnit, bool) {
+	units := []struct {
+		prefixes []string
+		unit     repeatUnit
+	}{
+		{[]string{"min", "мин"}, unitMinute},
+		{[]string{"hour", "час"}, unitHour},
+		{[]string{"da", "ден", "дн"}, unitDay},
+		{[]string{"week", "нед"}, unitWeek},
+		{[]string{"mon", "меся"}, unitMonth},
+		{[]string{"year", "annual", "год", "лет"}, unitYear},
+	}
+	for _, u := range units {
+		for _, prefix := range u.prefixes {
+			if strings.HasPrefix(word, prefix) {
+				return u.unit, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// quickInterval parses the number of periods of a phrase: empty means 1 and "other" means 2.
+func quickInterval(value string) (int, error) {
+	switch strings.ToLower(value) {
+	case "", "a", "an", "one":
+		return 1, nil
+	case "other":
+		return 2, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid interval '%s'", value)
+	}
+	return n, nil
+}
+
+// quickWeekdays turns a list of weekday names into the comma-separated weekday numbers of the "w" rule.
+func quickWeekdays(list string) string {
+	var days []int
+	for _, name := range reWeekdayName.FindAllString(list, -1) {
+		if wd, ok := quickWeekday(name); ok {
+			days = append(days, wd)
+		}
+	}
+	slices.Sort(days)
+	days = slices.Compact(days)
+
+	items := make([]string, 0, len(days))
+	for _, wd := range days {
+		items = append(items, strconv.Itoa(wd))
+	}
+	return strings.Join(items, ",")
+}
+
+// quickWeekday returns the number of the weekday with the given English or Russian name, 1 being Monday.
+func quickWeekday(name string) (int, bool) {
+	name = strings.ToLower(name)
+	for i := range enWeekdayStems {
+		if strings.HasPrefix(name, enWeekdayStems[i]) || strings.HasPrefix(name, ruWeekdayStems[i]) {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// quickMonth returns the number of the month with the given English or Russian name.
+func quickMonth(name string) (time.Month, bool) {
+	name = strings.ToLower(name)
+	for i := range enMonthStems {
+		if strings.HasPrefix(name, enMonthStems[i]) || strings.HasPrefix(name, ruMonthStems[i]) {
+			return time.Month(i + 1), true
+		}
+	}
+	return 0, false
+}
+
+// clockTime records a time of day given as "HH:MM", optionally followed by am or pm.
+func clockTime(q *quickTask, m []string) error {
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	return setTime(q, hour, minute, m[3], m[0])
+}
+
+// clockTime12 records a time of day given as an hour followed by am or pm, or the Russian part of the day.
+func clockTime12(q *quickTask, m []string) error {
+	hour, _ := strconv.Atoi(m[1])
+	return setTime(q, hour, 0, m[2], m[0])
+}
+
+// hourTime records a time of day given as a whole hour.
+func hourTime(q *quickTask, m []string) error {
+	hour, _ := strconv.Atoi(firstGroup(m))
+	return setTime(q, hour, 0, "", m[0])
+}
+
+// namedTime records noon or midnight.
+func namedTime(q *quickTask, m []string) error {
+	switch strings.ToLower(firstGroup(m)) {
+	case "midnight", "полночь":
+		return setTime(q, 0, 0, "", m[0])
+	default:
+		return setTime(q, 12, 0, "", m[0])
+	}
+}
+
+// setTime records the given time of day. The part of the day, "am", "pm" or its Russian counterpart,
+// turns a 12-hour clock time into a 24-hour one.
+func setTime(q *quickTask, hour, minute int, part, phrase string) error {
+	part = strings.ReplaceAll(strings.ToLower(part), ".", "")
+	if part != "" {
+		if hour < 1 || hour > 12 {
+			return fmt.Errorf("invalid time of day '%s'", strings.TrimSpace(phrase))
+		}
+		switch part {
+		case "pm", "дня", "вечера":
+			if hour < 12 {
+				hour += 12
+			}
+		case "am", "ночи":
+			if hour == 12 {
+				hour = 0
+			}
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return fmt.Errorf("invalid time of day '%s'", strings.TrimSpace(phrase))
+	}
+	q.time = fmt.Sprintf("%02d:%02d", hour, minute)
+	return nil
+}
+
+// daysFromToday records the date the given number of days from today.
+func daysFromToday(days int) func(q *quickTask, m []string) error {
+	return func(q *quickTask, _ []string) error {
+		q.date = q.today.AddDate(0, 0, days)
+		return nil
+	}
+}
+
+// dateIn records the date the given number of days, weeks or months from today.
+func dateIn(q *quickTask, m []string) error {
+	number, word := m[1], m[2]
+	if word == "" {
+		number, word = m[3], m[4]
+	}
+	n, err := quickInterval(number)
+	if err != nil {
+		return err
+	}
+
+	unit, _ := quickUnit(strings.ToLower(word))
+	switch unit {
+	case unitWeek:
+		q.date = q.today.AddDate(0, 0, n*7)
+	case unitMonth:
+		q.date = q.today.AddDate(0, n, 0)
+	default:
+		q.date = q.today.AddDate(0, 0, n)
+	}
+	return nil
+}
+
+// weekdayDate records the first date after today falling on the given weekday.
+func weekdayDate(q *quickTask, m []string) error {
+	wd, ok := quickWeekday(firstGroup(m))
+	if !ok {
+		return fmt.Errorf("unknown weekday '%s'", strings.TrimSpace(m[0]))
+	}
+	days := (wd%sundayNum - int(q.today.Weekday()) + sundayNum) % sundayNum
+	if days == 0 {
+		days = sundayNum
+	}
+	q.date = q.today.AddDate(0, 0, days)
+	return nil
+}
+
+// isoDate records a date given as "YYYY-MM-DD".
+func isoDate(q *quickTask, m []string) error {
+	return setDate(q, m[1], m[2], m[3], m[0])
+}
+
+// dottedDate records a date given as "DD.MM.YYYY" or "DD.MM".
+func dottedDate(q *quickTask, m []string) error {
+	return setDate(q, m[3], m[2], m[1], m[0])
+}
+
+// dayMonthDate records a date given as a day followed by the name of a month, and optionally a year.
+func dayMonthDate(q *quickTask, m []string) error {
+	month, ok := quickMonth(m[2])
+	if !ok {
+		return fmt.Errorf("unknown month '%s'", m[2])
+	}
+	return setDate(q, m[3], strconv.Itoa(int(month)), m[1], m[0])
+}
+
+// monthDayDate records a date given as the name of a month followed by a day, and optionally a year.
+func monthDayDate(q *quickTask, m []string) error {
+	month, ok := quickMonth(m[1])
+	if !ok {
+		return fmt.Errorf("unknown month '%s'", m[1])
+	}
+	return setDate(q, m[3], strconv.Itoa(int(month)), m[2], m[0])
+}
+
+// setDate records the given date; without a year, it's the first such date that isn't before today.
+// It returns an error if the date doesn't exist, like February 30.
+func setDate(q *quickTask, year, month, day, phrase string) error {
+	y, _ := strconv.Atoi(year)
+	if year == "" {
+		y = q.today.Year()
+	}
+	mon, _ := strconv.Atoi(month)
+	d, _ := strconv.Atoi(day)
+
+	date := time.Date(y, time.Month(mon), d, 0, 0, 0, 0, time.UTC)
+	if year == "" && date.Before(q.today) {
+		date = time.Date(y+1, time.Month(mon), d, 0, 0, 0, 0, time.UTC)
+	}
+	if date.Day() != d || int(date.Month()) != mon {
+		return fmt.Errorf("invalid date '%s'", strings.TrimSpace(phrase))
+	}
+	q.date = date
+	return nil
+}
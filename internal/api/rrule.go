@@ -0,0 +1,577 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mascotmascot1/go-todo/internal/db"
+)
+
+const (
+	rrulePrefix          = "RRULE:"
+	rruleFreqKey         = "FREQ="
+	rruleUntilLayout     = "20060102T150405Z"
+	rruleUntilLocal      = "20060102T150405"
+	maxRRuleInterval     = 400
+	maxRRuleCount        = 1000
+	maxRRuleOrdinal      = 53
+	maxRRuleSetPos       = 366
+	maxRRuleEmptyPeriods = 1000
+)
+
+type rruleFreq string
+
+const (
+	freqDaily   rruleFreq = "DAILY"
+	freqWeekly  rruleFreq = "WEEKLY"
+	freqMonthly rruleFreq = "MONTHLY"
+	freqYearly  rruleFreq = "YEARLY"
+)
+
+var (
+	reRRuleCount = regexp.MustCompile(`(?i)(^|;|:)COUNT=\d+`)
+	rruleDays    = map[string]time.Weekday{
+		"MO": time.Monday,
+		"TU": time.Tuesday,
+		"WE": time.Wednesday,
+		"TH": time.Thursday,
+		"FR": time.Friday,
+		"SA": time.Saturday,
+		"SU": time.Sunday,
+	}
+)
+
+// rruleWeekday is a single BYDAY entry. A zero ordinal means every such weekday
+// in the period, otherwise only the n-th one (negative values count from the end).
+type rruleWeekday struct {
+	ordinal int
+	weekday time.Weekday
+}
+
+// rrule is a parsed iCalendar recurrence rule (RFC 5545, section 3.3.10).
+// Only the date-level parts are supported since tasks have no time of day.
+type rrule struct {
+	freq       rruleFreq
+	interval   int
+	count      int
+	until      time.Time
+	weekStart  time.Weekday
+	byDay      []rruleWeekday
+	byMonthDay []int
+	byMonth    []int
+	bySetPos   []int
+}
+
+// isRRule reports whether the given repeat rule is written in the iCalendar RRULE syntax
+// rather than in the custom d/y/w/m syntax.
+func isRRule(repeat string) bool {
+	upper := strings.ToUpper(repeat)
+	return strings.HasPrefix(upper, rrulePrefix) || strings.HasPrefix(upper, rruleFreqKey)
+}
+
+// nextRRule computes the next date given a date and an RRULE repeat rule.
+// The initial date is used as DTSTART of the recurrence.
+// It returns ErrRepeatEnded if COUNT or UNTIL leave no occurrence after the base date.
+func nextRRule(now, startDate time.Time, repeat string) (string, error) {
+	r, err := parseRRule(repeat)
+	if err != nil {
+		return "", err
+	}
+
+	base := computeBaseTime(now, startDate)
+	var next time.Time
+	err = r.iterate(startDate, base, func(date time.Time) bool {
+		if afterNow(base, date) {
+			next = date
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	return next.Format(db.DateLayoutDB), nil
+}
+
+// advanceRepeat returns the repeat rule that has to be stored once a task moves from dStart to nextDate.
+// Only RRULE strings with COUNT change: the occurrences between both dates are used up,
+// so COUNT is decreased by their number. Any other rule is returned untouched.
+func advanceRepeat(repeat, dStart, nextDate string) (string, error) {
+	if !isRRule(repeat) {
+		return repeat, nil
+	}
+	r, err := parseRRule(repeat)
+	if err != nil {
+		return "", err
+	}
+	if r.count == 0 {
+		return repeat, nil
+	}
+
+	startDate, err := time.Parse(db.DateLayoutDB, dStart)
+	if err != nil {
+		return "", fmt.Errorf("error parsing the initial date '%s': %w", dStart, err)
+	}
+	next, err := time.Parse(db.DateLayoutDB, nextDate)
+	if err != nil {
+		return "", fmt.Errorf("error parsing the next date '%s': %w", nextDate, err)
+	}
+
+	var used int
+	err = r.iterate(startDate, startDate, func(date time.Time) bool {
+		if date.Before(next) {
+			used++
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return "", err
+	}
+
+	left := max(r.count-used, 0)
+	return reRRuleCount.ReplaceAllString(repeat, "${1}COUNT="+strconv.Itoa(left)), nil
+}
+
+// parseRRule parses the given RRULE string, with or without the "RRULE:" prefix.
+// Part names and values are case-insensitive. It returns a descriptive error
+// for unknown or duplicated parts, out of range values and combinations forbidden by RFC 5545.
+func parseRRule(repeat string) (*rrule, error) {
+	value := strings.ToUpper(strings.TrimSpace(repeat))
+	value = strings.TrimPrefix(value, rrulePrefix)
+
+	r := &rrule{interval: 1, weekStart: time.Monday}
+	seen := make(map[string]bool)
+
+	for part := range strings.SplitSeq(value, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("invalid RRULE part '%s': expected KEY=VALUE", part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("invalid RRULE: duplicate part '%s'", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			err = r.parseFreq(val)
+		case "INTERVAL":
+			r.interval, err = parseRRuleInt(key, val, 1, maxRRuleInterval)
+		case "COUNT":
+			r.count, err = parseRRuleInt(key, val, 1, maxRRuleCount)
+		case "UNTIL":
+			r.until, err = parseRRuleUntil(val)
+		case "WKST":
+			wd, ok := rruleDays[val]
+			if !ok {
+				err = fmt.Errorf("invalid WKST value '%s'", val)
+			}
+			r.weekStart = wd
+		case "BYDAY":
+			r.byDay, err = parseRRuleByDay(val)
+		case "BYMONTHDAY":
+			r.byMonthDay, err = parseRRuleInts(key, val, 31, true)
+		case "BYMONTH":
+			r.byMonth, err = parseRRuleInts(key, val, 12, false)
+		case "BYSETPOS":
+			r.bySetPos, err = parseRRuleInts(key, val, maxRRuleSetPos, true)
+		default:
+			err = fmt.Errorf("unsupported RRULE part '%s'", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRULE: %w", err)
+		}
+	}
+
+	if err := r.check(seen); err != nil {
+		return nil, fmt.Errorf("invalid RRULE: %w", err)
+	}
+	return r, nil
+}
+
+// parseFreq sets the frequency of the rule from the FREQ value.
+func (r *rrule) parseFreq(val string) error {
+	switch freq := rruleFreq(val); freq {
+	case freqDaily, freqWeekly, freqMonthly, freqYearly:
+		r.freq = freq
+		return nil
+	default:
+		return fmt.Errorf("unsupported FREQ '%s', expected DAILY, WEEKLY, MONTHLY or YEARLY", val)
+	}
+}
+
+// check validates the combination of parts of an already parsed rule.
+func (r *rrule) check(seen map[string]bool) error {
+	if r.freq == "" {
+		return fmt.Errorf("FREQ is required")
+	}
+	if seen["COUNT"] && seen["UNTIL"] {
+		return fmt.Errorf("COUNT and UNTIL can't be used together")
+	}
+	if r.freq == freqWeekly && len(r.byMonthDay) > 0 {
+		return fmt.Errorf("BYMONTHDAY can't be used with FREQ=WEEKLY")
+	}
+	if r.freq == freqDaily || r.freq == freqWeekly {
+		for _, wd := range r.byDay {
+			if wd.ordinal != 0 {
+				return fmt.Errorf("numbered BYDAY values are only allowed with FREQ=MONTHLY or FREQ=YEARLY")
+			}
+		}
+	}
+	if r.freq == freqYearly && len(r.byMonth) == 0 && len(r.byMonthDay) > 0 && len(r.byDay) > 0 {
+		return fmt.Errorf("BYDAY with BYMONTHDAY requires BYMONTH with FREQ=YEARLY")
+	}
+	if len(r.bySetPos) > 0 && len(r.byDay) == 0 && len(r.byMonthDay) == 0 && len(r.byMonth) == 0 {
+		return fmt.Errorf("BYSETPOS requires BYDAY, BYMONTHDAY or BYMONTH")
+	}
+	return nil
+}
+
+// parseRRuleInt parses a single integer value of the given part and checks it against the given range.
+func parseRRuleInt(key, val string, minVal, maxVal int) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value '%s': not a number", key, val)
+	}
+	if n < minVal || n > maxVal {
+		return 0, fmt.Errorf("invalid %s value '%d': must be between %d and %d", key, n, minVal, maxVal)
+	}
+	return n, nil
+}
+
+// parseRRuleInts parses a comma-separated list of integers of the given part.
+// Values must be within 1..maxVal, or within -maxVal..-1 if negative values are allowed.
+func parseRRuleInts(key, val string, maxVal int, allowNegative bool) ([]int, error) {
+	items := strings.Split(val, ",")
+	out := make([]int, 0, len(items))
+
+	for _, item := range items {
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s': not a number", key, item)
+		}
+		if n < 0 && allowNegative {
+			n = -n
+			if n > maxVal {
+				return nil, fmt.Errorf("invalid %s value '-%d': must be between -%d and -1", key, n, maxVal)
+			}
+			out = append(out, -n)
+			continue
+		}
+		if n <= 0 || n > maxVal {
+			return nil, fmt.Errorf("invalid %s value '%d': must be between 1 and %d", key, n, maxVal)
+		}
+		out = append(out, n)
+	}
+	return sortUniqueInts(out), nil
+}
+
+// parseRRuleByDay parses a BYDAY list such as "MO,WE" or "2TU,-1FR".
+func parseRRuleByDay(val string) ([]rruleWeekday, error) {
+	items := strings.Split(val, ",")
+	out := make([]rruleWeekday, 0, len(items))
+
+	for _, item := range items {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY value '%s'", item)
+		}
+		code, ordStr := item[len(item)-2:], item[:len(item)-2]
+
+		wd, ok := rruleDays[code]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY value '%s': unknown weekday '%s'", item, code)
+		}
+
+		var ord int
+		if ordStr != "" {
+			n, err := strconv.Atoi(ordStr)
+			if err != nil || n == 0 || n < -maxRRuleOrdinal || n > maxRRuleOrdinal {
+				return nil, fmt.Errorf("invalid BYDAY value '%s': ordinal must be between -%d and %d, except 0",
+					item, maxRRuleOrdinal, maxRRuleOrdinal)
+			}
+			ord = n
+		}
+		out = append(out, rruleWeekday{ordinal: ord, weekday: wd})
+	}
+	return out, nil
+}
+
+// parseRRuleUntil parses the UNTIL value. Both date ("20060102") and date-time forms are accepted,
+// but only the date is kept.
+func parseRRuleUntil(val string) (time.Time, error) {
+	for _, layout := range []string{db.DateLayoutDB, rruleUntilLayout, rruleUntilLocal} {
+		if t, err := time.Parse(layout, val); err == nil {
+			return midnight(t), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL value '%s': expected YYYYMMDD or YYYYMMDDTHHMMSSZ", val)
+}
+
+// iterate walks the occurrences of the rule in ascending order, starting with startDate as DTSTART.
+// If the rule has no COUNT, periods before from are skipped, since they can't influence the result.
+// The walk stops as soon as yield returns false. iterate returns ErrRepeatEnded
+// if the series runs out first, and an error if the rule never produces a date.
+func (r *rrule) iterate(startDate, from time.Time, yield func(time.Time) bool) error {
+	first := r.periodStart(startDate)
+
+	var idx int
+	if r.count == 0 && from.After(first) {
+		idx = r.periodsBetween(first, from) / r.interval
+	}
+
+	var emitted, empty int
+	for ; ; idx++ {
+		dates := r.expand(r.shift(first, idx*r.interval), startDate)
+		if len(dates) == 0 {
+			empty++
+			if empty > maxRRuleEmptyPeriods {
+				return fmt.Errorf("invalid RRULE: rule never produces a date")
+			}
+			continue
+		}
+		empty = 0
+
+		for _, date := range dates {
+			if !r.until.IsZero() && date.After(r.until) {
+				return ErrRepeatEnded
+			}
+			emitted++
+			if r.count > 0 && emitted > r.count {
+				return ErrRepeatEnded
+			}
+			if !yield(date) {
+				return nil
+			}
+		}
+	}
+}
+
+// periodStart returns the first day of the period (day, week, month or year) the given date belongs to.
+func (r *rrule) periodStart(date time.Time) time.Time {
+	switch r.freq {
+	case freqWeekly:
+		offset := (int(date.Weekday()) - int(r.weekStart) + 7) % 7
+		return date.AddDate(0, 0, -offset)
+	case freqMonthly:
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	case freqYearly:
+		return time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, date.Location())
+	default:
+		return date
+	}
+}
+
+// shift moves the given period start by n periods.
+func (r *rrule) shift(period time.Time, n int) time.Time {
+	switch r.freq {
+	case freqWeekly:
+		return period.AddDate(0, 0, 7*n)
+	case freqMonthly:
+		return period.AddDate(0, n, 0)
+	case freqYearly:
+		return period.AddDate(n, 0, 0)
+	default:
+		return period.AddDate(0, 0, n)
+	}
+}
+
+// periodsBetween returns the number of whole periods between the period start and the given date.
+func (r *rrule) periodsBetween(period, date time.Time) int {
+	switch r.freq {
+	case freqWeekly:
+		return daysBetween(period, date) / 7
+	case freqMonthly:
+		return (date.Year()-period.Year())*12 + int(date.Month()) - int(period.Month())
+	case freqYearly:
+		return date.Year() - period.Year()
+	default:
+		return daysBetween(period, date)
+	}
+}
+
+// expand returns the sorted occurrences of the rule within the period starting at the given date.
+// BYSETPOS is applied to the whole set, and dates before startDate are dropped.
+func (r *rrule) expand(period, startDate time.Time) []time.Time {
+	var dates []time.Time
+
+	switch r.freq {
+	case freqDaily:
+		if r.matchMonth(period) && r.matchMonthDay(period) && r.matchWeekday(period) {
+			dates = append(dates, period)
+		}
+
+	case freqWeekly:
+		for i := range 7 {
+			date := period.AddDate(0, 0, i)
+			if !r.matchMonth(date) {
+				continue
+			}
+			if (len(r.byDay) == 0 && date.Weekday() == startDate.Weekday()) || (len(r.byDay) > 0 && r.matchWeekday(date)) {
+				dates = append(dates, date)
+			}
+		}
+
+	case freqMonthly:
+		if r.matchMonth(period) {
+			dates = r.expandMonth(period.Year(), int(period.Month()), startDate)
+		}
+
+	case freqYearly:
+		dates = r.expandYear(period.Year(), startDate)
+	}
+
+	slices.SortFunc(dates, time.Time.Compare)
+	dates = slices.CompactFunc(dates, time.Time.Equal)
+	dates = applySetPos(dates, r.bySetPos)
+
+	return slices.DeleteFunc(dates, func(date time.Time) bool {
+		return date.Before(startDate)
+	})
+}
+
+// expandMonth returns the occurrences of the rule within the given month.
+// Without BYMONTHDAY and BYDAY the day of the month of startDate is used.
+func (r *rrule) expandMonth(year, month int, startDate time.Time) []time.Time {
+	loc := startDate.Location()
+	first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, loc)
+	last := computeLastMonthDay(year, month, loc)
+
+	switch {
+	case len(r.byMonthDay) > 0:
+		var dates []time.Time
+		for _, md := range r.byMonthDay {
+			if md < 0 {
+				md = last + md + 1
+			}
+			if md < 1 || md > last {
+				continue
+			}
+			date := first.AddDate(0, 0, md-1)
+			if len(r.byDay) == 0 || slices.ContainsFunc(r.expandByDay(first, last), date.Equal) {
+				dates = append(dates, date)
+			}
+		}
+		return dates
+
+	case len(r.byDay) > 0:
+		return r.expandByDay(first, last)
+
+	case startDate.Day() <= last:
+		return []time.Time{first.AddDate(0, 0, startDate.Day()-1)}
+
+	default:
+		return nil
+	}
+}
+
+// expandYear returns the occurrences of the rule within the given year.
+// BYMONTH selects the months to expand, otherwise BYMONTHDAY means every month,
+// BYDAY means every matching weekday of the year and no BY-part means the date of startDate.
+func (r *rrule) expandYear(year int, startDate time.Time) []time.Time {
+	var dates []time.Time
+
+	switch {
+	case len(r.byMonth) > 0:
+		for _, m := range r.byMonth {
+			dates = append(dates, r.expandMonth(year, m, startDate)...)
+		}
+
+	case len(r.byMonthDay) > 0:
+		for _, m := range allMonths {
+			dates = append(dates, r.expandMonth(year, m, startDate)...)
+		}
+
+	case len(r.byDay) > 0:
+		first := time.Date(year, time.January, 1, 0, 0, 0, 0, startDate.Location())
+		days := daysBetween(first, first.AddDate(1, 0, 0))
+		dates = r.expandByDay(first, days)
+
+	default:
+		date := time.Date(year, startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
+		// Skip years where the day doesn't exist, e.g. February 29.
+		if date.Day() == startDate.Day() {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+// expandByDay returns the days within the given number of days from first that match BYDAY.
+// Numbered entries pick the n-th matching weekday of the range, counting from the end if negative.
+func (r *rrule) expandByDay(first time.Time, days int) []time.Time {
+	var dates []time.Time
+
+	for _, wd := range r.byDay {
+		var matches []time.Time
+		for i := range days {
+			if date := first.AddDate(0, 0, i); date.Weekday() == wd.weekday {
+				matches = append(matches, date)
+			}
+		}
+
+		switch {
+		case wd.ordinal == 0:
+			dates = append(dates, matches...)
+		case wd.ordinal > 0 && wd.ordinal <= len(matches):
+			dates = append(dates, matches[wd.ordinal-1])
+		case wd.ordinal < 0 && -wd.ordinal <= len(matches):
+			dates = append(dates, matches[len(matches)+wd.ordinal])
+		}
+	}
+	return dates
+}
+
+// matchMonth reports whether the date satisfies BYMONTH.
+func (r *rrule) matchMonth(date time.Time) bool {
+	return len(r.byMonth) == 0 || slices.Contains(r.byMonth, int(date.Month()))
+}
+
+// matchMonthDay reports whether the date satisfies BYMONTHDAY.
+func (r *rrule) matchMonthDay(date time.Time) bool {
+	if len(r.byMonthDay) == 0 {
+		return true
+	}
+	last := computeLastMonthDay(date.Year(), int(date.Month()), date.Location())
+	return slices.Contains(r.byMonthDay, date.Day()) || slices.Contains(r.byMonthDay, date.Day()-last-1)
+}
+
+// matchWeekday reports whether the weekday of the date is listed in BYDAY, ignoring ordinals.
+func (r *rrule) matchWeekday(date time.Time) bool {
+	if len(r.byDay) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(r.byDay, func(wd rruleWeekday) bool {
+		return wd.weekday == date.Weekday()
+	})
+}
+
+// applySetPos keeps only the dates at the given BYSETPOS positions of the sorted set.
+// Negative positions count from the end. An empty position list keeps every date.
+func applySetPos(dates []time.Time, positions []int) []time.Time {
+	if len(positions) == 0 || len(dates) == 0 {
+		return dates
+	}
+
+	picked := make([]time.Time, 0, len(positions))
+	for _, pos := range positions {
+		idx := pos - 1
+		if pos < 0 {
+			idx = len(dates) + pos
+		}
+		if idx >= 0 && idx < len(dates) {
+			picked = append(picked, dates[idx])
+		}
+	}
+
+	slices.SortFunc(picked, time.Time.Compare)
+	return slices.CompactFunc(picked, time.Time.Equal)
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mascotmascot1/go-todo/internal/db"
+)
+
+type tagsResponse struct {
+	Tags []*db.TagCount `json:"tags"`
+}
+
+type renameTagRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type mergeTagsRequest struct {
+	From []string `json:"from"`
+	To   string   `json:"to"`
+}
+
+// normalizeTag returns the tag trimmed and in lower case, so that tags differing only in case are the same tag.
+// It returns an error if the tag is empty, longer than maxTagLength or has spaces, commas, parentheses or quotes,
+// which would make it ambiguous in a search query.
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return "", fmt.Errorf("tag mustn't be empty")
+	}
+	if utf8.RuneCountInString(tag) > maxTagLength {
+		return "", fmt.Errorf("tag '%s' is too long, at most %d characters are allowed", tag, maxTagLength)
+	}
+	if strings.ContainsFunc(tag, func(r rune) bool { return unicode.IsSpace(r) || strings.ContainsRune(`,()"'`, r) }) {
+		return "", fmt.Errorf("tag '%s' mustn't contain spaces, commas, parentheses or quotes", tag)
+	}
+	return tag, nil
+}
+
+// tagsHandler returns the tags of the tasks, except the ones in the trash, ordered by name.
+// The response will be in JSON format and will contain the list of tags under the key "tags",
+// each one with its name under "name" and the number of tasks having it under "count".
+func (h *Handlers) tagsHandler(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.store.Tags(r.Context())
+	if err != nil {
+		h.failWithTaskError(w, "tagsHandler", err)
+		return
+	}
+	h.writeJSON(w, tagsResponse{Tags: tags}, http.StatusOK)
+}
+
+// renameTagHandler renames the tag given under "from" in the JSON request body to the one under "to"
+// on all the tasks that have it.
+// If there's no such tag, it will return an error with 404 status code, and if the new name is already taken,
+// it will return an error with 409 status code: such tags are merged with mergeTagsHandler instead.
+// If the request body or one of the tags is invalid, it will return an error with 400 status code.
+// On success, it will record each task with the tag, the ones in the trash included, in the audit log
+// and return an empty response with 200 status code.
+func (h *Handlers) renameTagHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "renameTagHandler"
+
+	var req renameTagRequest
+	if !h.readJSON(w, r, caller, &req) {
+		return
+	}
+	from, err := normalizeTag(req.From)
+	if err == nil {
+		req.To, err = normalizeTag(req.To)
+	}
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.store.RenameTag(r.Context(), from, req.To); err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+	h.writeJSON(w, struct{}{}, http.StatusOK)
+}
+
+// mergeTagsHandler replaces the tags listed under "from" in the JSON request body with the one under "to"
+// on all the tasks that have them, and deletes them. The tag they're merged into is created if it doesn't exist.
+// If one of the tags to merge doesn't exist, it will return an error with 404 status code and merge nothing.
+// If the request body or one of the tags is invalid, or there are no tags to merge, it will return an error
+// with 400 status code.
+// On success, it will record each task with the merged tags, the ones in the trash included, in the audit log
+// and return an empty response with 200 status code.
+func (h *Handlers) mergeTagsHandler(w http.ResponseWriter, r *http.Request) {
+	caller := "mergeTagsHandler"
+
+	var req mergeTagsRequest
+	if !h.readJSON(w, r, caller, &req) {
+		return
+	}
+	to, err := normalizeTag(req.To)
+	if err == nil && len(req.From) == 0 {
+		err = fmt.Errorf("no tags to merge")
+	}
+	for i := 0; err == nil && i < len(req.From); i++ {
+		req.From[i], err = normalizeTag(req.From[i])
+	}
+	if err != nil {
+		h.logger.Printf("%s: %v\n", caller, err)
+		h.writeJSON(w, response{Error: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.store.MergeTags(r.Context(), req.From, to); err != nil {
+		h.failWithTaskError(w, caller, err)
+		return
+	}
+	h.writeJSON(w, struct{}{}, http.StatusOK)
+}
+
+// readJSON reads the request body into the given value.
+// If the body can't be read or isn't valid JSON, it writes an error with 400 status code and returns false.
+func (h *Handlers) readJSON(w http.ResponseWriter, r *http.Request, caller string, v any) bool {
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.Printf("%s: failed to read body: %v\n", caller, err)
+		h.writeJSON(w, response{Error: "failed to read request body"}, http.StatusBadRequest)
+		return false
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		h.logger.Printf("%s: json marshal error: %v\n", caller, err)
+		h.writeJSON(w, response{Error: fmt.Sprintf("JSON deserialization failed: %v", err)}, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
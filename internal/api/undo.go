@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mascotmascot1/go-todo/internal/db"
+)
+
+type changesResponse struct {
+	Changes []*db.Change `json:"changes"`
+}
+
+type undoResponse struct {
+	Change *db.Change `json:"change"`
+}
+
+// changesHandler returns the changes of tasks that can still be undone, the most recent first:
+// the updates, deletions and completions made within the undo window that haven't been undone yet.
+// The response will be in JSON format and will contain the list of changes under the key "changes",
+// each one with the task as it was before the change under "previous".
+func (h *Handlers) changesHandler(w http.ResponseWriter, r *http.Request) {
+	if h.limits.UndoWindow <= 0 {
+		h.writeJSON(w, changesResponse{Changes: []*db.Change{}}, http.StatusOK)
+		return
+	}
+
+	changes, err := h.store.Changes(r.Context(), time.Now().Add(-h.limits.UndoWindow))
+	if err != nil {
+		h.failWithTaskError(w, "changesHandler", err)
+		return
+	}
+	h.writeJSON(w, changesResponse{Changes: changes}, http.StatusOK)
+}
+
+// undoHandler undoes the change with the id given by the 'id' parameter, or the most recent change if it's empty,
+// bringing the task back to its state before the change. Undoing a completion also removes it from the history.
+// If there's no such change within the undo window, or the window is zero, it will return an error with 404 status code,
+// as it will if the task has been purged from the trash since.
+// If the task has been changed again since, it will return an error with 409 status code:
+// the later change must be undone first.
+// The undo is recorded in the audit log like any other change.
+// The response will be in JSON format and will contain the undone change under the key "change".
+func (h *Handlers) undoHandler(w http.ResponseWriter, r *http.Request) {
+	if h.limits.UndoWindow <= 0 {
+		h.failWithTaskError(w, "undoHandler", fmt.Errorf("undoing is disabled: %w", db.ErrNothingToUndo))
+		return
+	}
+
+	change, _, err := h.store.UndoChange(r.Context(), r.FormValue("id"), time.Now().Add(-h.limits.UndoWindow))
+	if err != nil {
+		h.failWithTaskError(w, "undoHandler", err)
+		return
+	}
+	h.writeJSON(w, undoResponse{Change: change}, http.StatusOK)
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mascotmascot1/go-todo/internal/db"
+	"github.com/mascotmascot1/go-todo/internal/holidays"
+)
+
+const (
+	lastBusinessDay = "last"
+	maxWorkdayShift = 14
+)
+
+// nextBusinessDay computes the next date given a date and a business day repeat rule.
+// The repeat rule is of the format "b <number>" where <number> is the number of business days to repeat,
+// or "b last" for the last business day of every month.
+// Business days are the days that are neither weekends nor holidays of the given calendar.
+// The function will return an error if the repeat rule is invalid or if the server fails to compute the next date.
+// The response is in plain text format and contains the next date in "YYYY-MM-DD".
+func nextBusinessDay(now, startDate time.Time, repeat string, cal *holidays.Calendar) (string, error) {
+	parts := strings.Split(repeat, " ")
+	if parts[1] == lastBusinessDay {
+		return nextLastBusinessDay(now, startDate, cal)
+	}
+
+	days, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid business day value: %w", err)
+	}
+	if days <= 0 || days > maxDaysInterval {
+		return "", fmt.Errorf("invalid business day interval '%d'", days)
+	}
+
+	for {
+		for left := days; left > 0; {
+			startDate = startDate.AddDate(0, 0, 1)
+			if cal.IsWorkday(startDate) {
+				left--
+			}
+		}
+		if afterNow(now, startDate) {
+			break
+		}
+	}
+	return startDate.Format(db.DateLayoutDB), nil
+}
+
+// nextLastBusinessDay computes the next date that is the last business day of its month.
+// The function will return an error if no business day is found within maxMonthLookupYears.
+func nextLastBusinessDay(now, startDate time.Time, cal *holidays.Calendar) (string, error) {
+	baseTime := computeBaseTime(now, startDate)
+	firstMonth := time.Date(baseTime.Year(), baseTime.Month(), 1, 0, 0, 0, 0, baseTime.Location())
+
+	for i := 0; i <= maxMonthLookupYears*12; i++ {
+		month := firstMonth.AddDate(0, i, 0)
+
+		date := month.AddDate(0, 1, -1)
+		for date.Month() == month.Month() && !cal.IsWorkday(date) {
+			date = date.AddDate(0, 0, -1)
+		}
+		if date.Month() == month.Month() && afterNow(baseTime, date) {
+			return date.Format(db.DateLayoutDB), nil
+		}
+	}
+	return "", fmt.Errorf("invalid repeat rule: there aren't business days in the calendar")
+}
+
+// nearestWorkday returns the working day nearest to the given date, the date itself if it's a working day.
+// If two working days are equally near, the earlier one is returned.
+// If there is no working day within maxWorkdayShift days, the date is returned unchanged.
+func nearestWorkday(date string, cal *holidays.Calendar) string {
+	d, err := time.Parse(db.DateLayoutDB, date)
+	if err != nil || cal.IsWorkday(d) {
+		return date
+	}
+
+	for k := 1; k <= maxWorkdayShift; k++ {
+		if before := d.AddDate(0, 0, -k); cal.IsWorkday(before) {
+			return before.Format(db.DateLayoutDB)
+		}
+		if after := d.AddDate(0, 0, k); cal.IsWorkday(after) {
+			return after.Format(db.DateLayoutDB)
+		}
+	}
+	return date
+}
+
+// nominalDate returns the date of the task's current occurrence as computed by its repeat rule,
+// i.e. the task's date without the working day shift.
+func nominalDate(task *db.Task) string {
+	if task.Shift == 0 {
+		return task.Date
+	}
+	date, err := time.Parse(db.DateLayoutDB, task.Date)
+	if err != nil {
+		return task.Date
+	}
+	return date.AddDate(0, 0, -task.Shift).Format(db.DateLayoutDB)
+}
+
+// nextOccurrence returns the first occurrence of the task's series, skipping its exceptions, that falls after the given time.
+// The occurrence is returned both as computed by the repeat rule and as the date the task falls on;
+// they differ only for tasks moved to the nearest working day.
+func nextOccurrence(task *db.Task, after time.Time, cal *holidays.Calendar) (string, string, error) {
+	var (
+		start    = nominalDate(task)
+		from     = after
+		afterStr = after.Format(db.DateLayoutDB)
+	)
+
+	// The shifted date can't be more than maxWorkdayShift days before the rule's date,
+	// so the loop is bounded.
+	for range maxWorkdayShift + 1 {
+		nominal, err := NextDateExcept(from, start, task.Repeat, task.Exceptions, cal)
+		if err != nil {
+			return "", "", err
+		}
+		if !task.Workday {
+			return nominal, nominal, nil
+		}
+
+		date := nearestWorkday(nominal, cal)
+		if date > afterStr {
+			return nominal, date, nil
+		}
+
+		from, err = time.Parse(db.DateLayoutDB, nominal)
+		if err != nil {
+			return "", "", fmt.Errorf("error parsing the next date '%s': %w", nominal, err)
+		}
+	}
+	return "", "", fmt.Errorf("failed to find an occurrence on a working day")
+}
+
+// shiftToWorkday moves the task from the date computed by its repeat rule to the nearest working day
+// and records the shift, so that the series keeps being computed from the rule's own dates.
+// If the working day is before today, the task moves on to the first occurrence that isn't.
+func shiftToWorkday(task *db.Task, now time.Time, cal *holidays.Calendar) error {
+	nominal, date := task.Date, nearestWorkday(task.Date, cal)
+
+	if date < now.Format(db.DateLayoutDB) {
+		var err error
+		nominal, date, err = nextOccurrence(task, now.AddDate(0, 0, -1), cal)
+		if err != nil {
+			return err
+		}
+	}
+
+	shift, err := shiftDays(nominal, date)
+	if err != nil {
+		return err
+	}
+
+	task.Date, task.Shift = date, shift
+	return nil
+}
+
+// shiftDays returns the number of days an occurrence was moved by,
+// from the date computed by the repeat rule to the date the task falls on.
+func shiftDays(nominal, date string) (int, error) {
+	original, err := time.Parse(db.DateLayoutDB, nominal)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing the date '%s': %w", nominal, err)
+	}
+	shifted, err := time.Parse(db.DateLayoutDB, date)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing the shifted date '%s': %w", date, err)
+	}
+	return daysBetween(original, shifted), nil
+}
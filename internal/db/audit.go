@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The operations recorded in the audit log. A restore takes a task out of the trash, a purge deletes it
+// from the trash for good, and an undo brings it back to its state before a change.
+const (
+	AuditAdd     = "add"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditDone    = "done"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+	AuditUndo    = "undo"
+)
+
+// AuditEntry is an entry of the audit log: who did what to which task, and when.
+// Logged is the time of the operation in RFC 3339 format, in UTC. Actor is the subject of the token the request
+// was authenticated with, empty if there was none, and Remote is the address the request came from;
+// both are empty for the operations the server makes by itself, like purging the trash after its retention period.
+// Before and After are the task before and after the operation in JSON, as stored; null if there was no task.
+// The tasks in the trash count as no task, so a restore has none before it, except for a purge,
+// which has the task as it was in the trash before it.
+type AuditEntry struct {
+	ID        string          `json:"id"`
+	Logged    string          `json:"logged"`
+	Actor     string          `json:"actor"`
+	Remote    string          `json:"remote"`
+	Operation string          `json:"operation"`
+	TaskID    string          `json:"task_id"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+}
+
+// AuditFilter selects the entries returned by Audit. Actor, Operation and TaskID select the entries with those values,
+// and From and To the entries logged from one time to another, To excluded; empty values don't filter.
+// AfterID is the id of the last entry of the previous page, so that a page starts right after it.
+// Limit is the maximum number of entries, zero for all.
+type AuditFilter struct {
+	Actor     string
+	Operation string
+	TaskID    string
+	From      time.Time
+	To        time.Time
+	AfterID   string
+	Limit     int
+}
+
+// IsAuditOperation reports whether the given name is one of the operations recorded in the audit log.
+func IsAuditOperation(name string) bool {
+	switch name {
+	case AuditAdd, AuditUpdate, AuditDelete, AuditDone, AuditRestore, AuditPurge, AuditUndo:
+		return true
+	}
+	return false
+}
+
+// actorKey is the key of the context value that tells who makes the operations on tasks, see WithActor.
+type actorKey struct{}
+
+// actor is who makes the operations on tasks, as recorded in the audit log.
+type actor struct {
+	name, remote string
+}
+
+// WithActor returns a copy of the context with which the operations on tasks are recorded in the audit log
+// as made by the actor with the given name from the given remote address, see AuditEntry.
+// The operations made with a context without them are recorded with an empty actor and address,
+// like the ones the server makes by itself.
+func WithActor(ctx context.Context, name, remote string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor{name: name, remote: remote})
+}
+
+// audit appends an entry to the audit log for an operation on the task with the given id made by the actor
+// of the context, given the task before and after the operation, nil if there was none.
+// It's called in the transaction of the operation, so that an operation that can't be logged isn't made.
+func (s *sqlStore) audit(ctx context.Context, operation, taskID string, before, after *Task) error {
+	entry := &AuditEntry{Operation: operation, TaskID: taskID}
+
+	var err error
+	if entry.Before, err = json.Marshal(before); err != nil {
+		return fmt.Errorf("failed to log the %s of task '%s': %w", operation, taskID, err)
+	}
+	if entry.After, err = json.Marshal(after); err != nil {
+		return fmt.Errorf("failed to log the %s of task '%s': %w", operation, taskID, err)
+	}
+	a, _ := ctx.Value(actorKey{}).(actor)
+	entry.Actor, entry.Remote = a.name, a.remote
+	return s.AddAuditEntry(ctx, entry)
+}
+
+// AddAuditEntry appends an entry to the audit log, setting its id and the time it's logged.
+// The log is append-only: there's no way to change or delete its entries.
+func (s *sqlStore) AddAuditEntry(ctx context.Context, entry *AuditEntry) error {
+	taskID, err := parseID(entry.TaskID)
+	if err != nil {
+		return err
+	}
+
+	entry.Logged = time.Now().UTC().Format(time.RFC3339)
+	query := `INSERT INTO audit (logged, actor, remote, operation, task_id, state_before, state_after)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`
+	var id int64
+	err = s.queryRow(ctx, query, entry.Logged, entry.Actor, entry.Remote, entry.Operation, taskID,
+		string(entry.Before), string(entry.After)).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to log the %s of task with id '%s': %w", entry.Operation, entry.TaskID, err)
+	}
+	entry.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+// Audit returns the entries of the audit log selected by the filter, the most recent first.
+func (s *sqlStore) Audit(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error) {
+	var (
+		conds []string
+		args  []any
+	)
+	if filter.Actor != "" {
+		conds, args = append(conds, `actor = ?`), append(args, filter.Actor)
+	}
+	if filter.Operation != "" {
+		conds, args = append(conds, `operation = ?`), append(args, filter.Operation)
+	}
+	if filter.TaskID != "" {
+		taskID, err := parseID(filter.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		conds, args = append(conds, `task_id = ?`), append(args, taskID)
+	}
+	if !filter.From.IsZero() {
+		conds, args = append(conds, `logged >= ?`), append(args, filter.From.UTC().Format(time.RFC3339))
+	}
+	if !filter.To.IsZero() {
+		conds, args = append(conds, `logged < ?`), append(args, filter.To.UTC().Format(time.RFC3339))
+	}
+	if filter.AfterID != "" {
+		afterID, err := strconv.ParseInt(filter.AfterID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid audit entry id '%s': %w", filter.AfterID, ErrInvalidCursor)
+		}
+		conds, args = append(conds, `id < ?`), append(args, afterID)
+	}
+
+	query := `SELECT id, logged, actor, remote, operation, task_id, state_before, state_after FROM audit `
+	if len(conds) > 0 {
+		query += `WHERE ` + strings.Join(conds, " AND ") + ` `
+	}
+	query += `ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query, args = query+` LIMIT ?`, append(args, filter.Limit)
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		var (
+			entry         AuditEntry
+			before, after string
+		)
+		err := rows.Scan(&entry.ID, &entry.Logged, &entry.Actor, &entry.Remote, &entry.Operation, &entry.TaskID,
+			&before, &after)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entry.Before, entry.After = auditState(before), auditState(after)
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows while reading the audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// auditState returns the stored state of a task as JSON, null if there was no task.
+func auditState(state string) json.RawMessage {
+	if state == "" {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(state)
+}
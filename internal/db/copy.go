@@ -0,0 +1,233 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// CopyTasks copies all the tasks of the SQLite store, along with their completions, tags, lists and audit log,
+// into the PostgreSQL one in a single transaction, keeping their ids, so that links to the tasks stay valid.
+// It returns the number of the tasks copied.
+// The PostgreSQL store must be empty, its audit log included; its id sequences continue after the largest ids copied.
+func CopyTasks(ctx context.Context, from *SQLiteStore, to *PostgresStore) (int, error) {
+	var existing int
+	if err := to.queryRow(ctx, `SELECT COUNT(*) FROM scheduler`).Scan(&existing); err != nil {
+		return 0, fmt.Errorf("failed to count the tasks of the target database: %w", err)
+	}
+	if existing > 0 {
+		return 0, fmt.Errorf("the target database already has %d tasks", existing)
+	}
+	// The audit log is append-only, so the entries already there could neither be kept apart from the copied ones
+	// nor be deleted to make room for them.
+	if err := to.queryRow(ctx, `SELECT COUNT(*) FROM audit`).Scan(&existing); err != nil {
+		return 0, fmt.Errorf("failed to count the audit entries of the target database: %w", err)
+	}
+	if existing > 0 {
+		return 0, fmt.Errorf("the target database already has %d audit entries", existing)
+	}
+
+	rows, err := from.query(ctx, `SELECT id, date, time, title, comment, repeat, until, remaining, exceptions, workday, shift,
+		created, deleted, list_id, priority FROM scheduler ORDER BY id`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select the tasks to copy: %w", err)
+	}
+	defer rows.Close()
+
+	tx, err := to.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin the copy: %w", err)
+	}
+	defer tx.Rollback()
+
+	insert := to.dialect.rebind(`INSERT INTO scheduler
+		(id, date, time, title, comment, repeat, until, remaining, exceptions, workday, shift, created, deleted, list_id,
+		priority) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	copied := 0
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan the task to copy: %w", err)
+		}
+		id, err := parseID(task.ID)
+		if err != nil {
+			return 0, fmt.Errorf("invalid id '%s' of the task to copy: %w", task.ID, err)
+		}
+		listID, err := parseListID(task.ListID)
+		if err != nil {
+			return 0, fmt.Errorf("invalid list id '%s' of the task to copy: %w", task.ListID, err)
+		}
+		_, err = tx.ExecContext(ctx, insert, id, task.Date, task.Time, task.Title, task.Comment, task.Repeat,
+			task.Until, task.Remaining, joinExceptions(task.Exceptions), task.Workday, task.Shift, task.Created, task.Deleted,
+			listID, priorityLevel(task.Priority))
+		if err != nil {
+			return 0, fmt.Errorf("failed to copy task with id '%s': %w", task.ID, err)
+		}
+		copied++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to iterate the tasks to copy: %w", err)
+	}
+
+	if err := copyCompletions(ctx, from, tx, to.dialect); err != nil {
+		return 0, err
+	}
+	if err := copyTags(ctx, from, tx, to.dialect); err != nil {
+		return 0, err
+	}
+	if err := copyLists(ctx, from, tx, to.dialect); err != nil {
+		return 0, err
+	}
+	if err := copyAudit(ctx, from, tx, to.dialect); err != nil {
+		return 0, err
+	}
+
+	for _, table := range []string{"scheduler", "completions", "tags", "lists", "audit"} {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%[1]s', 'id'),
+			(SELECT COALESCE(MAX(id), 0) + 1 FROM %[1]s), false)`, table))
+		if err != nil {
+			return 0, fmt.Errorf("failed to move the id sequence of %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit the copy: %w", err)
+	}
+	return copied, nil
+}
+
+// copyCompletions copies all the completions of the SQLite store within the given transaction, keeping their ids.
+func copyCompletions(ctx context.Context, from *SQLiteStore, tx *sql.Tx, d *dialect) error {
+	rows, err := from.query(ctx, `SELECT id, task_id, title, date, time, completed FROM completions ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to select the completions to copy: %w", err)
+	}
+	defer rows.Close()
+
+	insert := d.rebind(`INSERT INTO completions (id, task_id, title, date, time, completed) VALUES (?, ?, ?, ?, ?, ?)`)
+	for rows.Next() {
+		var (
+			id, taskID int64
+			c          Completion
+		)
+		if err := rows.Scan(&id, &taskID, &c.Title, &c.Date, &c.Time, &c.Completed); err != nil {
+			return fmt.Errorf("failed to scan the completion to copy: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, insert, id, taskID, c.Title, c.Date, c.Time, c.Completed); err != nil {
+			return fmt.Errorf("failed to copy completion with id '%d': %w", id, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate the completions to copy: %w", err)
+	}
+	return nil
+}
+
+// copyTags copies all the tags of the SQLite store and the links between them and the tasks within the given
+// transaction, keeping their ids.
+func copyTags(ctx context.Context, from *SQLiteStore, tx *sql.Tx, d *dialect) error {
+	rows, err := from.query(ctx, `SELECT id, name FROM tags ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to select the tags to copy: %w", err)
+	}
+	defer rows.Close()
+
+	insert := d.rebind(`INSERT INTO tags (id, name) VALUES (?, ?)`)
+	for rows.Next() {
+		var (
+			id   int64
+			name string
+		)
+		if err := rows.Scan(&id, &name); err != nil {
+			return fmt.Errorf("failed to scan the tag to copy: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, insert, id, name); err != nil {
+			return fmt.Errorf("failed to copy tag '%s': %w", name, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate the tags to copy: %w", err)
+	}
+
+	links, err := from.query(ctx, `SELECT task_id, tag_id FROM task_tags`)
+	if err != nil {
+		return fmt.Errorf("failed to select the task tags to copy: %w", err)
+	}
+	defer links.Close()
+
+	insert = d.rebind(`INSERT INTO task_tags (task_id, tag_id) VALUES (?, ?)`)
+	for links.Next() {
+		var taskID, tagID int64
+		if err := links.Scan(&taskID, &tagID); err != nil {
+			return fmt.Errorf("failed to scan the task tag to copy: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, insert, taskID, tagID); err != nil {
+			return fmt.Errorf("failed to copy the tag of task with id '%d': %w", taskID, err)
+		}
+	}
+	if err := links.Err(); err != nil {
+		return fmt.Errorf("failed to iterate the task tags to copy: %w", err)
+	}
+	return nil
+}
+
+// copyLists copies all the lists of the SQLite store within the given transaction, keeping their ids.
+func copyLists(ctx context.Context, from *SQLiteStore, tx *sql.Tx, d *dialect) error {
+	rows, err := from.query(ctx, `SELECT id, name, created FROM lists ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to select the lists to copy: %w", err)
+	}
+	defer rows.Close()
+
+	insert := d.rebind(`INSERT INTO lists (id, name, created) VALUES (?, ?, ?)`)
+	for rows.Next() {
+		var (
+			id   int64
+			list List
+		)
+		if err := rows.Scan(&id, &list.Name, &list.Created); err != nil {
+			return fmt.Errorf("failed to scan the list to copy: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, insert, id, list.Name, list.Created); err != nil {
+			return fmt.Errorf("failed to copy list '%s': %w", list.Name, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate the lists to copy: %w", err)
+	}
+	return nil
+}
+
+// copyAudit copies all the entries of the audit log of the SQLite store within the given transaction, keeping their ids
+// and the times they were logged. The entries are only inserted, as the log is append-only.
+func copyAudit(ctx context.Context, from *SQLiteStore, tx *sql.Tx, d *dialect) error {
+	rows, err := from.query(ctx, `SELECT id, logged, actor, remote, operation, task_id, state_before, state_after
+		FROM audit ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to select the audit entries to copy: %w", err)
+	}
+	defer rows.Close()
+
+	insert := d.rebind(`INSERT INTO audit (id, logged, actor, remote, operation, task_id, state_before, state_after)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	for rows.Next() {
+		var (
+			id, taskID    int64
+			entry         AuditEntry
+			before, after string
+		)
+		err := rows.Scan(&id, &entry.Logged, &entry.Actor, &entry.Remote, &entry.Operation, &taskID, &before, &after)
+		if err != nil {
+			return fmt.Errorf("failed to scan the audit entry to copy: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, insert, id, entry.Logged, entry.Actor, entry.Remote, entry.Operation, taskID,
+			before, after)
+		if err != nil {
+			return fmt.Errorf("failed to copy audit entry with id '%d': %w", id, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate the audit entries to copy: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Completion records that a task was marked as done. Title is the title of the task at the time,
+// Date and Time are the date and time of day the completed occurrence was scheduled for,
+// and Completed is the time it was marked as done in RFC 3339 format, in UTC.
+// Completions outlive their tasks, which may be deleted or edited afterwards.
+type Completion struct {
+	ID        string `json:"id"`
+	TaskID    string `json:"task_id"`
+	Title     string `json:"title"`
+	Date      string `json:"date"`
+	Time      string `json:"time,omitempty"`
+	Completed string `json:"completed"`
+}
+
+// HistoryFilter selects the completions returned by History.
+// TaskID is the id of the task they belong to, and From and To are the first and the last scheduled dates
+// in DateLayoutDB format; empty values don't filter. Limit is the maximum number of completions, zero for all.
+type HistoryFilter struct {
+	TaskID string
+	From   string
+	To     string
+	Limit  int
+}
+
+// CompleteTask records the completion of a task and moves the task on in a single transaction:
+// it updates the task to next, its following occurrence, or moves it to the trash if next is nil.
+// It sets the id and the completion time of the completion. Undoing the change, see UndoChange,
+// brings the task back and removes the completion. The completion is recorded in the audit log, see WithActor.
+// If the task doesn't exist, it will return ErrTaskNotFound and record nothing.
+func (s *sqlStore) CompleteTask(ctx context.Context, done *Completion, next *Task) error {
+	taskID, err := parseID(done.TaskID)
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(ctx, func(tx *sqlStore) error {
+		previous, err := tx.GetTask(ctx, done.TaskID)
+		if err != nil {
+			return err
+		}
+		if next != nil {
+			if err := tx.updateTask(ctx, next); err != nil {
+				return err
+			}
+		} else if err := tx.deleteTask(ctx, done.TaskID); err != nil {
+			return err
+		}
+
+		done.Completed = time.Now().UTC().Format(time.RFC3339)
+		query := `INSERT INTO completions (task_id, title, date, time, completed) VALUES (?, ?, ?, ?, ?) RETURNING id`
+		var id int64
+		err = tx.queryRow(ctx, query, taskID, done.Title, done.Date, done.Time, done.Completed).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to record the completion of task with id '%s': %w", done.TaskID, err)
+		}
+		done.ID = strconv.FormatInt(id, 10)
+		if err := tx.recordChange(ctx, ChangeDone, previous, id); err != nil {
+			return err
+		}
+
+		var after *Task
+		if next != nil {
+			if after, err = tx.GetTask(ctx, done.TaskID); err != nil {
+				return err
+			}
+		}
+		return tx.audit(ctx, AuditDone, done.TaskID, previous, after)
+	})
+}
+
+// History returns the completions selected by the filter, the most recent first.
+func (s *sqlStore) History(ctx context.Context, filter HistoryFilter) ([]*Completion, error) {
+	var (
+		conds []string
+		args  []any
+	)
+	if filter.TaskID != "" {
+		taskID, err := parseID(filter.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		conds, args = append(conds, `task_id = ?`), append(args, taskID)
+	}
+	if filter.From != "" {
+		conds, args = append(conds, `date >= ?`), append(args, filter.From)
+	}
+	if filter.To != "" {
+		conds, args = append(conds, `date <= ?`), append(args, filter.To)
+	}
+
+	query := `SELECT id, task_id, title, date, time, completed FROM completions `
+	if len(conds) > 0 {
+		query += `WHERE ` + strings.Join(conds, " AND ") + ` `
+	}
+	query += `ORDER BY completed DESC, id DESC`
+	if filter.Limit > 0 {
+		query, args = query+` LIMIT ?`, append(args, filter.Limit)
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select completions: %w", err)
+	}
+	defer rows.Close()
+
+	completions := []*Completion{}
+	for rows.Next() {
+		var c Completion
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.Title, &c.Date, &c.Time, &c.Completed); err != nil {
+			return nil, fmt.Errorf("failed to scan completion while building history: %w", err)
+		}
+		completions = append(completions, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows while building history: %w", err)
+	}
+	return completions, nil
+}
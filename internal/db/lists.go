@@ -0,0 +1,323 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrListNotFound   = errors.New("list not found")
+	ErrListExists     = errors.New("list already exists")
+	ErrMoveToSameList = errors.New("can't move the tasks to the list being deleted")
+)
+
+// List is a named group of tasks, such as "Home" or "Work". A task belongs to at most one list, see Task.ListID.
+// Created is the time the list was added in RFC 3339 format, in UTC. Count isn't stored: it's the number of the tasks
+// of the list, except the ones in the trash.
+type List struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Created string `json:"created,omitempty"`
+	Count   int    `json:"count"`
+}
+
+const listQuery = `SELECT l.id, l.name, l.created, COUNT(s.id) FROM lists l
+	LEFT JOIN scheduler s ON s.list_id = l.id AND s.deleted = '' `
+
+// Lists returns all the lists ordered by name, each with the number of its tasks.
+func (s *sqlStore) Lists(ctx context.Context) ([]*List, error) {
+	rows, err := s.query(ctx, listQuery+`GROUP BY l.id, l.name, l.created ORDER BY l.name, l.id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select lists: %w", err)
+	}
+	defer rows.Close()
+
+	lists := []*List{}
+	for rows.Next() {
+		var list List
+		if err := rows.Scan(&list.ID, &list.Name, &list.Created, &list.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan list while building the list of lists: %w", err)
+		}
+		lists = append(lists, &list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows while building the list of lists: %w", err)
+	}
+	return lists, nil
+}
+
+// GetList returns the list with the given id, or ErrListNotFound if there's none.
+func (s *sqlStore) GetList(ctx context.Context, id string) (*List, error) {
+	listID, err := parseListID(id)
+	if err != nil {
+		return nil, err
+	}
+	if listID == 0 {
+		return nil, ErrEmptyID
+	}
+
+	var list List
+	err = s.queryRow(ctx, listQuery+`WHERE l.id = ? GROUP BY l.id, l.name, l.created`, listID).
+		Scan(&list.ID, &list.Name, &list.Created, &list.Count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no list with id '%s': %w", id, ErrListNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get list: %w", err)
+	}
+	return &list, nil
+}
+
+// AddList adds a new list and returns its id, setting its creation time.
+// If there's already a list with the same name, it will return ErrListExists.
+func (s *sqlStore) AddList(ctx context.Context, list *List) (int64, error) {
+	list.Created = time.Now().UTC().Format(time.RFC3339)
+
+	var id int64
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		if err := tx.checkListName(ctx, list.Name, 0); err != nil {
+			return err
+		}
+		err := tx.queryRow(ctx, `INSERT INTO lists (name, created) VALUES (?, ?) RETURNING id`, list.Name, list.Created).
+			Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to add list '%s': %w", list.Name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdateList renames the list with the given id.
+// If there's no such list, it will return ErrListNotFound, and if another list has the new name, ErrListExists.
+func (s *sqlStore) UpdateList(ctx context.Context, list *List) error {
+	listID, err := parseListID(list.ID)
+	if err != nil {
+		return err
+	}
+	if listID == 0 {
+		return ErrEmptyID
+	}
+
+	return s.inTx(ctx, func(tx *sqlStore) error {
+		if err := tx.checkListName(ctx, list.Name, listID); err != nil {
+			return err
+		}
+		res, err := tx.exec(ctx, `UPDATE lists SET name = ? WHERE id = ?`, list.Name, listID)
+		if err != nil {
+			return fmt.Errorf("failed to update list with id '%s': %w", list.ID, err)
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected while updating list: %w", err)
+		}
+		if count != 1 {
+			return fmt.Errorf(`incorrect id for updating list '%s': %w`, list.ID, ErrListNotFound)
+		}
+		return nil
+	})
+}
+
+// DeleteList deletes the list with the given id in a single transaction, together with its tasks if cascade is true:
+// they're moved to the trash, like with DeleteTask. Otherwise, its tasks are moved to the list with the id moveTo,
+// or out of any list if moveTo is empty. Either way, the changes of the tasks are recorded so that they can be undone,
+// see UndoChange, as well as in the audit log, see WithActor, and it returns the tasks, except the ones already
+// in the trash, as they were before.
+// If either list doesn't exist, it will return ErrListNotFound, and if moveTo is the list being deleted,
+// ErrMoveToSameList; either way, it changes nothing.
+func (s *sqlStore) DeleteList(ctx context.Context, id, moveTo string, cascade bool) ([]*Task, error) {
+	var tasks []*Task
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		list, err := tx.GetList(ctx, id)
+		if err != nil {
+			return err
+		}
+		listID, _ := parseListID(list.ID)
+		var toID int64
+		if !cascade {
+			if toID, err = tx.checkList(ctx, moveTo); err != nil {
+				return err
+			}
+			if toID == listID {
+				return fmt.Errorf("list with id '%s': %w", id, ErrMoveToSameList)
+			}
+		}
+
+		if tasks, err = tx.listTasks(ctx, listID); err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			operation := ChangeUpdate
+			if cascade {
+				if err := tx.deleteTask(ctx, task.ID); err != nil {
+					return err
+				}
+				operation = ChangeDelete
+			}
+			if err := tx.recordChange(ctx, operation, task, 0); err != nil {
+				return err
+			}
+		}
+
+		// The tasks already in the trash are moved as well, so that they aren't restored into a list that's gone.
+		if _, err := tx.exec(ctx, `UPDATE scheduler SET list_id = ? WHERE list_id = ?`, toID, listID); err != nil {
+			return fmt.Errorf("failed to move the tasks of list with id '%s': %w", id, err)
+		}
+		for _, task := range tasks {
+			if cascade {
+				if err := tx.audit(ctx, AuditDelete, task.ID, task, nil); err != nil {
+					return err
+				}
+				continue
+			}
+			moved, err := tx.GetTask(ctx, task.ID)
+			if err != nil {
+				return err
+			}
+			if err := tx.audit(ctx, AuditUpdate, task.ID, task, moved); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.exec(ctx, `DELETE FROM lists WHERE id = ?`, listID); err != nil {
+			return fmt.Errorf("failed to delete list with id '%s': %w", id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// MoveTasks moves the tasks with the given ids to the list with the given id, or out of any list if it's empty,
+// in a single transaction, recording the changes so that they can be undone, see UndoChange,
+// as well as in the audit log, see WithActor.
+// It returns the tasks that were moved as they were before; the ones already in the list are left as they are.
+// If the list or one of the tasks doesn't exist, it will return ErrListNotFound or ErrTaskNotFound and move nothing.
+func (s *sqlStore) MoveTasks(ctx context.Context, ids []string, listID string) ([]*Task, error) {
+	moved := []*Task{}
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		toID, err := tx.checkList(ctx, listID)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			task, err := tx.GetTask(ctx, id)
+			if err != nil {
+				return err
+			}
+			if task.ListID == formatListID(toID) {
+				continue
+			}
+			taskID, err := parseID(task.ID)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.exec(ctx, `UPDATE scheduler SET list_id = ? WHERE id = ?`, toID, taskID); err != nil {
+				return fmt.Errorf("failed to move task with id '%s': %w", id, err)
+			}
+			if err := tx.recordChange(ctx, ChangeUpdate, task, 0); err != nil {
+				return err
+			}
+			after, err := tx.GetTask(ctx, task.ID)
+			if err != nil {
+				return err
+			}
+			if err := tx.audit(ctx, AuditUpdate, task.ID, task, after); err != nil {
+				return err
+			}
+			moved = append(moved, task)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+// listTasks returns the tasks of the list with the given id, except the ones in the trash, ordered by id.
+func (s *sqlStore) listTasks(ctx context.Context, listID int64) ([]*Task, error) {
+	query := `SELECT id, date, time, title, comment, repeat, until, remaining, exceptions, workday, shift, created, deleted,
+		list_id, priority FROM scheduler WHERE list_id = ? AND deleted = '' ORDER BY id`
+	rows, err := s.query(ctx, query, listID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select the tasks of list with id '%d': %w", listID, err)
+	}
+	defer rows.Close()
+
+	tasks := []*Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task while building the tasks of a list: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows while building the tasks of a list: %w", err)
+	}
+	if err := s.loadTags(ctx, tasks...); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// checkList returns the id of the list with the given id in the database, or zero if the id is empty,
+// which means no list. If there's no such list, it will return ErrListNotFound.
+func (s *sqlStore) checkList(ctx context.Context, id string) (int64, error) {
+	listID, err := parseListID(id)
+	if err != nil || listID == 0 {
+		return 0, err
+	}
+
+	var exists int
+	if err := s.queryRow(ctx, `SELECT COUNT(*) FROM lists WHERE id = ?`, listID).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("failed to check list with id '%s': %w", id, err)
+	}
+	if exists == 0 {
+		return 0, fmt.Errorf("no list with id '%s': %w", id, ErrListNotFound)
+	}
+	return listID, nil
+}
+
+// checkListName returns ErrListExists if a list other than the one with the given id has the given name.
+func (s *sqlStore) checkListName(ctx context.Context, name string, listID int64) error {
+	var taken int
+	err := s.queryRow(ctx, `SELECT COUNT(*) FROM lists WHERE name = ? AND id <> ?`, name, listID).Scan(&taken)
+	if err != nil {
+		return fmt.Errorf("failed to check list name '%s': %w", name, err)
+	}
+	if taken > 0 {
+		return fmt.Errorf("list '%s': %w", name, ErrListExists)
+	}
+	return nil
+}
+
+// parseListID parses the id of a list, an empty id being zero, which means no list.
+// An id that isn't a number is reported as ErrListNotFound.
+func parseListID(id string) (int64, error) {
+	if id == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("no list with id '%s': %w", id, ErrListNotFound)
+	}
+	return n, nil
+}
+
+// formatListID formats the id of a list as it's stored, zero meaning no list.
+func formatListID(listID int64) string {
+	if listID == 0 {
+		return ""
+	}
+	return strconv.FormatInt(listID, 10)
+}
@@ -0,0 +1,479 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const schemaVersionTable = `CREATE TABLE IF NOT EXISTS "schema_version" (
+    version INTEGER PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    applied_at TEXT NOT NULL DEFAULT ''
+);`
+
+// Migration is a single step of the database schema, taking it from the previous version to Version.
+type Migration struct {
+	Version     int
+	Description string
+	up          func(ctx context.Context, tx *sql.Tx) error
+}
+
+// SchemaStatus is the version of a database schema, the latest version known to the program
+// and the migrations to apply to get there.
+type SchemaStatus struct {
+	Version int
+	Latest  int
+	Pending []Migration
+}
+
+// ErrSchemaTooNew is returned for a database migrated by a newer version of the program.
+var ErrSchemaTooNew = errors.New("database schema is newer than this program supports")
+
+// sqliteMigrations are the steps of the SQLite database schema in the order they are applied.
+// Databases created before the schema was versioned have no version at all, so every step
+// must also work on a database that already has some of its changes: tables and indexes are created
+// only if they don't exist, and columns are added only if they are missing.
+var sqliteMigrations = []Migration{
+	{Version: 1, Description: "create the scheduler table", up: execSteps(
+		`CREATE TABLE IF NOT EXISTS "scheduler" (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date CHAR(8) NOT NULL DEFAULT '',
+    title VARCHAR(64) NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    repeat VARCHAR(128) NOT NULL DEFAULT ''
+);`,
+		`CREATE INDEX IF NOT EXISTS scheduler_date ON scheduler(date);`,
+	)},
+	{Version: 2, Description: "add the until date and the remaining count", up: addColumns("scheduler",
+		`until CHAR(8) NOT NULL DEFAULT ''`,
+		`remaining INTEGER NOT NULL DEFAULT 0`,
+	)},
+	{Version: 3, Description: "add the exception dates", up: addColumns("scheduler",
+		`exceptions TEXT NOT NULL DEFAULT ''`,
+	)},
+	{Version: 4, Description: "add the working day option", up: addColumns("scheduler",
+		`workday INTEGER NOT NULL DEFAULT 0`,
+		`shift INTEGER NOT NULL DEFAULT 0`,
+	)},
+	{Version: 5, Description: "add the time of day", up: addColumns("scheduler",
+		`time CHAR(5) NOT NULL DEFAULT ''`,
+	)},
+	{Version: 6, Description: "add the full-text search index", up: execSteps(
+		`CREATE VIRTUAL TABLE IF NOT EXISTS scheduler_fts USING fts5(title, comment,
+    content='scheduler', content_rowid='id', tokenize='unicode61 remove_diacritics 2');`,
+		`CREATE TRIGGER IF NOT EXISTS scheduler_fts_insert AFTER INSERT ON scheduler BEGIN
+    INSERT INTO scheduler_fts(rowid, title, comment) VALUES (new.id, new.title, new.comment);
+END;`,
+		`CREATE TRIGGER IF NOT EXISTS scheduler_fts_delete AFTER DELETE ON scheduler BEGIN
+    INSERT INTO scheduler_fts(scheduler_fts, rowid, title, comment) VALUES ('delete', old.id, old.title, old.comment);
+END;`,
+		`CREATE TRIGGER IF NOT EXISTS scheduler_fts_update AFTER UPDATE OF title, comment ON scheduler BEGIN
+    INSERT INTO scheduler_fts(scheduler_fts, rowid, title, comment) VALUES ('delete', old.id, old.title, old.comment);
+    INSERT INTO scheduler_fts(rowid, title, comment) VALUES (new.id, new.title, new.comment);
+END;`,
+		// The index is built from scratch, since the table may already have tasks.
+		`INSERT INTO scheduler_fts(scheduler_fts) VALUES ('rebuild');`,
+	)},
+	{Version: 7, Description: "add the creation time", up: addColumns("scheduler",
+		`created TEXT NOT NULL DEFAULT ''`,
+	)},
+	{Version: 8, Description: "add the trash", up: func(ctx context.Context, tx *sql.Tx) error {
+		if err := addColumns("scheduler", `deleted TEXT NOT NULL DEFAULT ''`)(ctx, tx); err != nil {
+			return err
+		}
+		return execSteps(`CREATE INDEX IF NOT EXISTS scheduler_deleted ON scheduler(deleted);`)(ctx, tx)
+	}},
+	{Version: 9, Description: "create the completions table", up: execSteps(
+		`CREATE TABLE IF NOT EXISTS completions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    title VARCHAR(64) NOT NULL DEFAULT '',
+    date CHAR(8) NOT NULL DEFAULT '',
+    time CHAR(5) NOT NULL DEFAULT '',
+    completed TEXT NOT NULL DEFAULT ''
+);`,
+		`CREATE INDEX IF NOT EXISTS completions_task ON completions(task_id, date);`,
+		`CREATE INDEX IF NOT EXISTS completions_completed ON completions(completed);`,
+	)},
+	{Version: 10, Description: "create the changes table", up: execSteps(
+		`CREATE TABLE IF NOT EXISTS changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    operation VARCHAR(16) NOT NULL DEFAULT '',
+    completion_id INTEGER NOT NULL DEFAULT 0,
+    changed TEXT NOT NULL DEFAULT '',
+    undone TEXT NOT NULL DEFAULT '',
+    date CHAR(8) NOT NULL DEFAULT '',
+    time CHAR(5) NOT NULL DEFAULT '',
+    title VARCHAR(64) NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    repeat VARCHAR(128) NOT NULL DEFAULT '',
+    until CHAR(8) NOT NULL DEFAULT '',
+    remaining INTEGER NOT NULL DEFAULT 0,
+    exceptions TEXT NOT NULL DEFAULT '',
+    workday INTEGER NOT NULL DEFAULT 0,
+    shift INTEGER NOT NULL DEFAULT 0
+);`,
+		`CREATE INDEX IF NOT EXISTS changes_task ON changes(task_id);`,
+		`CREATE INDEX IF NOT EXISTS changes_changed ON changes(changed);`,
+	)},
+	{Version: 11, Description: "create the audit log", up: execSteps(
+		`CREATE TABLE IF NOT EXISTS audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    logged TEXT NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    remote TEXT NOT NULL DEFAULT '',
+    operation VARCHAR(16) NOT NULL DEFAULT '',
+    task_id INTEGER NOT NULL DEFAULT 0,
+    state_before TEXT NOT NULL DEFAULT '',
+    state_after TEXT NOT NULL DEFAULT ''
+);`,
+		`CREATE INDEX IF NOT EXISTS audit_logged ON audit(logged);`,
+		`CREATE INDEX IF NOT EXISTS audit_task ON audit(task_id);`,
+		// The log is append-only: its entries can be neither changed nor deleted.
+		`CREATE TRIGGER IF NOT EXISTS audit_no_update BEFORE UPDATE ON audit BEGIN
+    SELECT RAISE(ABORT, 'the audit log is append-only');
+END;`,
+		`CREATE TRIGGER IF NOT EXISTS audit_no_delete BEFORE DELETE ON audit BEGIN
+    SELECT RAISE(ABORT, 'the audit log is append-only');
+END;`,
+	)},
+	{Version: 12, Description: "add the tags", up: func(ctx context.Context, tx *sql.Tx) error {
+		err := execSteps(
+			`CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(32) NOT NULL UNIQUE
+);`,
+			`CREATE TABLE IF NOT EXISTS task_tags (
+    task_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (task_id, tag_id)
+);`,
+			`CREATE INDEX IF NOT EXISTS task_tags_tag ON task_tags(tag_id);`,
+		)(ctx, tx)
+		if err != nil {
+			return err
+		}
+		return addColumns("changes", `tags TEXT NOT NULL DEFAULT ''`)(ctx, tx)
+	}},
+	{Version: 13, Description: "add the lists", up: func(ctx context.Context, tx *sql.Tx) error {
+		err := execSteps(`CREATE TABLE IF NOT EXISTS lists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(64) NOT NULL UNIQUE,
+    created TEXT NOT NULL DEFAULT ''
+);`)(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if err := addColumns("scheduler", `list_id INTEGER NOT NULL DEFAULT 0`)(ctx, tx); err != nil {
+			return err
+		}
+		if err := addColumns("changes", `list_id INTEGER NOT NULL DEFAULT 0`)(ctx, tx); err != nil {
+			return err
+		}
+		return execSteps(`CREATE INDEX IF NOT EXISTS scheduler_list ON scheduler(list_id);`)(ctx, tx)
+	}},
+	{Version: 14, Description: "add the priority", up: func(ctx context.Context, tx *sql.Tx) error {
+		if err := addColumns("scheduler", `priority INTEGER NOT NULL DEFAULT 0`)(ctx, tx); err != nil {
+			return err
+		}
+		return addColumns("changes", `priority INTEGER NOT NULL DEFAULT 0`)(ctx, tx)
+	}},
+}
+
+// postgresMigrations are the steps of the PostgreSQL database schema, matching sqliteMigrations version by version.
+// Texts are unbounded and unpadded, since PostgreSQL, unlike SQLite, enforces the length of VARCHAR and pads CHAR.
+var postgresMigrations = []Migration{
+	{Version: 1, Description: "create the scheduler table", up: execSteps(
+		`CREATE TABLE IF NOT EXISTS scheduler (
+    id BIGSERIAL PRIMARY KEY,
+    date TEXT NOT NULL DEFAULT '',
+    title TEXT NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    repeat TEXT NOT NULL DEFAULT ''
+);`,
+		`CREATE INDEX IF NOT EXISTS scheduler_date ON scheduler(date);`,
+	)},
+	{Version: 2, Description: "add the until date and the remaining count", up: execSteps(
+		`ALTER TABLE scheduler ADD COLUMN IF NOT EXISTS until TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS remaining INTEGER NOT NULL DEFAULT 0;`,
+	)},
+	{Version: 3, Description: "add the exception dates", up: execSteps(
+		`ALTER TABLE scheduler ADD COLUMN IF NOT EXISTS exceptions TEXT NOT NULL DEFAULT '';`,
+	)},
+	{Version: 4, Description: "add the working day option", up: execSteps(
+		`ALTER TABLE scheduler ADD COLUMN IF NOT EXISTS workday BOOLEAN NOT NULL DEFAULT FALSE,
+			ADD COLUMN IF NOT EXISTS shift INTEGER NOT NULL DEFAULT 0;`,
+	)},
+	{Version: 5, Description: "add the time of day", up: execSteps(
+		`ALTER TABLE scheduler ADD COLUMN IF NOT EXISTS time TEXT NOT NULL DEFAULT '';`,
+	)},
+	{Version: 6, Description: "add the full-text search index", up: execSteps(
+		`ALTER TABLE scheduler ADD COLUMN IF NOT EXISTS search tsvector
+			GENERATED ALWAYS AS (to_tsvector('simple', title || ' ' || comment)) STORED;`,
+		`CREATE INDEX IF NOT EXISTS scheduler_search ON scheduler USING GIN (search);`,
+	)},
+	{Version: 7, Description: "add the creation time", up: execSteps(
+		`ALTER TABLE scheduler ADD COLUMN IF NOT EXISTS created TEXT NOT NULL DEFAULT '';`,
+	)},
+	{Version: 8, Description: "add the trash", up: execSteps(
+		`ALTER TABLE scheduler ADD COLUMN IF NOT EXISTS deleted TEXT NOT NULL DEFAULT '';`,
+		`CREATE INDEX IF NOT EXISTS scheduler_deleted ON scheduler(deleted);`,
+	)},
+	{Version: 9, Description: "create the completions table", up: execSteps(
+		`CREATE TABLE IF NOT EXISTS completions (
+    id BIGSERIAL PRIMARY KEY,
+    task_id BIGINT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    date TEXT NOT NULL DEFAULT '',
+    time TEXT NOT NULL DEFAULT '',
+    completed TEXT NOT NULL DEFAULT ''
+);`,
+		`CREATE INDEX IF NOT EXISTS completions_task ON completions(task_id, date);`,
+		`CREATE INDEX IF NOT EXISTS completions_completed ON completions(completed);`,
+	)},
+	{Version: 10, Description: "create the changes table", up: execSteps(
+		`CREATE TABLE IF NOT EXISTS changes (
+    id BIGSERIAL PRIMARY KEY,
+    task_id BIGINT NOT NULL,
+    operation TEXT NOT NULL DEFAULT '',
+    completion_id BIGINT NOT NULL DEFAULT 0,
+    changed TEXT NOT NULL DEFAULT '',
+    undone TEXT NOT NULL DEFAULT '',
+    date TEXT NOT NULL DEFAULT '',
+    time TEXT NOT NULL DEFAULT '',
+    title TEXT NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    repeat TEXT NOT NULL DEFAULT '',
+    until TEXT NOT NULL DEFAULT '',
+    remaining INTEGER NOT NULL DEFAULT 0,
+    exceptions TEXT NOT NULL DEFAULT '',
+    workday BOOLEAN NOT NULL DEFAULT FALSE,
+    shift INTEGER NOT NULL DEFAULT 0
+);`,
+		`CREATE INDEX IF NOT EXISTS changes_task ON changes(task_id);`,
+		`CREATE INDEX IF NOT EXISTS changes_changed ON changes(changed);`,
+	)},
+	{Version: 11, Description: "create the audit log", up: execSteps(
+		`CREATE TABLE IF NOT EXISTS audit (
+    id BIGSERIAL PRIMARY KEY,
+    logged TEXT NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    remote TEXT NOT NULL DEFAULT '',
+    operation TEXT NOT NULL DEFAULT '',
+    task_id BIGINT NOT NULL DEFAULT 0,
+    state_before TEXT NOT NULL DEFAULT '',
+    state_after TEXT NOT NULL DEFAULT ''
+);`,
+		`CREATE INDEX IF NOT EXISTS audit_logged ON audit(logged);`,
+		`CREATE INDEX IF NOT EXISTS audit_task ON audit(task_id);`,
+		// The log is append-only: its entries can be neither changed nor deleted.
+		`CREATE OR REPLACE FUNCTION audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'the audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS audit_append_only ON audit;`,
+		`CREATE TRIGGER audit_append_only BEFORE UPDATE OR DELETE ON audit
+    FOR EACH ROW EXECUTE FUNCTION audit_append_only();`,
+	)},
+	{Version: 12, Description: "add the tags", up: execSteps(
+		`CREATE TABLE IF NOT EXISTS tags (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);`,
+		`CREATE TABLE IF NOT EXISTS task_tags (
+    task_id BIGINT NOT NULL,
+    tag_id BIGINT NOT NULL,
+    PRIMARY KEY (task_id, tag_id)
+);`,
+		`CREATE INDEX IF NOT EXISTS task_tags_tag ON task_tags(tag_id);`,
+		`ALTER TABLE changes ADD COLUMN IF NOT EXISTS tags TEXT NOT NULL DEFAULT '';`,
+	)},
+	{Version: 13, Description: "add the lists", up: execSteps(
+		`CREATE TABLE IF NOT EXISTS lists (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created TEXT NOT NULL DEFAULT ''
+);`,
+		`ALTER TABLE scheduler ADD COLUMN IF NOT EXISTS list_id BIGINT NOT NULL DEFAULT 0;`,
+		`ALTER TABLE changes ADD COLUMN IF NOT EXISTS list_id BIGINT NOT NULL DEFAULT 0;`,
+		`CREATE INDEX IF NOT EXISTS scheduler_list ON scheduler(list_id);`,
+	)},
+	{Version: 14, Description: "add the priority", up: execSteps(
+		`ALTER TABLE scheduler ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE changes ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;`,
+	)},
+}
+
+// latestVersion returns the version of the schema once all the migrations of the dialect are applied.
+func (d *dialect) latestVersion() int {
+	return d.migrations[len(d.migrations)-1].Version
+}
+
+// ReadSchemaStatus returns the schema status of the SQLite database in the given file without changing it.
+// A file that doesn't exist yet has the version 0, so all the migrations are pending.
+func ReadSchemaStatus(ctx context.Context, dbFile string) (*SchemaStatus, error) {
+	if _, err := os.Stat(dbFile); errors.Is(err, os.ErrNotExist) {
+		return &SchemaStatus{Latest: sqliteDialect.latestVersion(), Pending: sqliteDialect.migrations}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error checking database file '%s': %w", dbFile, err)
+	}
+
+	db, err := sql.Open(driverSQLite, "file:"+dbFile+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("error opening database '%s': %w", dbFile, err)
+	}
+	defer db.Close()
+
+	return schemaStatus(ctx, db, sqliteDialect)
+}
+
+// ReadPostgresSchemaStatus returns the schema status of the PostgreSQL database with the given connection string
+// without changing it.
+func ReadPostgresSchemaStatus(ctx context.Context, dsn string) (*SchemaStatus, error) {
+	db, err := sql.Open(driverPostgres, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening PostgreSQL database: %w", err)
+	}
+	defer db.Close()
+
+	return schemaStatus(ctx, db, postgresDialect)
+}
+
+// schemaStatus returns the schema status of the given database.
+func schemaStatus(ctx context.Context, db *sql.DB, d *dialect) (*SchemaStatus, error) {
+	version, err := schemaVersion(ctx, db, d)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &SchemaStatus{Version: version, Latest: d.latestVersion()}
+	for _, m := range d.migrations {
+		if m.Version > version {
+			status.Pending = append(status.Pending, m)
+		}
+	}
+	return status, nil
+}
+
+// schemaVersion returns the version of the schema of the given database, 0 if it isn't versioned yet.
+func schemaVersion(ctx context.Context, db *sql.DB, d *dialect) (int, error) {
+	var exists int
+	err := db.QueryRowContext(ctx, d.versionTableExists).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up the schema version table: %w", err)
+	}
+	if exists == 0 {
+		return 0, nil
+	}
+
+	var version int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get the schema version: %w", err)
+	}
+	return version, nil
+}
+
+// migrate applies the pending migrations to the given database, each one in its own transaction,
+// and returns the ones it applied. It refuses to touch a database with a schema newer than the latest known one.
+func migrate(ctx context.Context, db *sql.DB, d *dialect) ([]Migration, error) {
+	if _, err := db.ExecContext(ctx, schemaVersionTable); err != nil {
+		return nil, fmt.Errorf("failed to create the schema version table: %w", err)
+	}
+
+	status, err := schemaStatus(ctx, db, d)
+	if err != nil {
+		return nil, err
+	}
+	if status.Version > status.Latest {
+		return nil, fmt.Errorf("%w: version %d, latest known %d", ErrSchemaTooNew, status.Version, status.Latest)
+	}
+
+	for i, m := range status.Pending {
+		if err := applyMigration(ctx, db, d, m); err != nil {
+			return status.Pending[:i], err
+		}
+	}
+	return status.Pending, nil
+}
+
+// applyMigration applies a single migration and records its version in one transaction.
+func applyMigration(ctx context.Context, db *sql.DB, d *dialect, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	if err := m.up(ctx, tx); err != nil {
+		return fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Description, err)
+	}
+	_, err = tx.ExecContext(ctx, d.rebind(`INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`),
+		m.Version, m.Description, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+	}
+	return nil
+}
+
+// execSteps returns a migration step executing the given statements in order.
+func execSteps(stmts ...string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		for _, stmt := range stmts {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumns returns an SQLite migration step adding the given column definitions to the table,
+// skipping the columns it already has.
+func addColumns(table string, columns ...string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		existing, err := tableColumns(ctx, tx, table)
+		if err != nil {
+			return err
+		}
+		for _, column := range columns {
+			name, _, _ := strings.Cut(column, " ")
+			if existing[name] {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s`, table, column)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// tableColumns returns the set of the column names of the table.
+func tableColumns(ctx context.Context, tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the columns of '%s': %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan a column of '%s': %w", table, err)
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
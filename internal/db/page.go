@@ -0,0 +1,161 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The orders of the task list. SortPriority puts the highest priorities first, then orders by date.
+// The default one is SortDate with the overdue tasks of high priority and above first, unless the search query
+// is a plain full-text one, whose matches come best first.
+const (
+	SortDate     = "date"
+	SortTitle    = "title"
+	SortID       = "id"
+	SortCreated  = "created"
+	SortPriority = "priority"
+	// sortDefault is the default order, whose sort key depends on the date of today, see sortExprs.
+	sortDefault = "default"
+	// sortRank is the order of a plain full-text search, which has no sort key, so its cursors are offsets.
+	sortRank = "rank"
+)
+
+// ErrInvalidCursor is returned by Tasks for a cursor that isn't one of its own, or one of a different order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// sortColumns are the columns of the sort key of each order, all of which end with the id, so that the key is unique.
+// The keys are compared as text, except the id, so the priority is turned into a digit that's lower
+// for a higher priority, so that the highest priorities come first in ascending order.
+var sortColumns = map[string][]string{
+	SortDate:     {"date", "time", "id"},
+	SortTitle:    {"title", "id"},
+	SortID:       {"id"},
+	SortCreated:  {"created", "id"},
+	SortPriority: {"CAST(4 - priority AS TEXT)", "date", "time", "id"},
+}
+
+// overdueFirst is the first column of the sort key of sortDefault: '0' for the tasks of high priority and above
+// dated before today, which is formatted into it, and '1' for the rest.
+const overdueFirst = `CASE WHEN priority >= 3 AND date < '%s' THEN '0' ELSE '1' END`
+
+// TaskPage is a page of the task list.
+// NextCursor is the cursor of the next page, empty on the last one, and Total is the number of the tasks on all pages.
+type TaskPage struct {
+	Tasks      []*Task
+	NextCursor string
+	Total      int
+}
+
+// cursor points at the last task of a page: it holds the sort key of the task, or for sortRank the number of
+// the tasks on the pages up to it. Keyset cursors stay valid while tasks are added, edited and deleted:
+// the next page starts right after the key, wherever the task has gone.
+type cursor struct {
+	Sort   string   `json:"s"`
+	Desc   bool     `json:"d,omitempty"`
+	Key    []string `json:"k,omitempty"`
+	Offset int      `json:"o,omitempty"`
+}
+
+// isDate reports whether the value is a date in DateLayoutDB format.
+func isDate(value string) bool {
+	_, err := time.Parse(DateLayoutDB, value)
+	return err == nil
+}
+
+// sortExprs returns the columns of the sort key of the order, given the date of today in DateLayoutDB format
+// for sortDefault, which has to be a valid date, since it's part of the query.
+func sortExprs(sort, today string) []string {
+	if sort == sortDefault {
+		return append([]string{fmt.Sprintf(overdueFirst, today)}, sortColumns[SortDate]...)
+	}
+	return sortColumns[sort]
+}
+
+// IsSort reports whether the order is one of the orders of the task list.
+func IsSort(order string) bool {
+	_, ok := sortColumns[order]
+	return ok
+}
+
+// encode returns the cursor as an opaque URL-safe string.
+func (c *cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes a cursor made by encode for the given order.
+func decodeCursor(value, sort string, desc bool) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sort || c.Desc != desc {
+		return nil, fmt.Errorf("%w: it belongs to a list sorted by %s", ErrInvalidCursor, c.Sort)
+	}
+	if sort != sortRank && len(c.Key) != len(sortExprs(sort, "")) {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// after returns the condition of the tasks following the cursor and its arguments, comparing the sort keys as rows,
+// given the columns of the sort key, see sortExprs.
+func (c *cursor) after(columns []string) (string, []any, error) {
+	args := make([]any, len(c.Key))
+	for i, value := range c.Key {
+		args[i] = value
+	}
+	// The id is a number, and the last column of every key.
+	id, err := strconv.ParseInt(c.Key[len(c.Key)-1], 10, 64)
+	if err != nil {
+		return "", nil, ErrInvalidCursor
+	}
+	args[len(args)-1] = id
+
+	op := ">"
+	if c.Desc {
+		op = "<"
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	return fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), op, placeholders), args, nil
+}
+
+// orderBy returns the ORDER BY clause of the given columns of a sort key.
+func orderBy(columns []string, desc bool) string {
+	dir := " ASC"
+	if desc {
+		dir = " DESC"
+	}
+	return "ORDER BY " + strings.Join(columns, dir+", ") + dir
+}
+
+// sortKey returns the sort key of the task in the order, given the date of today for sortDefault.
+func sortKey(task *Task, sort, today string) []string {
+	level := priorityLevel(task.Priority)
+	switch sort {
+	case SortDate:
+		return []string{task.Date, task.Time, task.ID}
+	case SortPriority:
+		return []string{strconv.Itoa(4 - level), task.Date, task.Time, task.ID}
+	case sortDefault:
+		overdue := "1"
+		if level >= priorityLevel(PriorityHigh) && task.Date < today {
+			overdue = "0"
+		}
+		return []string{overdue, task.Date, task.Time, task.ID}
+	case SortTitle:
+		return []string{task.Title, task.ID}
+	case SortCreated:
+		return []string{task.Created, task.ID}
+	}
+	return []string{task.ID}
+}
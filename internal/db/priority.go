@@ -0,0 +1,33 @@
+package db
+
+import "slices"
+
+// The priorities of a task, from the lowest to the highest. A task has no priority by default.
+const (
+	PriorityNone   = ""
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+// priorities are the priorities in the order of their levels, which is how they're stored.
+var priorities = []string{PriorityNone, PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent}
+
+// IsPriority reports whether the given name is one of the priorities of a task.
+func IsPriority(name string) bool {
+	return slices.Contains(priorities, name)
+}
+
+// priorityLevel returns the stored level of the priority, zero for no priority or an unknown one.
+func priorityLevel(name string) int {
+	return max(slices.Index(priorities, name), 0)
+}
+
+// priorityName returns the priority of the stored level, no priority for an unknown one.
+func priorityName(level int) string {
+	if level < 0 || level >= len(priorities) {
+		return PriorityNone
+	}
+	return priorities[level]
+}
@@ -0,0 +1,397 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidQuery is wrapped by the errors of search strings that aren't valid queries, see parseQuery.
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// queryDateLayouts are the layouts of the dates of the before:, after: and on: operators.
+var queryDateLayouts = []string{"2006-01-02", DateLayoutDB, DateLayoutSearch}
+
+type tokenKind int
+
+const (
+	tokenText tokenKind = iota
+	tokenField
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+// queryToken is a token of a search query. The text of a text token is the word or the quoted phrase as written,
+// so that parseSearch makes a term of it; the one of a field token is its value, unquoted.
+type queryToken struct {
+	kind  tokenKind
+	field string
+	text  string
+}
+
+// taskQuery is a search query compiled to the condition of a WHERE clause with "?" placeholders and its arguments.
+// A query made only of words and phrases, which all must match, is a plain one:
+// its terms are searched for by the ranked full-text query of the dialect instead.
+type taskQuery struct {
+	where string
+	args  []any
+	plain bool
+	terms []searchTerm
+}
+
+// parseQuery compiles a search query. Its terms are separated by spaces and all must match, unless joined with OR:
+//
+//   - word, "quoted phrase", word* — a full-text term, see parseSearch
+//   - 02.01.2006 — the tasks on the date
+//   - before:DATE, after:DATE, on:DATE — the tasks before, after or on the date, in YYYY-MM-DD, YYYYMMDD
+//     or DD.MM.YYYY format
+//   - repeat:yes, repeat:no — the repeating or one-time tasks
+//   - title:TEXT, comment:TEXT — the tasks whose title or comment contains the text, which may be quoted
+//   - tag:NAME — the tasks with the tag, whatever its case
+//   - overdue — the tasks dated before today, which is in DateLayoutDB format
+//   - A AND B, A OR B, NOT A, -A, (A) — AND binds tighter than OR, and the AND may be left out
+//
+// The keywords AND, OR and NOT are upper-case, so that the lower-case words are searched for.
+// Errors wrap ErrInvalidQuery.
+func parseQuery(search, today string, d *dialect) (*taskQuery, error) {
+	tokens, err := lexQuery(search)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens, today: today, dialect: d, plain: true}
+	q := &taskQuery{}
+	if len(tokens) == 0 {
+		return q, nil
+	}
+
+	q.where, err = p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("%w: unexpected %s", ErrInvalidQuery, t)
+	}
+	if !p.plain && p.wordless != "" {
+		return nil, fmt.Errorf("%w: '%s' has no letters or digits", ErrInvalidQuery, p.wordless)
+	}
+	q.args, q.plain, q.terms = p.args, p.plain, p.terms
+	return q, nil
+}
+
+// withTags narrows the query down to the tasks with all the given tags. The query is no longer a plain one,
+// since the ranked full-text query can't select by tag.
+func (q *taskQuery) withTags(tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	conds := make([]string, 0, len(tags)+1)
+	if q.where != "" {
+		conds = append(conds, q.where)
+	}
+	for _, tag := range tags {
+		conds, q.args = append(conds, hasTag), append(q.args, strings.ToLower(tag))
+	}
+	q.where, q.plain = "("+strings.Join(conds, " AND ")+")", false
+}
+
+// withList narrows the query down to the tasks of the list with the given id, see withTags.
+func (q *taskQuery) withList(listID int64) {
+	cond := `list_id = ?`
+	if q.where != "" {
+		cond = q.where + ` AND ` + cond
+	}
+	q.where, q.args, q.plain = "("+cond+")", append(q.args, listID), false
+}
+
+// lexQuery splits a search query into tokens.
+func lexQuery(search string) ([]queryToken, error) {
+	var tokens []queryToken
+	rest := strings.TrimSpace(search)
+	for ; rest != ""; rest = strings.TrimSpace(rest) {
+		switch {
+		case rest[0] == '(':
+			tokens, rest = append(tokens, queryToken{kind: tokenOpen}), rest[1:]
+			continue
+		case rest[0] == ')':
+			tokens, rest = append(tokens, queryToken{kind: tokenClose}), rest[1:]
+			continue
+		case rest[0] == '-' && len(rest) > 1 && !unicode.IsSpace(rune(rest[1])):
+			tokens, rest = append(tokens, queryToken{kind: tokenNot}), rest[1:]
+			continue
+		case rest[0] == '"':
+			phrase, after, err := cutQuoted(rest)
+			if err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(after, "*") {
+				phrase, after = phrase+"*", after[1:]
+			}
+			tokens, rest = append(tokens, queryToken{kind: tokenText, text: phrase}), after
+			continue
+		}
+
+		end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || strings.ContainsRune(`()"`, r) })
+		if end < 0 {
+			end = len(rest)
+		}
+		word := rest[:end]
+		rest = rest[end:]
+
+		switch word {
+		case "AND":
+			tokens = append(tokens, queryToken{kind: tokenAnd})
+			continue
+		case "OR":
+			tokens = append(tokens, queryToken{kind: tokenOr})
+			continue
+		case "NOT":
+			tokens = append(tokens, queryToken{kind: tokenNot})
+			continue
+		}
+
+		field, value, ok := strings.Cut(word, ":")
+		field = strings.ToLower(field)
+		if !ok || !isQueryField(field) {
+			tokens = append(tokens, queryToken{kind: tokenText, text: word})
+			continue
+		}
+		if value == "" && strings.HasPrefix(rest, `"`) {
+			phrase, after, err := cutQuoted(rest)
+			if err != nil {
+				return nil, err
+			}
+			value, rest = strings.Trim(phrase, `"`), after
+		}
+		if value == "" {
+			return nil, fmt.Errorf("%w: %s: needs a value", ErrInvalidQuery, field)
+		}
+		tokens = append(tokens, queryToken{kind: tokenField, field: field, text: value})
+	}
+	return tokens, nil
+}
+
+// cutQuoted cuts the quoted phrase the string starts with, quotes included, off the rest of the string.
+func cutQuoted(s string) (string, string, error) {
+	end := strings.IndexByte(s[1:], '"')
+	if end < 0 {
+		return "", "", fmt.Errorf("%w: unclosed quote", ErrInvalidQuery)
+	}
+	return s[:end+2], s[end+2:], nil
+}
+
+// isQueryField reports whether the name is the one of a field:value operator.
+func isQueryField(name string) bool {
+	switch name {
+	case "before", "after", "on", "repeat", "title", "comment", "tag":
+		return true
+	}
+	return false
+}
+
+// String describes the token in the errors of parseQuery.
+func (t queryToken) String() string {
+	switch t.kind {
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenOpen:
+		return "'('"
+	case tokenClose:
+		return "')'"
+	case tokenField:
+		return fmt.Sprintf("'%s:%s'", t.field, t.text)
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+// queryParser is a recursive descent parser of the tokens of a search query,
+// which builds the condition while collecting its arguments in order.
+type queryParser struct {
+	tokens  []queryToken
+	pos     int
+	today   string
+	dialect *dialect
+	args    []any
+	plain   bool
+	terms   []searchTerm
+	// wordless is the first text term without letters or digits.
+	wordless string
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// or parses the terms joined with OR.
+func (p *queryParser) or() (string, error) {
+	var items []string
+	for {
+		item, err := p.and()
+		if err != nil {
+			return "", err
+		}
+		items = append(items, item)
+		if t, ok := p.peek(); !ok || t.kind != tokenOr {
+			break
+		}
+		p.pos++
+		p.plain = false
+	}
+	if len(items) == 1 {
+		return items[0], nil
+	}
+	return "(" + strings.Join(items, " OR ") + ")", nil
+}
+
+// and parses the terms joined with AND or written one after another. The wordless terms are left out,
+// and if there are only such terms, nothing matches.
+func (p *queryParser) and() (string, error) {
+	var items []string
+	for {
+		item, err := p.not()
+		if err != nil {
+			return "", err
+		}
+		if item != "" {
+			items = append(items, item)
+		}
+
+		t, ok := p.peek()
+		if !ok || t.kind == tokenOr || t.kind == tokenClose {
+			break
+		}
+		if t.kind == tokenAnd {
+			p.pos++
+		}
+	}
+	switch len(items) {
+	case 0:
+		return "1 = 0", nil
+	case 1:
+		return items[0], nil
+	}
+	return "(" + strings.Join(items, " AND ") + ")", nil
+}
+
+// not parses a term, negated if it follows NOT or "-".
+func (p *queryParser) not() (string, error) {
+	if t, ok := p.peek(); ok && t.kind == tokenNot {
+		p.pos++
+		p.plain = false
+		item, err := p.not()
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + item + ")", nil
+	}
+	return p.term()
+}
+
+// term parses a single term or a query in parentheses.
+func (p *queryParser) term() (string, error) {
+	t, ok := p.peek()
+	if !ok {
+		return "", fmt.Errorf("%w: unexpected end of query", ErrInvalidQuery)
+	}
+	p.pos++
+
+	switch t.kind {
+	case tokenOpen:
+		p.plain = false
+		item, err := p.or()
+		if err != nil {
+			return "", err
+		}
+		if t, ok := p.peek(); !ok || t.kind != tokenClose {
+			return "", fmt.Errorf("%w: missing ')'", ErrInvalidQuery)
+		}
+		p.pos++
+		return "(" + item + ")", nil
+	case tokenField:
+		p.plain = false
+		return p.field(t.field, t.text)
+	case tokenText:
+		return p.text(t.text)
+	}
+	return "", fmt.Errorf("%w: unexpected %s", ErrInvalidQuery, t)
+}
+
+// field compiles a field:value operator.
+func (p *queryParser) field(name, value string) (string, error) {
+	switch name {
+	case "before", "after", "on":
+		date, err := parseQueryDate(value)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %w", ErrInvalidQuery, name, err)
+		}
+		op := map[string]string{"before": "<", "after": ">", "on": "="}[name]
+		return p.arg("date "+op+" ?", date), nil
+	case "repeat":
+		switch strings.ToLower(value) {
+		case "yes":
+			return "repeat <> ''", nil
+		case "no":
+			return "repeat = ''", nil
+		}
+		return "", fmt.Errorf("%w: repeat: the value must be yes or no, got '%s'", ErrInvalidQuery, value)
+	case "title", "comment":
+		return p.arg(fmt.Sprintf(p.dialect.contains, name), "%"+escapeLike(value)+"%"), nil
+	}
+	return p.arg(hasTag, strings.ToLower(value)), nil
+}
+
+// text compiles a full-text term, a date in DateLayoutSearch format or the overdue keyword.
+func (p *queryParser) text(word string) (string, error) {
+	if word == "overdue" {
+		p.plain = false
+		return p.arg("date < ?", p.today), nil
+	}
+	if date, err := time.Parse(DateLayoutSearch, word); err == nil {
+		p.plain = false
+		return p.arg("date = ?", date.Format(DateLayoutDB)), nil
+	}
+
+	terms := parseSearch(word)
+	if len(terms) == 0 {
+		// A plain query skips such a term like parseSearch does, so it's left out of the condition,
+		// and any other query reports it once parsed.
+		if p.wordless == "" {
+			p.wordless = word
+		}
+		return "", nil
+	}
+	p.terms = append(p.terms, terms...)
+	return p.arg(p.dialect.textMatch, p.dialect.match(terms)), nil
+}
+
+// arg returns the condition, adding its argument.
+func (p *queryParser) arg(cond string, value any) string {
+	p.args = append(p.args, value)
+	return cond
+}
+
+// parseQueryDate parses a date in one of queryDateLayouts into DateLayoutDB format.
+func parseQueryDate(value string) (string, error) {
+	for _, layout := range queryDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.Format(DateLayoutDB), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date '%s', must be like 2026-11-01", value)
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern with a backslash.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
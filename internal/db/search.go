@@ -0,0 +1,89 @@
+package db
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	// The matches in a snippet are marked with control characters, which can't come from a task,
+	// and turned into HTML once the rest of the snippet is escaped.
+	snippetStart = "\x02"
+	snippetEnd   = "\x03"
+)
+
+// searchTerm is a word or a quoted phrase of a search string.
+// A prefix term matches the words beginning with its last word.
+type searchTerm struct {
+	words  []string
+	prefix bool
+}
+
+// parseSearch splits a search string into terms: "quoted phrases" and single words, all of which must match.
+// A term followed by "*" is a prefix one. Only letters and digits make up words and anything else separates them,
+// so a search string can't break the syntax of the full-text query.
+func parseSearch(search string) []searchTerm {
+	var terms []searchTerm
+	add := func(chunk string, prefix bool) {
+		words := strings.FieldsFunc(strings.ToLower(chunk), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) > 0 {
+			terms = append(terms, searchTerm{words: words, prefix: prefix})
+		}
+	}
+
+	for rest := strings.TrimSpace(search); rest != ""; rest = strings.TrimSpace(rest) {
+		var chunk string
+		if phrase, ok := strings.CutPrefix(rest, `"`); ok {
+			chunk, rest, _ = strings.Cut(phrase, `"`)
+		} else {
+			end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(rest)
+			}
+			chunk, rest = rest[:end], rest[end:]
+		}
+
+		prefix := strings.HasSuffix(chunk, "*")
+		if after, ok := strings.CutPrefix(rest, "*"); ok {
+			prefix, rest = true, after
+		}
+		add(chunk, prefix)
+	}
+	return terms
+}
+
+// fts5Match builds an SQLite FTS5 query of the terms, e.g. `"pay rent" "bill"*`.
+func fts5Match(terms []searchTerm) string {
+	items := make([]string, 0, len(terms))
+	for _, term := range terms {
+		item := `"` + strings.Join(term.words, " ") + `"`
+		if term.prefix {
+			item += "*"
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, " ")
+}
+
+// tsQuery builds a PostgreSQL tsquery of the terms, e.g. "(pay <-> rent) & (bill:*)".
+func tsQuery(terms []searchTerm) string {
+	items := make([]string, 0, len(terms))
+	for _, term := range terms {
+		words := term.words
+		if term.prefix {
+			words = append(words[:len(words)-1:len(words)-1], words[len(words)-1]+":*")
+		}
+		items = append(items, "("+strings.Join(words, " <-> ")+")")
+	}
+	return strings.Join(items, " & ")
+}
+
+// highlight turns a snippet with marked matches into HTML, the matches wrapped into <mark> tags.
+func highlight(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, snippetStart, "<mark>")
+	return strings.ReplaceAll(snippet, snippetEnd, "</mark>")
+}
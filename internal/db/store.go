@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// TaskStore is the storage of the scheduler's tasks.
+// Every call takes a context, so that a cancelled request stops its queries.
+// The errors are ErrEmptyID if the id is empty, and ErrTaskNotFound if there's no task with the given id.
+// Only the methods of the trash see the tasks in the trash; for the others, they don't exist.
+// The methods that change tasks record the changes in the audit log in the same transaction, see WithActor.
+type TaskStore interface {
+	// Tasks returns a page of the tasks selected by the filter; see SQLiteStore.Tasks.
+	Tasks(ctx context.Context, filter TaskFilter) (*TaskPage, error)
+	// TasksUntil returns all the tasks dated on or before the given date, ordered by date and time.
+	TasksUntil(ctx context.Context, date string) ([]*Task, error)
+	// GetTask returns the task with the given id.
+	GetTask(ctx context.Context, id string) (*Task, error)
+	// AddTask adds a new task, setting its creation time, and returns its id.
+	AddTask(ctx context.Context, task *Task) (int64, error)
+	// UpdateTask applies update to the task with the given id and stores the result, recording the change.
+	// It returns the task as it was before the update.
+	UpdateTask(ctx context.Context, id string, update func(task *Task) error) (*Task, error)
+	// DeleteTask moves the task with the given id to the trash, recording the change.
+	DeleteTask(ctx context.Context, id string) error
+	// TrashedTasks returns the tasks in the trash, the most recently deleted first.
+	TrashedTasks(ctx context.Context) ([]*Task, error)
+	// RestoreTask takes the task with the given id out of the trash.
+	RestoreTask(ctx context.Context, id string) error
+	// PurgeTask permanently deletes the task with the given id from the trash and returns it as it was.
+	PurgeTask(ctx context.Context, id string) (*Task, error)
+	// PurgeTrash permanently deletes the tasks moved to the trash before the given time, or all of them
+	// if the time is zero, and returns them as they were.
+	PurgeTrash(ctx context.Context, before time.Time) ([]*Task, error)
+	// CompleteTask records the completion of a task and updates the task to its following occurrence,
+	// or moves it to the trash if there's none, at once, recording the change.
+	CompleteTask(ctx context.Context, done *Completion, next *Task) error
+	// History returns the completions selected by the filter, the most recent first.
+	History(ctx context.Context, filter HistoryFilter) ([]*Completion, error)
+	// Changes returns the changes made since the given time that haven't been undone, the most recent first.
+	Changes(ctx context.Context, since time.Time) ([]*Change, error)
+	// UndoChange undoes the change with the given id, or the most recent one if the id is empty,
+	// among the changes made since the given time, and returns it along with the task as it was before,
+	// nil if it was in the trash.
+	UndoChange(ctx context.Context, id string, since time.Time) (*Change, *Task, error)
+	// PruneChanges deletes the changes made before the given time and returns their number.
+	PruneChanges(ctx context.Context, before time.Time) (int64, error)
+	// AddAuditEntry appends an entry to the audit log, setting its id and the time it's logged.
+	AddAuditEntry(ctx context.Context, entry *AuditEntry) error
+	// Audit returns the entries of the audit log selected by the filter, the most recent first.
+	Audit(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error)
+	// Tags returns the tags of the tasks, except the ones in the trash, with the number of tasks having each of them.
+	Tags(ctx context.Context) ([]*TagCount, error)
+	// RenameTag renames a tag on all the tasks that have it and returns the tasks as they were before.
+	RenameTag(ctx context.Context, from, to string) ([]*Task, error)
+	// MergeTags replaces the given tags with another one on all the tasks that have them and returns the tasks
+	// as they were before.
+	MergeTags(ctx context.Context, from []string, to string) ([]*Task, error)
+	// Lists returns all the lists ordered by name, each with the number of its tasks.
+	Lists(ctx context.Context) ([]*List, error)
+	// GetList returns the list with the given id.
+	GetList(ctx context.Context, id string) (*List, error)
+	// AddList adds a new list and returns its id.
+	AddList(ctx context.Context, list *List) (int64, error)
+	// UpdateList renames the list with the given id.
+	UpdateList(ctx context.Context, list *List) error
+	// DeleteList deletes the list with the given id, moving its tasks to the trash if cascade is true,
+	// or to another list otherwise, and returns the tasks as they were before.
+	DeleteList(ctx context.Context, id, moveTo string, cascade bool) ([]*Task, error)
+	// MoveTasks moves the tasks with the given ids to the list with the given id and returns the moved tasks
+	// as they were before.
+	MoveTasks(ctx context.Context, ids []string, listID string) ([]*Task, error)
+	// Close releases the resources of the store.
+	Close() error
+}
+
+var (
+	_ TaskStore = (*SQLiteStore)(nil)
+	_ TaskStore = (*PostgresStore)(nil)
+)
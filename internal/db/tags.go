@@ -0,0 +1,334 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrTagNotFound = errors.New("tag not found")
+	ErrTagExists   = errors.New("tag already exists")
+)
+
+// hasTag is the condition selecting the tasks with the tag given as its argument.
+const hasTag = `id IN (SELECT tt.task_id FROM task_tags tt JOIN tags t ON t.id = tt.tag_id WHERE t.name = ?)`
+
+// TagCount is a tag together with the number of the tasks that have it.
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Tags returns the tags of the tasks, except the ones in the trash, with the number of tasks having each of them,
+// ordered by name.
+func (s *sqlStore) Tags(ctx context.Context) ([]*TagCount, error) {
+	query := `SELECT t.name, COUNT(*) FROM tags t
+		JOIN task_tags tt ON tt.tag_id = t.id
+		JOIN scheduler s ON s.id = tt.task_id AND s.deleted = ''
+		GROUP BY t.name ORDER BY t.name`
+	rows, err := s.query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []*TagCount{}
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag while building the tag list: %w", err)
+		}
+		tags = append(tags, &tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows while building the tag list: %w", err)
+	}
+	return tags, nil
+}
+
+// RenameTag renames a tag on all the tasks that have it, and returns the tasks, the ones in the trash included,
+// as they were before.
+// If there's no tag with the old name, it will return ErrTagNotFound,
+// and if there's already one with the new name, ErrTagExists; see MergeTags.
+// The tag is renamed in the changes that can be undone as well, see retagChanges,
+// and the update of each task is recorded in the audit log, see auditRetag.
+func (s *sqlStore) RenameTag(ctx context.Context, from, to string) ([]*Task, error) {
+	tasks := []*Task{}
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		id, err := tx.tagID(ctx, from)
+		if err != nil || from == to {
+			return err
+		}
+		if _, err := tx.tagID(ctx, to); !errors.Is(err, ErrTagNotFound) {
+			if err == nil {
+				return fmt.Errorf("can't rename tag '%s' to '%s': %w", from, to, ErrTagExists)
+			}
+			return err
+		}
+
+		if tasks, err = tx.taggedTasks(ctx, id); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, `UPDATE tags SET name = ? WHERE id = ?`, to, id); err != nil {
+			return fmt.Errorf("failed to rename tag '%s': %w", from, err)
+		}
+		if err := tx.retagChanges(ctx, map[string]string{from: to}); err != nil {
+			return err
+		}
+		return tx.auditRetag(ctx, tasks)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// MergeTags replaces the given tags with another one on all the tasks that have them, in a single transaction,
+// and deletes them. The tag they're merged into is created if it doesn't exist.
+// If one of the tags to merge doesn't exist, it will return ErrTagNotFound and merge nothing.
+// It returns the tasks that had the merged tags, the ones in the trash included, as they were before.
+// The tags are merged in the changes that can be undone as well, see retagChanges,
+// and the update of each task is recorded in the audit log, see auditRetag.
+func (s *sqlStore) MergeTags(ctx context.Context, from []string, to string) ([]*Task, error) {
+	tasks := []*Task{}
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		toID, err := tx.ensureTag(ctx, to)
+		if err != nil {
+			return err
+		}
+		var (
+			names []string
+			ids   []int64
+		)
+		for _, name := range from {
+			if name == to {
+				continue
+			}
+			id, err := tx.tagID(ctx, name)
+			if err != nil {
+				return err
+			}
+			names, ids = append(names, name), append(ids, id)
+		}
+		if tasks, err = tx.taggedTasks(ctx, ids...); err != nil {
+			return err
+		}
+
+		for i, id := range ids {
+			// The tasks that already have both tags keep one link, and the other is deleted with the tag.
+			_, err = tx.exec(ctx, `UPDATE task_tags SET tag_id = ? WHERE tag_id = ?
+				AND task_id NOT IN (SELECT task_id FROM task_tags WHERE tag_id = ?)`, toID, id, toID)
+			if err != nil {
+				return fmt.Errorf("failed to merge tag '%s' into '%s': %w", names[i], to, err)
+			}
+			if _, err := tx.exec(ctx, `DELETE FROM task_tags WHERE tag_id = ?`, id); err != nil {
+				return fmt.Errorf("failed to merge tag '%s' into '%s': %w", names[i], to, err)
+			}
+			if _, err := tx.exec(ctx, `DELETE FROM tags WHERE id = ?`, id); err != nil {
+				return fmt.Errorf("failed to delete tag '%s': %w", names[i], err)
+			}
+		}
+		renames := make(map[string]string, len(names))
+		for _, name := range names {
+			renames[name] = to
+		}
+		if err := tx.retagChanges(ctx, renames); err != nil {
+			return err
+		}
+		return tx.auditRetag(ctx, tasks)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// retagChanges renames the tags in the task states recorded by the changes that can still be undone, so that undoing
+// a change made before a tag was renamed or merged restores the tag under its new name; see UndoChange.
+// The keys of renames are the old names of the tags and the values are the new ones.
+func (s *sqlStore) retagChanges(ctx context.Context, renames map[string]string) error {
+	rows, err := s.query(ctx, `SELECT id, tags FROM changes WHERE undone = '' AND tags != ''`)
+	if err != nil {
+		return fmt.Errorf("failed to select the changes with tags: %w", err)
+	}
+	defer rows.Close()
+
+	retagged := map[int64]string{}
+	for rows.Next() {
+		var (
+			id   int64
+			tags string
+		)
+		if err := rows.Scan(&id, &tags); err != nil {
+			return fmt.Errorf("failed to scan change while renaming its tags: %w", err)
+		}
+		names := strings.Split(tags, ",")
+		changed := false
+		for i, name := range names {
+			if to, ok := renames[name]; ok {
+				names[i], changed = to, true
+			}
+		}
+		if changed {
+			slices.Sort(names)
+			retagged[id] = strings.Join(slices.Compact(names), ",")
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate rows while renaming the tags of changes: %w", err)
+	}
+	rows.Close()
+
+	for id, tags := range retagged {
+		if _, err := s.exec(ctx, `UPDATE changes SET tags = ? WHERE id = ?`, tags, id); err != nil {
+			return fmt.Errorf("failed to rename the tags of change with id '%d': %w", id, err)
+		}
+	}
+	return nil
+}
+
+// auditRetag records in the audit log the update of the given tasks, as they were before their tags were renamed
+// or merged, reading their tags back from the store. The tasks in the trash are recorded as well.
+func (s *sqlStore) auditRetag(ctx context.Context, tasks []*Task) error {
+	for _, task := range tasks {
+		retagged := *task
+		retagged.Tags = nil
+		if err := s.loadTags(ctx, &retagged); err != nil {
+			return err
+		}
+		if err := s.audit(ctx, AuditUpdate, task.ID, task, &retagged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// taggedTasks returns the tasks with any of the tags with the given ids, the ones in the trash included,
+// ordered by id.
+func (s *sqlStore) taggedTasks(ctx context.Context, tagIDs ...int64) ([]*Task, error) {
+	if len(tagIDs) == 0 {
+		return []*Task{}, nil
+	}
+	args := make([]any, 0, len(tagIDs))
+	for _, id := range tagIDs {
+		args = append(args, id)
+	}
+
+	query := `SELECT id, date, time, title, comment, repeat, until, remaining, exceptions, workday, shift, created, deleted,
+		list_id, priority FROM scheduler WHERE id IN (SELECT task_id FROM task_tags WHERE tag_id IN (?` +
+		strings.Repeat(", ?", len(args)-1) + `)) ORDER BY id`
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select the tasks with tags: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := []*Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task while building the tasks with tags: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows while building the tasks with tags: %w", err)
+	}
+	if err := s.loadTags(ctx, tasks...); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// tagID returns the id of the tag with the given name, or ErrTagNotFound if there's none.
+func (s *sqlStore) tagID(ctx context.Context, name string) (int64, error) {
+	var id int64
+	err := s.queryRow(ctx, `SELECT id FROM tags WHERE name = ?`, name).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("no tag '%s': %w", name, ErrTagNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to select tag '%s': %w", name, err)
+	}
+	return id, nil
+}
+
+// ensureTag returns the id of the tag with the given name, creating the tag if it doesn't exist.
+func (s *sqlStore) ensureTag(ctx context.Context, name string) (int64, error) {
+	if _, err := s.exec(ctx, `INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING`, name); err != nil {
+		return 0, fmt.Errorf("failed to add tag '%s': %w", name, err)
+	}
+	return s.tagID(ctx, name)
+}
+
+// setTags replaces the tags of the task with the given id and deletes the tags no task has any longer.
+func (s *sqlStore) setTags(ctx context.Context, taskID int64, names []string) error {
+	if _, err := s.exec(ctx, `DELETE FROM task_tags WHERE task_id = ?`, taskID); err != nil {
+		return fmt.Errorf("failed to clear the tags of task with id '%d': %w", taskID, err)
+	}
+	for _, name := range names {
+		tagID, err := s.ensureTag(ctx, name)
+		if err != nil {
+			return err
+		}
+		if _, err := s.exec(ctx, `INSERT INTO task_tags (task_id, tag_id) VALUES (?, ?)`, taskID, tagID); err != nil {
+			return fmt.Errorf("failed to tag task with id '%d': %w", taskID, err)
+		}
+	}
+	return s.pruneTags(ctx)
+}
+
+// pruneTags deletes the tags of the tasks that no longer exist and the tags no task has.
+func (s *sqlStore) pruneTags(ctx context.Context) error {
+	if _, err := s.exec(ctx, `DELETE FROM task_tags WHERE task_id NOT IN (SELECT id FROM scheduler)`); err != nil {
+		return fmt.Errorf("failed to delete the tags of purged tasks: %w", err)
+	}
+	if _, err := s.exec(ctx, `DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM task_tags)`); err != nil {
+		return fmt.Errorf("failed to delete unused tags: %w", err)
+	}
+	return nil
+}
+
+// loadTags sets the tags of the given tasks, ordered by name.
+func (s *sqlStore) loadTags(ctx context.Context, tasks ...*Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*Task, len(tasks))
+	args := make([]any, 0, len(tasks))
+	for _, task := range tasks {
+		taskID, err := parseID(task.ID)
+		if err != nil {
+			return err
+		}
+		byID[task.ID] = task
+		args = append(args, taskID)
+	}
+
+	query := `SELECT tt.task_id, t.name FROM task_tags tt JOIN tags t ON t.id = tt.tag_id
+		WHERE tt.task_id IN (?` + strings.Repeat(", ?", len(args)-1) + `) ORDER BY t.name`
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to select the tags of tasks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return fmt.Errorf("failed to scan the tag of a task: %w", err)
+		}
+		if task := byID[id]; task != nil {
+			task.Tags = append(task.Tags, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate rows while selecting the tags of tasks: %w", err)
+	}
+	return nil
+}
@@ -1,15 +1,21 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const (
 	DateLayoutSearch = "02.01.2006"
 	DateLayoutDB     = "20060102"
+	TimeLayout       = "15:04"
+	DateTimeLayout   = DateLayoutDB + " " + TimeLayout
 )
 
 var (
@@ -17,159 +23,368 @@ var (
 	ErrTaskNotFound = errors.New("task not found")
 )
 
+// Task is a single task of the scheduler.
+// Time is the optional time of day the task is due at, in TimeLayout format; an empty Time means the whole day.
+// Until and Remaining are optional end conditions of a repeating task: the last date the task
+// may fall on, and the number of occurrences left, the current one included. Zero values mean no limit.
+// Exceptions are the dates a repeating task skips, in DateLayoutDB format.
+// Workday moves every occurrence of a repeating task that falls on a weekend or a holiday to the nearest working day;
+// Shift is the number of days the current occurrence was moved by. Shift isn't part of the JSON,
+// so a date sent by a client is always taken as the date computed by the repeat rule.
+// Created is the time the task was added in RFC 3339 format, in UTC, so that the times sort as strings;
+// it's empty for the tasks added before it was recorded. Deleted is the time the task was moved to the trash
+// in the same format, and it's empty for the tasks that aren't in the trash, which are the only ones the methods
+// of the store see, except for the ones of the trash.
+// ListID is the id of the list the task belongs to, empty if it doesn't belong to any, see List.
+// Priority is one of PriorityLow, PriorityMedium, PriorityHigh and PriorityUrgent, or PriorityNone.
+// Snippet isn't stored: it's the highlighted fragment of the title or comment matching a full-text search, see Tasks.
 type Task struct {
-	ID      string `json:"id"`
-	Date    string `json:"date"`
-	Title   string `json:"title"`
-	Comment string `json:"comment"`
-	Repeat  string `json:"repeat"`
+	ID         string   `json:"id"`
+	Date       string   `json:"date"`
+	Time       string   `json:"time,omitempty"`
+	Title      string   `json:"title"`
+	Comment    string   `json:"comment"`
+	Repeat     string   `json:"repeat"`
+	Until      string   `json:"until,omitempty"`
+	Remaining  int      `json:"remaining,omitempty"`
+	Exceptions []string `json:"exceptions,omitempty"`
+	Workday    bool     `json:"workday,omitempty"`
+	Shift      int      `json:"-"`
+	Tags       []string `json:"tags,omitempty"`
+	ListID     string   `json:"list_id,omitempty"`
+	Priority   string   `json:"priority,omitempty"`
+	Created    string   `json:"created,omitempty"`
+	Deleted    string   `json:"deleted,omitempty"`
+	Snippet    string   `json:"snippet,omitempty"`
 }
 
-// Tasks returns a list of tasks based on the given search string.
-// It will return tasks that match the search string in either title or comment.
-// If the search string is empty, it will return all tasks up to the limit set in the configuration.
-// The response will be in JSON format and will contain a list of tasks under the key "tasks".
-func Tasks(limit int, search string) ([]*Task, error) {
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// TaskFilter selects the tasks returned by Tasks and their order.
+// Search is a search query, see parseQuery, and Today is the current date in DateLayoutDB format
+// the overdue tasks are dated before.
+// Sort is one of SortDate, SortTitle, SortID, SortCreated and SortPriority, or empty for the default order, see Tasks;
+// Desc reverses it. Cursor is the NextCursor of the previous page, or empty for the first one.
+// Tags selects the tasks that have all of them, like the tag: operator of the query,
+// and ListID the tasks of the list with that id.
+type TaskFilter struct {
+	Limit  int
+	Search string
+	Today  string
+	Sort   string
+	Desc   bool
+	Cursor string
+	Tags   []string
+	ListID string
+}
+
+// Tasks returns a page of up to Limit tasks selected by the filter, with the cursor of the next page
+// and the number of all the tasks matching the search query.
+// If the search query is only made of words and phrases and no order is given, it will return the tasks whose title
+// or comment match them as a full-text query, best matches first, each with a snippet of the matching text highlighted
+// with <mark> tags; see parseSearch. The pages of such a search are counted by offset, so they may skip or repeat
+// a task if the tasks change in between. Otherwise, it will return the tasks matching the query, see parseQuery,
+// ordered by date and time unless another order is given, the overdue tasks of high priority and above first
+// if Today is set. Those pages follow the sort key of the last task
+// of the previous page, so that walking them returns every task that stays in the list exactly once.
+// An invalid query returns an error wrapping ErrInvalidQuery, and an invalid cursor one wrapping ErrInvalidCursor.
+// If the search query is empty, it will return all tasks.
+func (s *sqlStore) Tasks(ctx context.Context, filter TaskFilter) (*TaskPage, error) {
 	var (
-		baseQuery = `SELECT id, date, title, comment, repeat FROM scheduler `
-		rows      *sql.Rows
-		errQuery  error
+		baseQuery = `SELECT id, date, time, title, comment, repeat, until, remaining, exceptions, workday, shift, created, deleted,
+			list_id, priority FROM scheduler `
+		limit    = filter.Limit
+		sort     = filter.Sort
+		desc     = filter.Desc
+		offset   int
+		rows     *sql.Rows
+		errQuery error
 	)
 
-	if search == "" {
-		query := baseQuery + `ORDER BY date ASC LIMIT :limit`
-		rows, errQuery = db.Query(query, sql.Named("limit", limit))
-	} else {
-		taskDate, err := time.Parse(DateLayoutSearch, search)
+	q, err := parseQuery(filter.Search, filter.Today, s.dialect)
+	if err != nil {
+		return nil, err
+	}
+	q.withTags(filter.Tags)
+	if filter.ListID != "" {
+		listID, err := parseListID(filter.ListID)
 		if err != nil {
-			search = "%" + search + "%"
-			queryWord := baseQuery + `WHERE title LIKE :search OR comment LIKE :search ORDER BY date ASC LIMIT :limit`
-			rows, errQuery = db.Query(queryWord, sql.Named("search", search), sql.Named("limit", limit))
+			return nil, err
+		}
+		q.withList(listID)
+	}
+	page := &TaskPage{Tasks: []*Task{}}
+	if q.plain && q.where != "" && len(q.terms) == 0 {
+		return page, nil
+	}
+	switch {
+	case sort == "" && q.plain && q.where != "":
+		sort, desc = sortRank, false
+	case sort == "" && isDate(filter.Today):
+		sort = sortDefault
+	case sort == "":
+		sort = SortDate
+	case !IsSort(sort):
+		return nil, fmt.Errorf("unknown sort order '%s'", sort)
+	}
 
-		} else {
-			queryDate := baseQuery + `WHERE date = :date ORDER BY date ASC LIMIT :limit`
-			rows, errQuery = db.Query(queryDate, sql.Named("date", taskDate.Format(DateLayoutDB)), sql.Named("limit", limit))
+	var c *cursor
+	if filter.Cursor != "" {
+		if c, err = decodeCursor(filter.Cursor, sort, desc); err != nil {
+			return nil, err
 		}
 	}
 
+	countQuery := `SELECT COUNT(*) FROM scheduler WHERE deleted = ''`
+	if q.where != "" {
+		countQuery += ` AND ` + q.where
+	}
+	if err := s.queryRow(ctx, countQuery, q.args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("failed to count tasks: %w", err)
+	}
+
+	// One more task than the limit is selected to find out whether there's a next page.
+	if sort == sortRank {
+		if c != nil {
+			offset = c.Offset
+		}
+		rows, errQuery = s.query(ctx, s.dialect.fullText, snippetStart, snippetEnd, s.dialect.match(q.terms),
+			limit+1, offset)
+	} else {
+		var (
+			conds = []string{`deleted = ''`}
+			args  = append([]any{}, q.args...)
+		)
+		if q.where != "" {
+			conds = append(conds, q.where)
+		}
+		if c != nil {
+			cond, keyArgs, err := c.after(sortExprs(sort, filter.Today))
+			if err != nil {
+				return nil, err
+			}
+			conds, args = append(conds, cond), append(args, keyArgs...)
+		}
+		query := baseQuery + `WHERE ` + strings.Join(conds, " AND ") + ` ` + orderBy(sortExprs(sort, filter.Today), desc) +
+			` LIMIT ?`
+		rows, errQuery = s.query(ctx, query, append(args, limit+1)...)
+	}
+
 	if errQuery != nil {
 		return nil, fmt.Errorf("failed to select tasks: %w", errQuery)
 	}
 	defer rows.Close()
 
-	tasks := make([]*Task, 0, limit)
 	for rows.Next() {
-		var task Task
-
-		err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+		var (
+			task    *Task
+			snippet string
+			err     error
+		)
+		if sort == sortRank {
+			task, err = scanTask(rows, &snippet)
+		} else {
+			task, err = scanTask(rows)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan task while building task list: %w", err)
 		}
-		tasks = append(tasks, &task)
+		if sort == sortRank {
+			task.Snippet = highlight(snippet)
+		}
+		page.Tasks = append(page.Tasks, task)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("failed to iterate rows while building task list: %w", err)
 	}
+
+	if len(page.Tasks) > limit {
+		page.Tasks = page.Tasks[:limit]
+		next := &cursor{Sort: sort, Desc: desc}
+		if sort == sortRank {
+			next.Offset = offset + limit
+		} else {
+			next.Key = sortKey(page.Tasks[limit-1], sort, filter.Today)
+		}
+		page.NextCursor = next.encode()
+	}
+	if err := s.loadTags(ctx, page.Tasks...); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// TasksUntil returns all the tasks dated on or before the given date in DateLayoutDB format,
+// ordered by date and time. Together with their repeat rules, they make up the agenda up to that date.
+func (s *sqlStore) TasksUntil(ctx context.Context, date string) ([]*Task, error) {
+	query := `SELECT id, date, time, title, comment, repeat, until, remaining, exceptions, workday, shift, created, deleted,
+		list_id, priority FROM scheduler WHERE date <= ? AND deleted = '' ORDER BY date ASC, time ASC, id ASC`
+	rows, err := s.query(ctx, query, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select tasks until '%s': %w", date, err)
+	}
+	defer rows.Close()
+
+	tasks := []*Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task while building task list: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows while building task list: %w", err)
+	}
+	if err := s.loadTags(ctx, tasks...); err != nil {
+		return nil, err
+	}
 	return tasks, nil
 }
 
 // GetTask returns a single task based on the given id.
 // If the task doesn't exist, it will return an error with 404 status code.
 // The response will be in JSON format and will contain the task under the key "task".
-func GetTask(id string) (*Task, error) {
-	if id == "" {
-		return nil, ErrEmptyID
+func (s *sqlStore) GetTask(ctx context.Context, id string) (*Task, error) {
+	taskID, err := parseID(id)
+	if err != nil {
+		return nil, err
 	}
 
-	var (
-		task  Task
-		query = `SELECT id, date, title, comment, repeat FROM scheduler WHERE id = :id`
-	)
-	row := db.QueryRow(query, sql.Named("id", id))
-	if err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat); err != nil {
+	query := `SELECT id, date, time, title, comment, repeat, until, remaining, exceptions, workday, shift, created, deleted,
+		list_id, priority FROM scheduler WHERE id = ? AND deleted = ''`
+	task, err := scanTask(s.queryRow(ctx, query, taskID))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrTaskNotFound
 		}
 		return nil, fmt.Errorf("failed to get task: %w", err)
 	}
-
-	return &task, nil
-}
-
-// UpdateTask updates the task with the given id.
-// If the task doesn't exist, it will return an error with 404 status code.
-// The response will be in JSON format and will contain the updated task under the key "task".
-// If the request body is invalid, it will return an error with 400 status code.
-// If the request body is too large, it will return an error with 413 status code.
-func UpdateTask(task *Task) error {
-	if task.ID == "" {
-		return ErrEmptyID
+	if err := s.loadTags(ctx, task); err != nil {
+		return nil, err
 	}
 
-	query := `UPDATE scheduler SET date = :date, title = :title, comment = :comment, repeat = :repeat WHERE id = :id`
+	return task, nil
+}
 
-	res, err := db.Exec(query,
-		sql.Named("title", task.Title),
-		sql.Named("comment", task.Comment),
-		sql.Named("repeat", task.Repeat),
-		sql.Named("date", task.Date),
-		sql.Named("id", task.ID))
+// UpdateTask updates the task with the given id in a single transaction: it reads the task, applies update to it
+// and stores the result, recording the previous state so that the update can be undone, see UndoChange,
+// and the update in the audit log, see WithActor.
+// It returns the task as it was before the update. The id of the task can't be changed by update.
+// If update returns an error, the task is left as it is and the error is returned unchanged.
+func (s *sqlStore) UpdateTask(ctx context.Context, id string, update func(task *Task) error) (*Task, error) {
+	var previous *Task
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		var err error
+		if previous, err = tx.GetTask(ctx, id); err != nil {
+			return err
+		}
+		task := *previous
+		// update may decode into the slices, which would overwrite the previous state.
+		task.Exceptions, task.Tags = slices.Clone(previous.Exceptions), slices.Clone(previous.Tags)
+		if err := update(&task); err != nil {
+			return err
+		}
+		task.ID = previous.ID
+		if err := tx.updateTask(ctx, &task); err != nil {
+			return err
+		}
+		if err := tx.recordChange(ctx, ChangeUpdate, previous, 0); err != nil {
+			return err
+		}
+		updated, err := tx.GetTask(ctx, id)
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, AuditUpdate, id, previous, updated)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update task with id '%s': %w", task.ID, err)
+		return nil, err
 	}
+	return previous, nil
+}
 
-	count, err := res.RowsAffected()
+// updateTask updates the task with the given id and its tags without recording the change.
+// If the task is moved to a list that doesn't exist, it will return ErrListNotFound.
+func (s *sqlStore) updateTask(ctx context.Context, task *Task) error {
+	taskID, err := parseID(task.ID)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected while updating task: %w", err)
+		return err
 	}
-	if count != 1 {
-		return fmt.Errorf(`incorrect id for updating task '%s': %w`, task.ID, ErrTaskNotFound)
-	}
-	return nil
-}
 
-// UpdateDate updates the date of the task with the given id.
-// If the task doesn't exist, it will return an error with 404 status code.
-// The response will be in JSON format and will contain the updated task under the key "task".
-// If the request body is invalid, it will return an error with 400 status code.
-// If the request body is too large, it will return an error with 413 status code.
-func UpdateDate(id, nextDate string) error {
-	if id == "" {
-		return ErrEmptyID
+	listID, err := s.checkList(ctx, task.ListID)
+	if err != nil {
+		return err
 	}
 
-	query := `UPDATE scheduler SET date = :date WHERE id = :id`
+	query := `UPDATE scheduler SET date = ?, time = ?, title = ?, comment = ?, repeat = ?,
+		until = ?, remaining = ?, exceptions = ?, workday = ?, shift = ?, list_id = ?, priority = ?
+		WHERE id = ? AND deleted = ''`
 
-	res, err := db.Exec(query,
-		sql.Named("date", nextDate),
-		sql.Named("id", id))
+	res, err := s.exec(ctx, query,
+		task.Date,
+		task.Time,
+		task.Title,
+		task.Comment,
+		task.Repeat,
+		task.Until,
+		task.Remaining,
+		joinExceptions(task.Exceptions),
+		task.Workday,
+		task.Shift,
+		listID,
+		priorityLevel(task.Priority),
+		taskID)
 	if err != nil {
-		return fmt.Errorf("failed to update date for the task with id '%s': %w", id, err)
+		return fmt.Errorf("failed to update task with id '%s': %w", task.ID, err)
 	}
 
 	count, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected while updating date: %w", err)
+		return fmt.Errorf("failed to get rows affected while updating task: %w", err)
 	}
 	if count != 1 {
-		return fmt.Errorf(`incorrect id for updating date for the task '%s': %w`, id, ErrTaskNotFound)
+		return fmt.Errorf(`incorrect id for updating task '%s': %w`, task.ID, ErrTaskNotFound)
 	}
-	return nil
+	return s.setTags(ctx, taskID, task.Tags)
 }
 
-// DeleteTask deletes a task with the given id.
-// If the task doesn't exist, it will return an error with 404 status code.
+// DeleteTask moves a task with the given id to the trash, see RestoreTask and PurgeTrash.
+// It records the state of the task so that the deletion can be undone, see UndoChange,
+// and the deletion in the audit log, see WithActor.
+// If the task doesn't exist or is already in the trash, it will return an error with 404 status code.
 // The response will be in JSON format and will contain an empty response with 200 status code.
 // If the request body is invalid, it will return an error with 400 status code.
 // If the request body is too large, it will return an error with 413 status code.
-func DeleteTask(id string) error {
-	if id == "" {
-		return ErrEmptyID
+func (s *sqlStore) DeleteTask(ctx context.Context, id string) error {
+	return s.inTx(ctx, func(tx *sqlStore) error {
+		previous, err := tx.GetTask(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.deleteTask(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.recordChange(ctx, ChangeDelete, previous, 0); err != nil {
+			return err
+		}
+		return tx.audit(ctx, AuditDelete, id, previous, nil)
+	})
+}
+
+// deleteTask moves a task with the given id to the trash without recording the change.
+func (s *sqlStore) deleteTask(ctx context.Context, id string) error {
+	taskID, err := parseID(id)
+	if err != nil {
+		return err
 	}
 
-	query := `DELETE FROM scheduler WHERE id = :id`
-	res, err := db.Exec(query, sql.Named("id", id))
+	query := `UPDATE scheduler SET deleted = ? WHERE id = ? AND deleted = ''`
+	res, err := s.exec(ctx, query, time.Now().UTC().Format(time.RFC3339), taskID)
 	if err != nil {
 		return fmt.Errorf("failed to delete task with id '%s': %w", id, err)
 	}
@@ -184,28 +399,98 @@ func DeleteTask(id string) error {
 	return nil
 }
 
-// AddTask adds a new task to the database.
-// It returns the id of the newly inserted task.
+// AddTask adds a new task to the database together with its tags.
+// If the task is added to a list that doesn't exist, it will return ErrListNotFound.
+// It returns the id of the newly inserted task and sets its creation time.
+// The task is recorded in the audit log as it's stored, see WithActor.
 // If the task already exists, it will return an error with 409 status code.
 // If the request body is invalid, it will return an error with 400 status code.
 // If the request body is too large, it will return an error with 413 status code.
-func AddTask(task *Task) (int64, error) {
-	query := `INSERT INTO scheduler (date, title, comment, repeat) 
-		VALUES (:date, :title, :comment, :repeat)`
-
-	res, err := db.Exec(query,
-		sql.Named("date", task.Date),
-		sql.Named("title", task.Title),
-		sql.Named("comment", task.Comment),
-		sql.Named("repeat", task.Repeat))
+func (s *sqlStore) AddTask(ctx context.Context, task *Task) (int64, error) {
+	query := `INSERT INTO scheduler (date, time, title, comment, repeat, until, remaining, exceptions, workday, shift, created,
+		list_id, priority) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
+
+	task.Created = time.Now().UTC().Format(time.RFC3339)
+
+	var id int64
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		listID, err := tx.checkList(ctx, task.ListID)
+		if err != nil {
+			return err
+		}
+		err = tx.queryRow(ctx, query,
+			task.Date,
+			task.Time,
+			task.Title,
+			task.Comment,
+			task.Repeat,
+			task.Until,
+			task.Remaining,
+			joinExceptions(task.Exceptions),
+			task.Workday,
+			task.Shift,
+			task.Created,
+			listID,
+			priorityLevel(task.Priority)).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to add task with title '%s': %w", task.Title, err)
+		}
+		if len(task.Tags) > 0 {
+			if err := tx.setTags(ctx, id, task.Tags); err != nil {
+				return err
+			}
+		}
+		added, err := tx.GetTask(ctx, strconv.FormatInt(id, 10))
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, AuditAdd, added.ID, nil, added)
+	})
 	if err != nil {
-		return 0, fmt.Errorf("failed to add task with title '%s': %w", task.Title, err)
+		return 0, err
 	}
 
-	id, err := res.LastInsertId()
+	return id, nil
+}
+
+// parseID parses a task id. It returns ErrEmptyID for an empty id, and ErrTaskNotFound for an id
+// that isn't a number, since no task can have it: ids are generated by the database.
+func parseID(id string) (int64, error) {
+	if id == "" {
+		return 0, ErrEmptyID
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+		return 0, ErrTaskNotFound
 	}
+	return n, nil
+}
 
-	return id, nil
+// joinExceptions joins the exception dates for storing, see scanTask.
+func joinExceptions(exceptions []string) string {
+	return strings.Join(exceptions, ",")
+}
+
+// scanTask scans a single task row selected with the id, date, time, title, comment, repeat, until,
+// remaining, exceptions, workday, shift, created, deleted, list_id and priority columns, in that order,
+// followed by the columns scanned into extra.
+// Exceptions are stored as a comma-separated list of dates, the list as its id, zero for none,
+// and the priority as its level, see priorityLevel.
+func scanTask(sc scanner, extra ...any) (*Task, error) {
+	var (
+		task       Task
+		exceptions string
+		listID     int64
+		priority   int
+	)
+	dest := []any{&task.ID, &task.Date, &task.Time, &task.Title, &task.Comment, &task.Repeat, &task.Until, &task.Remaining,
+		&exceptions, &task.Workday, &task.Shift, &task.Created, &task.Deleted, &listID, &priority}
+	if err := sc.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if exceptions != "" {
+		task.Exceptions = strings.Split(exceptions, ",")
+	}
+	task.ListID, task.Priority = formatListID(listID), priorityName(priority)
+	return &task, nil
 }
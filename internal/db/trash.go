@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TrashedTasks returns the tasks in the trash, the most recently deleted first.
+func (s *sqlStore) TrashedTasks(ctx context.Context) ([]*Task, error) {
+	query := `SELECT id, date, time, title, comment, repeat, until, remaining, exceptions, workday, shift, created, deleted,
+		list_id, priority FROM scheduler WHERE deleted <> '' ORDER BY deleted DESC, id DESC`
+	rows, err := s.query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select the tasks in the trash: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := []*Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task while building the trash: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows while building the trash: %w", err)
+	}
+	if err := s.loadTags(ctx, tasks...); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// RestoreTask takes the task with the given id out of the trash, recording it in the audit log, see WithActor.
+// If the task isn't in the trash, it will return ErrTaskNotFound.
+func (s *sqlStore) RestoreTask(ctx context.Context, id string) error {
+	taskID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(ctx, func(tx *sqlStore) error {
+		query := `UPDATE scheduler SET deleted = '' WHERE id = ? AND deleted <> ''`
+		res, err := tx.exec(ctx, query, taskID)
+		if err != nil {
+			return fmt.Errorf("failed to restore task with id '%s': %w", id, err)
+		}
+
+		count, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected while restoring: %w", err)
+		}
+		if count != 1 {
+			return fmt.Errorf(`incorrect id for restoring task '%s': %w`, id, ErrTaskNotFound)
+		}
+
+		restored, err := tx.GetTask(ctx, id)
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, AuditRestore, id, nil, restored)
+	})
+}
+
+// PurgeTask permanently deletes the task with the given id from the trash, together with its tags,
+// and returns the task as it was. If the task isn't in the trash, it will return ErrTaskNotFound.
+func (s *sqlStore) PurgeTask(ctx context.Context, id string) (*Task, error) {
+	taskID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.purge(ctx, `id = ?`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) != 1 {
+		return nil, fmt.Errorf(`incorrect id for purging task '%s': %w`, id, ErrTaskNotFound)
+	}
+	return tasks[0], nil
+}
+
+// PurgeTrash permanently deletes the tasks moved to the trash before the given time, or all of them
+// if the time is zero. It returns the tasks deleted as they were.
+func (s *sqlStore) PurgeTrash(ctx context.Context, before time.Time) ([]*Task, error) {
+	if before.IsZero() {
+		return s.purge(ctx, "")
+	}
+	return s.purge(ctx, `deleted < ?`, before.UTC().Format(time.RFC3339))
+}
+
+// purge permanently deletes the tasks in the trash matching the condition, all of them if it's empty,
+// together with their tags, in a single transaction, recording each of them in the audit log, see WithActor.
+// It returns the tasks deleted as they were, in no particular order.
+func (s *sqlStore) purge(ctx context.Context, cond string, args ...any) ([]*Task, error) {
+	query := `DELETE FROM scheduler WHERE deleted <> ''`
+	if cond != "" {
+		query += ` AND ` + cond
+	}
+	query += ` RETURNING id, date, time, title, comment, repeat, until, remaining, exceptions, workday, shift, created,
+		deleted, list_id, priority`
+
+	tasks := []*Task{}
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		rows, err := tx.query(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to purge the trash: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			task, err := scanTask(rows)
+			if err != nil {
+				return fmt.Errorf("failed to scan the purged task: %w", err)
+			}
+			tasks = append(tasks, task)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to iterate the purged tasks: %w", err)
+		}
+		rows.Close()
+
+		// The links to the tags are still there until they're pruned.
+		if err := tx.loadTags(ctx, tasks...); err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if err := tx.audit(ctx, AuditPurge, task.ID, task, nil); err != nil {
+				return err
+			}
+		}
+		return tx.pruneTags(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The operations recorded as changes.
+const (
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+	ChangeDone   = "done"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrUndoConflict  = errors.New("a later change of the task must be undone first")
+)
+
+// Change is an operation on a task that can be undone: an update, a deletion or a completion.
+// Previous is the task as it was before the operation, and Changed is the time of the operation
+// in RFC 3339 format, in UTC. Undone is the time the change was undone, empty if it wasn't.
+type Change struct {
+	ID        string `json:"id"`
+	TaskID    string `json:"task_id"`
+	Operation string `json:"operation"`
+	Changed   string `json:"changed"`
+	Undone    string `json:"undone,omitempty"`
+	Previous  *Task  `json:"previous"`
+
+	changeID, taskID, completionID int64
+}
+
+const changeColumns = `id, task_id, operation, completion_id, changed, undone,
+	date, time, title, comment, repeat, until, remaining, exceptions, workday, shift, tags, list_id, priority`
+
+// recordChange records an operation on a task given its state before the operation,
+// and the id of the completion the operation added, if any.
+func (s *sqlStore) recordChange(ctx context.Context, operation string, previous *Task, completionID int64) error {
+	taskID, err := parseID(previous.ID)
+	if err != nil {
+		return err
+	}
+
+	listID, err := parseListID(previous.ListID)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO changes (task_id, operation, completion_id, changed,
+		date, time, title, comment, repeat, until, remaining, exceptions, workday, shift, tags, list_id, priority)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = s.exec(ctx, query, taskID, operation, completionID, time.Now().UTC().Format(time.RFC3339),
+		previous.Date, previous.Time, previous.Title, previous.Comment, previous.Repeat, previous.Until,
+		previous.Remaining, joinExceptions(previous.Exceptions), previous.Workday, previous.Shift,
+		strings.Join(previous.Tags, ","), listID, priorityLevel(previous.Priority))
+	if err != nil {
+		return fmt.Errorf("failed to record the %s of task with id '%s': %w", operation, previous.ID, err)
+	}
+	return nil
+}
+
+// Changes returns the changes made since the given time that haven't been undone, the most recent first.
+func (s *sqlStore) Changes(ctx context.Context, since time.Time) ([]*Change, error) {
+	query := `SELECT ` + changeColumns + ` FROM changes WHERE undone = '' AND changed >= ? ORDER BY id DESC`
+	rows, err := s.query(ctx, query, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to select changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []*Change{}
+	for rows.Next() {
+		change, err := scanChange(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan change while building the change list: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows while building the change list: %w", err)
+	}
+	return changes, nil
+}
+
+// UndoChange undoes the change with the given id, or the most recent one if the id is empty, in a single transaction:
+// it brings the task back to its previous state, taking it out of the trash if needed, and removes the completion
+// the change added, recording the undo in the audit log, see WithActor. Only the changes made since the given time that haven't been undone yet can be undone,
+// otherwise it will return ErrNothingToUndo. The changes of a task are undone from the latest one backwards,
+// so it will return ErrUndoConflict for a change followed by another one of the same task.
+// If the task has been purged from the trash since, it will return ErrTaskNotFound.
+// It returns the undone change and the task as it was before undoing it, nil if it was in the trash.
+func (s *sqlStore) UndoChange(ctx context.Context, id string, since time.Time) (*Change, *Task, error) {
+	var (
+		change  *Change
+		current *Task
+	)
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		query := `SELECT ` + changeColumns + ` FROM changes WHERE undone = '' AND changed >= ?`
+		args := []any{since.UTC().Format(time.RFC3339)}
+		if id != "" {
+			changeID, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid change id '%s': %w", id, ErrNothingToUndo)
+			}
+			query, args = query+` AND id = ?`, append(args, changeID)
+		}
+		query += ` ORDER BY id DESC LIMIT 1`
+
+		var err error
+		change, err = scanChange(tx.queryRow(ctx, query, args...))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNothingToUndo
+		}
+		if err != nil {
+			return fmt.Errorf("failed to select the change to undo: %w", err)
+		}
+
+		var later int
+		err = tx.queryRow(ctx, `SELECT COUNT(*) FROM changes WHERE task_id = ? AND id > ? AND undone = ''`,
+			change.taskID, change.changeID).Scan(&later)
+		if err != nil {
+			return fmt.Errorf("failed to count the later changes of task with id '%s': %w", change.TaskID, err)
+		}
+		if later > 0 {
+			return ErrUndoConflict
+		}
+		current, err = tx.GetTask(ctx, change.TaskID)
+		if errors.Is(err, ErrTaskNotFound) {
+			current = nil
+		} else if err != nil {
+			return err
+		}
+
+		// The task goes back to its list, unless the list has been deleted since.
+		task := change.Previous
+		listID, err := tx.checkList(ctx, task.ListID)
+		if errors.Is(err, ErrListNotFound) {
+			task.ListID = ""
+		} else if err != nil {
+			return err
+		}
+		query = `UPDATE scheduler SET date = ?, time = ?, title = ?, comment = ?, repeat = ?,
+			until = ?, remaining = ?, exceptions = ?, workday = ?, shift = ?, list_id = ?, priority = ?, deleted = ''
+			WHERE id = ?`
+		res, err := tx.exec(ctx, query, task.Date, task.Time, task.Title, task.Comment, task.Repeat,
+			task.Until, task.Remaining, joinExceptions(task.Exceptions), task.Workday, task.Shift, listID,
+			priorityLevel(task.Priority), change.taskID)
+		if err != nil {
+			return fmt.Errorf("failed to restore task with id '%s': %w", change.TaskID, err)
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected while undoing: %w", err)
+		}
+		if count != 1 {
+			return fmt.Errorf(`incorrect id for restoring task '%s': %w`, change.TaskID, ErrTaskNotFound)
+		}
+		if err := tx.setTags(ctx, change.taskID, task.Tags); err != nil {
+			return err
+		}
+
+		if change.completionID != 0 {
+			if _, err := tx.exec(ctx, `DELETE FROM completions WHERE id = ?`, change.completionID); err != nil {
+				return fmt.Errorf("failed to remove the completion of task with id '%s': %w", change.TaskID, err)
+			}
+		}
+
+		change.Undone = time.Now().UTC().Format(time.RFC3339)
+		if _, err := tx.exec(ctx, `UPDATE changes SET undone = ? WHERE id = ?`, change.Undone, change.changeID); err != nil {
+			return fmt.Errorf("failed to mark change with id '%s' as undone: %w", change.ID, err)
+		}
+
+		restored, err := tx.GetTask(ctx, change.TaskID)
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, AuditUndo, change.TaskID, current, restored)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return change, current, nil
+}
+
+// PruneChanges deletes the changes made before the given time, which can no longer be undone,
+// and returns their number.
+func (s *sqlStore) PruneChanges(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.exec(ctx, `DELETE FROM changes WHERE changed < ?`, before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune changes: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected while pruning changes: %w", err)
+	}
+	return count, nil
+}
+
+// scanChange scans a row of changeColumns.
+func scanChange(row scanner) (*Change, error) {
+	var (
+		change           Change
+		task             Task
+		exceptions, tags string
+		listID           int64
+		priority         int
+	)
+	err := row.Scan(&change.changeID, &change.taskID, &change.Operation, &change.completionID, &change.Changed, &change.Undone,
+		&task.Date, &task.Time, &task.Title, &task.Comment, &task.Repeat, &task.Until, &task.Remaining,
+		&exceptions, &task.Workday, &task.Shift, &tags, &listID, &priority)
+	if err != nil {
+		return nil, err
+	}
+	change.ID, change.TaskID = strconv.FormatInt(change.changeID, 10), strconv.FormatInt(change.taskID, 10)
+	task.ID = change.TaskID
+	if exceptions != "" {
+		task.Exceptions = strings.Split(exceptions, ",")
+	}
+	if tags != "" {
+		task.Tags = strings.Split(tags, ",")
+	}
+	task.ListID, task.Priority = formatListID(listID), priorityName(priority)
+	change.Previous = &task
+	return &change, nil
+}
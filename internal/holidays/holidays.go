@@ -0,0 +1,233 @@
+package holidays
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout    = "20060102"
+	maxEventDays  = 366
+	icalDateChars = 8
+)
+
+var dateLayoutsCSV = []string{dateLayout, "2006-01-02", "02.01.2006"}
+
+// Calendar is a set of public holidays loaded from a local file.
+// A nil *Calendar is valid and has no holidays, so only weekends are days off.
+type Calendar struct {
+	days map[string]string
+}
+
+// Load reads the holiday calendar from the given file.
+// The format is chosen by the file extension: ".ics" for iCalendar and ".csv" for CSV.
+// If the path is empty, Load returns a nil calendar without holidays.
+// If the file can't be read or parsed, Load returns an error.
+func Load(path string) (*Calendar, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening holiday calendar '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	c := &Calendar{days: make(map[string]string)}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".ics", ".ical":
+		err = c.readICal(f)
+	case ".csv":
+		err = c.readCSV(f)
+	default:
+		return nil, fmt.Errorf("unsupported holiday calendar format '%s': expected .ics or .csv", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading holiday calendar '%s': %w", path, err)
+	}
+	return c, nil
+}
+
+// Len returns the number of holidays in the calendar.
+func (c *Calendar) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.days)
+}
+
+// IsHoliday reports whether the given date is a holiday of the calendar.
+func (c *Calendar) IsHoliday(date time.Time) bool {
+	if c == nil {
+		return false
+	}
+	_, ok := c.days[date.Format(dateLayout)]
+	return ok
+}
+
+// IsWorkday reports whether the given date is neither a weekend nor a holiday.
+func (c *Calendar) IsWorkday(date time.Time) bool {
+	wd := date.Weekday()
+	return wd != time.Saturday && wd != time.Sunday && !c.IsHoliday(date)
+}
+
+// readCSV reads holidays from CSV records of the form "date[,name]".
+// Dates can be written as "20060102", "2006-01-02" or "02.01.2006".
+// A header row, empty lines and lines starting with '#' are skipped.
+func (c *Calendar) readCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		date, ok := parseCSVDate(record[0])
+		if !ok {
+			if line == 1 {
+				continue
+			}
+			return fmt.Errorf("invalid date '%s' on line %d", record[0], line)
+		}
+
+		var name string
+		if len(record) > 1 {
+			name = record[1]
+		}
+		c.days[date.Format(dateLayout)] = name
+	}
+}
+
+// readICal reads holidays from the VEVENT components of an iCalendar stream (RFC 5545).
+// Every day from DTSTART up to, but not including, DTEND is a holiday; without DTEND only DTSTART is.
+// Recurring events are rejected, since each holiday has to be listed with its own dates.
+func (c *Calendar) readICal(r io.Reader) error {
+	lines, err := unfoldICal(r)
+	if err != nil {
+		return err
+	}
+
+	var (
+		inEvent          bool
+		start, end, name string
+		recurring        bool
+	)
+	for _, line := range lines {
+		prop, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Parameters such as ";VALUE=DATE" don't matter for whole days.
+		prop, _, _ = strings.Cut(strings.ToUpper(prop), ";")
+
+		switch {
+		case prop == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			inEvent = true
+			start, end, name, recurring = "", "", "", false
+
+		case prop == "END" && strings.EqualFold(value, "VEVENT"):
+			inEvent = false
+			if recurring {
+				return fmt.Errorf("event '%s' is recurring: list each holiday date separately", name)
+			}
+			if err := c.addEvent(start, end, name); err != nil {
+				return err
+			}
+
+		case !inEvent:
+			continue
+
+		case prop == "DTSTART":
+			start = value
+		case prop == "DTEND":
+			end = value
+		case prop == "SUMMARY":
+			name = value
+		case prop == "RRULE" || prop == "RDATE":
+			recurring = true
+		}
+	}
+	return nil
+}
+
+// addEvent adds the days of a single VEVENT to the calendar.
+func (c *Calendar) addEvent(start, end, name string) error {
+	first, err := parseICalDate(start)
+	if err != nil {
+		return fmt.Errorf("event '%s': invalid DTSTART: %w", name, err)
+	}
+
+	last := first
+	if end != "" {
+		endDate, err := parseICalDate(end)
+		if err != nil {
+			return fmt.Errorf("event '%s': invalid DTEND: %w", name, err)
+		}
+		// DTEND of an all-day event is exclusive.
+		if endDate.After(first) {
+			last = endDate.AddDate(0, 0, -1)
+		}
+	}
+
+	for i, date := 0, first; !date.After(last); i, date = i+1, date.AddDate(0, 0, 1) {
+		if i >= maxEventDays {
+			return fmt.Errorf("event '%s' is longer than %d days", name, maxEventDays)
+		}
+		c.days[date.Format(dateLayout)] = name
+	}
+	return nil
+}
+
+// unfoldICal splits an iCalendar stream into logical lines,
+// joining the continuation lines that start with a space or a tab.
+func unfoldICal(r io.Reader) ([]string, error) {
+	var lines []string
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("invalid iCalendar: %w", err)
+	}
+	return lines, nil
+}
+
+// parseICalDate parses the date part of an iCalendar DATE or DATE-TIME value.
+func parseICalDate(value string) (time.Time, error) {
+	if len(value) < icalDateChars {
+		return time.Time{}, fmt.Errorf("value '%s' is too short", value)
+	}
+	return time.Parse(dateLayout, value[:icalDateChars])
+}
+
+// parseCSVDate parses a CSV date in any of the supported layouts.
+func parseCSVDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayoutsCSV {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/mascotmascot1/go-todo/internal/api"
 	"github.com/mascotmascot1/go-todo/internal/config"
+	"github.com/mascotmascot1/go-todo/internal/db"
+	"github.com/mascotmascot1/go-todo/internal/holidays"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -17,14 +19,16 @@ type server struct {
 	logger *log.Logger
 }
 
-// New returns a new server instance with the given configuration and logger.
-// It sets up a Chi router with the handlers for signin, nextdate, tasks, task, update, delete and task done endpoints.
+// New returns a new server instance with the given configuration, task store, holiday calendar and logger.
+// It sets up a Chi router with the handlers for signin, nextdate, occurrences, tasks, agenda, task, update, delete,
+// trash, purge, task restore, history, history stats, changes, undo, audit, tags, tag rename, tag merge, lists, list,
+// list add, list update, list delete, task move, task parse, task done and task skip endpoints.
 // It also sets up a file server to serve static files from the web directory.
 // The server is configured to listen on the address <host>:<port>, with the given timeouts.
-func New(cfg *config.Config, logger *log.Logger) *server {
+func New(cfg *config.Config, store db.TaskStore, cal *holidays.Calendar, logger *log.Logger) *server {
 	r := chi.NewRouter()
 
-	h := api.NewHandlers(&cfg.Limits, &cfg.Auth, logger)
+	h := api.NewHandlers(store, &cfg.Limits, &cfg.Auth, cal, cfg.Calendar.Location, logger)
 	api.Init(r, h)
 
 	fileServer := http.FileServer(http.Dir(cfg.Server.WebDir))
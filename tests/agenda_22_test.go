@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type agendaDay struct {
+	Date  string              `json:"date"`
+	Tasks []map[string]string `json:"tasks"`
+}
+
+func TestAgenda(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+
+	base := time.Now().AddDate(0, 0, 10)
+	day := func(n int) string {
+		return base.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	addTaskValues(t, map[string]any{"date": day(1), "title": "Разовая"})
+	addTaskValues(t, map[string]any{"date": day(1), "time": "08:00", "title": "Разовая утром"})
+	addTaskValues(t, map[string]any{"date": day(0), "title": "Через день", "repeat": "d 2"})
+	addTaskValues(t, map[string]any{"date": day(0), "title": "До послезавтра", "repeat": "d 1", "until": day(2)})
+	addTaskValues(t, map[string]any{"date": day(0), "time": "09:00", "title": "Дважды в день", "repeat": "h 6 09:00-18:00"})
+	addTaskValues(t, map[string]any{"date": day(8), "title": "После диапазона"})
+	// Повторяющаяся задача из прошлого попадает в диапазон своими будущими повторениями.
+	_, err = db.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`,
+		day(-30), "Раз в три дня", "", "d 3")
+	assert.NoError(t, err)
+
+	body, err := requestJSON("api/agenda?"+url.Values{"from": {day(0)}, "to": {day(6)}}.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m struct {
+		Days []agendaDay `json:"days"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m))
+	if !assert.Len(t, m.Days, 7, "В ответе должны быть все дни диапазона") {
+		return
+	}
+
+	counts := map[string][]int{}
+	for i, d := range m.Days {
+		assert.Equal(t, day(i), d.Date)
+		for _, task := range d.Tasks {
+			assert.Equal(t, d.Date, task["date"], "Дата повторения должна совпадать с днём")
+			counts[task["title"]] = append(counts[task["title"]], i)
+		}
+	}
+	assert.Equal(t, []int{1}, counts["Разовая"])
+	assert.Equal(t, []int{0, 2, 4, 6}, counts["Через день"])
+	assert.Equal(t, []int{0, 1, 2}, counts["До послезавтра"])
+	assert.Equal(t, []int{0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6}, counts["Дважды в день"])
+	assert.Equal(t, []int{0, 3, 6}, counts["Раз в три дня"])
+	assert.Empty(t, counts["После диапазона"])
+
+	if second := m.Days[1].Tasks; assert.Len(t, second, 5) {
+		assert.Empty(t, second[0]["time"], "Задачи на весь день идут первыми")
+		assert.Equal(t, "08:00", second[2]["time"])
+		assert.Equal(t, "09:00", second[3]["time"])
+		assert.Equal(t, "15:00", second[4]["time"])
+	}
+
+	for _, query := range []url.Values{
+		{"from": {"abc"}},
+		{"from": {day(2)}, "to": {day(1)}},
+		{"from": {day(0)}, "to": {day(100)}},
+	} {
+		ret, err := postJSON("api/agenda?"+query.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для параметров %s", query.Encode())
+	}
+}
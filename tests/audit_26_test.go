@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type auditEntry struct {
+	ID        string         `json:"id"`
+	Operation string         `json:"operation"`
+	TaskID    string         `json:"task_id"`
+	Remote    string         `json:"remote"`
+	Before    map[string]any `json:"before"`
+	After     map[string]any `json:"after"`
+}
+
+type auditPage struct {
+	Entries    []auditEntry `json:"entries"`
+	NextCursor string       `json:"next_cursor"`
+}
+
+func getAudit(t *testing.T, query url.Values) auditPage {
+	body, err := requestJSON("api/audit?"+query.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var page auditPage
+	assert.NoError(t, json.Unmarshal(body, &page))
+	return page
+}
+
+func TestAudit(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	id := addTask(t, task{date: date, title: "Полить цветы", repeat: "d 1"})
+	ret, err := postJSON("api/task", map[string]any{"id": id, "date": date, "title": "Полить все цветы",
+		"repeat": "d 1"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	for _, path := range []string{"api/task/done?id=", "api/task?id="} {
+		method := http.MethodPost
+		if path == "api/task?id=" {
+			method = http.MethodDelete
+		}
+		ret, err = postJSON(path+id, nil, method)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+
+	page := getAudit(t, url.Values{"task_id": {id}})
+	if !assert.Len(t, page.Entries, 4, "Каждое изменение задачи должно попасть в журнал") {
+		return
+	}
+	var ops []string
+	for _, e := range page.Entries {
+		assert.Equal(t, id, e.TaskID)
+		assert.NotEmpty(t, e.Remote, "В журнале должен быть адрес клиента")
+		ops = append(ops, e.Operation)
+	}
+	assert.Equal(t, []string{"delete", "done", "update", "add"}, ops, "Последние записи идут первыми")
+
+	deleted, done, updated, added := page.Entries[0], page.Entries[1], page.Entries[2], page.Entries[3]
+	assert.Nil(t, added.Before)
+	assert.Equal(t, "Полить цветы", added.After["title"])
+	assert.Equal(t, "Полить цветы", updated.Before["title"])
+	assert.Equal(t, "Полить все цветы", updated.After["title"])
+	assert.NotEmpty(t, updated.After["created"], "Задача после изменения записывается такой, какой сохранена")
+	assert.Equal(t, date, done.Before["date"])
+	assert.NotEqual(t, date, done.After["date"], "После выполнения задача переносится")
+	assert.NotNil(t, deleted.Before)
+	assert.Nil(t, deleted.After)
+
+	page = getAudit(t, url.Values{"task_id": {id}, "operation": {"update"}})
+	assert.Len(t, page.Entries, 1)
+	page = getAudit(t, url.Values{"task_id": {id}, "limit": {"3"}})
+	if assert.Len(t, page.Entries, 3) && assert.NotEmpty(t, page.NextCursor) {
+		page = getAudit(t, url.Values{"task_id": {id}, "limit": {"3"}, "cursor": {page.NextCursor}})
+		if assert.Len(t, page.Entries, 1, "Вторая страница журнала") {
+			assert.Equal(t, "add", page.Entries[0].Operation)
+		}
+	}
+
+	body, err := requestJSON("api/audit?"+url.Values{"task_id": {id}, "format": {"csv"}}.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 5, "Выгрузка содержит заголовок и все записи") {
+		assert.Equal(t, "operation", records[0][4])
+		assert.Equal(t, "add", records[4][4])
+	}
+
+	for _, query := range []url.Values{
+		{"operation": {"rename"}},
+		{"from": {"abc"}},
+		{"format": {"xml"}},
+		{"limit": {"0"}},
+		{"cursor": {"abc"}},
+	} {
+		ret, err := postJSON("api/audit?"+query.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для параметров %s", query.Encode())
+	}
+
+	_, err = db.Exec("DELETE FROM audit")
+	assert.Error(t, err, "Записи журнала нельзя удалять")
+	_, err = db.Exec("UPDATE audit SET actor = 'кто-то'")
+	assert.Error(t, err, "Записи журнала нельзя изменять")
+}
+
+func TestAuditEveryChange(t *testing.T) {
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	id := addTaskValues(t, map[string]any{"date": date, "title": "Полить грядки", "repeat": "d 1",
+		"tags": []string{"аудит-сад"}})
+
+	for _, req := range []struct {
+		path   string
+		body   map[string]any
+		method string
+	}{
+		{"api/task/skip?id=" + id, nil, http.MethodPost},
+		{"api/undo", nil, http.MethodPost},
+		{"api/task?id=" + id, nil, http.MethodDelete},
+		{"api/task/restore?id=" + id, nil, http.MethodPost},
+		{"api/tags/rename", map[string]any{"from": "аудит-сад", "to": "аудит-дача"}, http.MethodPost},
+		{"api/tags/merge", map[string]any{"from": []string{"аудит-дача"}, "to": "аудит-огород"}, http.MethodPost},
+		{"api/task?id=" + id, nil, http.MethodDelete},
+		{"api/trash?id=" + id, nil, http.MethodDelete},
+	} {
+		ret, err := postJSON(req.path, req.body, req.method)
+		assert.NoError(t, err)
+		assert.Empty(t, ret["error"], "Запрос %s %s", req.method, req.path)
+	}
+
+	page := getAudit(t, url.Values{"task_id": {id}})
+	var ops []string
+	for _, e := range page.Entries {
+		ops = append(ops, e.Operation)
+	}
+	if !assert.Equal(t, []string{"purge", "delete", "update", "update", "restore", "delete", "undo", "update", "add"},
+		ops, "Каждое изменение задачи должно попасть в журнал") {
+		return
+	}
+
+	purged, merged, renamed, restored := page.Entries[0], page.Entries[2], page.Entries[3], page.Entries[4]
+	undone, skipped := page.Entries[6], page.Entries[7]
+	assert.Equal(t, date, skipped.Before["date"])
+	assert.NotEqual(t, date, skipped.After["date"], "Пропущенная задача переносится")
+	assert.Equal(t, skipped.After["date"], undone.Before["date"])
+	assert.Equal(t, date, undone.After["date"], "Отмена возвращает задачу к прежнему состоянию")
+	assert.Nil(t, restored.Before, "Задача в корзине не считается задачей")
+	assert.Equal(t, "Полить грядки", restored.After["title"])
+	assert.Equal(t, []any{"аудит-сад"}, renamed.Before["tags"])
+	assert.Equal(t, []any{"аудит-дача"}, renamed.After["tags"])
+	assert.Equal(t, []any{"аудит-огород"}, merged.After["tags"])
+	assert.Equal(t, "Полить грядки", purged.Before["title"])
+	assert.NotEmpty(t, purged.Before["deleted"], "Удалённая навсегда задача записывается такой, какой была в корзине")
+	assert.Nil(t, purged.After)
+}
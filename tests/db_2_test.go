@@ -11,11 +11,21 @@ import (
 )
 
 type Task struct {
-	ID      int64  `db:"id"`
-	Date    string `db:"date"`
-	Title   string `db:"title"`
-	Comment string `db:"comment"`
-	Repeat  string `db:"repeat"`
+	ID         int64  `db:"id"`
+	Date       string `db:"date"`
+	Time       string `db:"time"`
+	Title      string `db:"title"`
+	Comment    string `db:"comment"`
+	Repeat     string `db:"repeat"`
+	Until      string `db:"until"`
+	Remaining  int    `db:"remaining"`
+	Exceptions string `db:"exceptions"`
+	Workday    bool   `db:"workday"`
+	Shift      int    `db:"shift"`
+	Created    string `db:"created"`
+	Deleted    string `db:"deleted"`
+	ListID     int64  `db:"list_id"`
+	Priority   int    `db:"priority"`
 }
 
 func count(db *sqlx.DB) (int, error) {
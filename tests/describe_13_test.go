@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeRepeat(t *testing.T) {
+	today := time.Now().Format(`20060102`)
+
+	tbl := []struct {
+		repeat, en, ru string
+	}{
+		{"d 3", "every 3 days", "каждые 3 дня"},
+		{"y", "every year", "каждый год"},
+		{"w 1,4", "every week on Mon and Thu", "каждую неделю по понедельникам и четвергам"},
+		{"m -1,15 3,6,9,12", "on the 15th and last day of Mar, Jun, Sep, Dec",
+			"15-го и последнего числа марта, июня, сентября, декабря"},
+		{"m w2:2,-1:5", "on the 2nd Tue and the last Fri of every month",
+			"во 2-й вторник и в последнюю пятницу каждого месяца"},
+		{"b last", "on the last business day of every month", "в последний рабочий день каждого месяца"},
+		{"FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "every year on the 4th Thu of Nov", "каждый год в 4-й четверг ноября"},
+	}
+	for _, v := range tbl {
+		id := addTaskValues(t, map[string]any{
+			"date":   today,
+			"title":  "Описание правила",
+			"repeat": v.repeat,
+		})
+
+		m, err := postJSON("api/task?id="+id+"&lang=en", nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, v.en, m["description"], "Неверное описание правила %q", v.repeat)
+
+		m, err = postJSON("api/task?id="+id, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, v.ru, m["description"], "Неверное описание правила %q", v.repeat)
+	}
+
+	id := addTaskValues(t, map[string]any{
+		"date":  today,
+		"title": "Без повторения",
+	})
+	m, err := postJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	_, ok := m["description"]
+	assert.False(t, ok, "У задачи без правила повторения не должно быть описания")
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExceptions(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+
+	tbl := []map[string]any{
+		{"date": today, "title": "Тест", "exceptions": []string{today}},
+		{"date": today, "title": "Тест", "repeat": "d 1", "exceptions": []string{"01.01.2025"}},
+	}
+	for _, v := range tbl {
+		m, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+
+		e, ok := m["error"]
+		assert.False(t, !ok || len(fmt.Sprint(e)) == 0,
+			"Ожидается ошибка для задачи %v", v)
+	}
+
+	id := addTaskValues(t, map[string]any{
+		"date":       today,
+		"title":      "Пробежка",
+		"repeat":     "d 1",
+		"exceptions": []string{now.AddDate(0, 0, 2).Format(`20060102`), today, now.AddDate(0, 0, 1).Format(`20060102`)},
+	})
+
+	var task Task
+	err := db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 3).Format(`20060102`), task.Date)
+	assert.Equal(t, fmt.Sprintf("%s,%s,%s", today, now.AddDate(0, 0, 1).Format(`20060102`),
+		now.AddDate(0, 0, 2).Format(`20060102`)), task.Exceptions)
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 4).Format(`20060102`), task.Date)
+	assert.Empty(t, task.Exceptions)
+}
+
+func TestUpdateExceptions(t *testing.T) {
+	now := time.Now()
+	today := now.Format(`20060102`)
+	exceptions := []any{now.AddDate(0, 0, 5).Format(`20060102`), now.AddDate(0, 0, 6).Format(`20060102`)}
+	id := addTaskValues(t, map[string]any{"date": today, "title": "Зарядка", "repeat": "d 1", "exceptions": exceptions})
+
+	ret, err := postJSON("api/task", map[string]any{"id": id, "date": today, "title": "Утренняя зарядка",
+		"repeat": "d 1"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, exceptions, getTask(t, id)["exceptions"], "Изменение без исключений сохраняет их")
+
+	ret, err = postJSON("api/task", map[string]any{"id": id, "date": today, "title": "Утренняя зарядка",
+		"repeat": "d 1", "exceptions": []string{}}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Nil(t, getTask(t, id)["exceptions"], "Пустой список убирает исключения")
+}
+
+func TestSkipTask(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Планёрка",
+		repeat: "d 7",
+	})
+
+	ret, err := postJSON("api/task/skip?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var skipped Task
+	err = db.Get(&skipped, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 7).Format(`20060102`), skipped.Date)
+
+	once := addTask(t, task{
+		date:  now.Format(`20060102`),
+		title: "Разовая задача",
+	})
+	ret, err = postJSON("api/task/skip?id="+once, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	ret, err = postJSON("api/task/skip?id=wjhgese", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type completion struct {
+	ID        string `json:"id"`
+	TaskID    string `json:"task_id"`
+	Title     string `json:"title"`
+	Date      string `json:"date"`
+	Completed string `json:"completed"`
+}
+
+func getHistory(t *testing.T, query url.Values) []completion {
+	body, err := requestJSON("api/history?"+query.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m struct {
+		Completions []completion `json:"completions"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m.Completions
+}
+
+func TestHistory(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+	_, err = db.Exec("DELETE FROM completions")
+	assert.NoError(t, err)
+
+	day := func(n int) string {
+		return time.Now().AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	once := addTask(t, task{date: day(1), title: "Разовая"})
+	every := addTask(t, task{date: day(0), title: "Через день", repeat: "d 2"})
+	for _, id := range []string{once, every} {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+	ret, err := postJSON("api/task", map[string]any{"id": every, "date": day(2), "title": "Переименованная",
+		"repeat": "d 2"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	history := getHistory(t, nil)
+	if assert.Len(t, history, 2, "Каждое выполнение должно попасть в историю") {
+		assert.Equal(t, every, history[0].TaskID, "Последние выполнения идут первыми")
+		assert.Equal(t, "Через день", history[0].Title, "В истории остаётся заголовок на момент выполнения")
+		assert.Equal(t, day(0), history[0].Date)
+		assert.NotEmpty(t, history[0].Completed)
+		assert.Equal(t, "Разовая", history[1].Title, "Выполнение разовой задачи тоже записывается")
+	}
+	assert.Len(t, getHistory(t, url.Values{"task_id": {once}}), 1)
+	assert.Len(t, getHistory(t, url.Values{"from": {day(1)}, "to": {day(1)}}), 1)
+	assert.Len(t, getHistory(t, url.Values{"limit": {"1"}}), 1)
+
+	for _, query := range []url.Values{
+		{"from": {"abc"}},
+		{"to": {"2024-01-01"}},
+		{"limit": {"0"}},
+	} {
+		ret, err = postJSON("api/history?"+query.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для параметров %s", query.Encode())
+	}
+
+	// Ежедневная задача выполнена во все дни, кроме третьего дня назад.
+	daily := addTask(t, task{date: day(0), title: "Каждый день", repeat: "d 1"})
+	for _, n := range []int{-5, -4, -2, -1} {
+		_, err = db.Exec(`INSERT INTO completions (task_id, title, date, time, completed) VALUES (?, ?, ?, ?, ?)`,
+			daily, "Каждый день", day(n), "", time.Now().UTC().Format(time.RFC3339))
+		assert.NoError(t, err)
+	}
+
+	body, err := requestJSON("api/history/stats", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m struct {
+		Stats []map[string]any `json:"stats"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m))
+	stats := map[string]map[string]any{}
+	for _, s := range m.Stats {
+		stats[s["id"].(string)] = s
+	}
+	assert.NotContains(t, stats, once, "У разовых задач нет статистики")
+	if s, ok := stats[daily]; assert.True(t, ok, "Статистика ежедневной задачи") {
+		assert.Equal(t, 4.0, s["completed"])
+		assert.Equal(t, 5.0, s["expected"])
+		assert.Equal(t, 0.8, s["rate"])
+		assert.Equal(t, 2.0, s["streak"])
+		assert.Equal(t, 2.0, s["best_streak"])
+	}
+	if s, ok := stats[every]; assert.True(t, ok, "Статистика задачи через день") {
+		assert.Equal(t, 1.0, s["completed"])
+		assert.Equal(t, 1.0, s["expected"])
+		assert.Equal(t, 1.0, s["streak"])
+	}
+}
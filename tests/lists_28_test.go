@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type list struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func getLists(t *testing.T) []list {
+	body, err := requestJSON("api/lists", nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m struct {
+		Lists []list `json:"lists"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m.Lists
+}
+
+func addList(t *testing.T, name string) string {
+	ret, err := postJSON("api/list", map[string]any{"name": name}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["id"], "Не возвращён id для списка %s", name)
+	return fmt.Sprint(ret["id"])
+}
+
+func TestLists(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	for _, table := range []string{"scheduler", "lists"} {
+		_, err := db.Exec("DELETE FROM " + table)
+		assert.NoError(t, err)
+	}
+
+	home, work := addList(t, "Дом"), addList(t, " Работа ")
+	ret, err := postJSON("api/list", map[string]any{"name": "Дом"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Списки не могут повторяться")
+	ret, err = postJSON("api/list", map[string]any{"name": " "}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Название списка обязательно")
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	report := addTaskValues(t, map[string]any{"date": date, "title": "Отчёт", "list_id": work})
+	call := addTaskValues(t, map[string]any{"date": date, "title": "Созвон", "list_id": work})
+	flowers := addTask(t, task{date: date, title: "Полить цветы"})
+	ret, err = postJSON("api/task", map[string]any{"date": date, "title": "Нет списка", "list_id": "100500"},
+		http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Список задачи должен существовать")
+
+	assert.Equal(t, work, getTask(t, report)["list_id"])
+	ret, err = postJSON("api/task", map[string]any{"id": call, "date": date, "title": "Созвон с командой"},
+		http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, work, getTask(t, call)["list_id"], "Изменение без списка не убирает задачу из него")
+	assert.Equal(t, []list{{home, "Дом", 0}, {work, "Работа", 2}}, getLists(t))
+	assert.Equal(t, 2, getTasksPage(t, url.Values{"list_id": {work}}).Total, "Отбор задач по списку")
+
+	ret, err = postJSON("api/tasks/move", map[string]any{"ids": []string{flowers, report}, "list_id": home},
+		http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, ret["moved"])
+	assert.Equal(t, home, getTask(t, flowers)["list_id"])
+	ret, err = postJSON("api/tasks/move", map[string]any{"ids": []string{call, "100500"}, "list_id": home},
+		http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	assert.Equal(t, work, getTask(t, call)["list_id"], "При ошибке задачи не переносятся")
+	undo(t, "")
+	assert.Equal(t, work, getTask(t, report)["list_id"], "Перенос задачи можно отменить")
+
+	ret, err = postJSON("api/list", map[string]any{"id": work, "name": "Офис"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	body, err := requestJSON("api/list?id="+work, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var got list
+	assert.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, list{work, "Офис", 2}, got)
+
+	for _, query := range []url.Values{
+		{"id": {work}, "tasks": {"archive"}},
+		{"id": {work}, "to": {home}},
+		{"id": {work}, "tasks": {"move"}, "to": {work}},
+		{"id": {work}, "tasks": {"move"}, "to": {"0" + work}},
+	} {
+		ret, err := postJSON("api/list?"+query.Encode(), nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для параметров %s", query.Encode())
+	}
+
+	ret, err = postJSON("api/list?"+url.Values{"id": {work}, "tasks": {"move"}, "to": {home}}.Encode(), nil,
+		http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, ret["moved"])
+	assert.Equal(t, home, getTask(t, call)["list_id"], "Задачи удалённого списка переносятся")
+	assert.Equal(t, []list{{home, "Дом", 3}}, getLists(t))
+
+	ret, err = postJSON("api/list?id="+home, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, ret["deleted"])
+	notFoundTask(t, flowers)
+	assert.Empty(t, getLists(t))
+	assert.Len(t, getTrash(t), 3, "Задачи удалённого списка попадают в корзину")
+	undo(t, "")
+	restored := getTask(t, flowers)
+	assert.Equal(t, "Полить цветы", restored["title"])
+	assert.Nil(t, restored["list_id"], "Восстановленная задача остаётся без списка")
+}
@@ -0,0 +1,27 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaVersion(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	var versions []int
+	err := db.Select(&versions, `SELECT version FROM schema_version ORDER BY version`)
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, versions, "Версия схемы базы данных не записана") {
+		return
+	}
+	for i, v := range versions {
+		assert.Equal(t, i+1, v, "Миграции должны применяться по порядку")
+	}
+
+	var count int
+	err = db.Get(&count, `SELECT COUNT(*) FROM pragma_table_info('scheduler') WHERE name IN ('time', 'until', 'exceptions', 'workday')`)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count, "Не все миграции применены к таблице scheduler")
+}
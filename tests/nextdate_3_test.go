@@ -81,3 +81,53 @@ func TestNextDate(t *testing.T) {
 	}
 	check()
 }
+
+func checkNextDates(t *testing.T, now string, tbl []nextDate) {
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=%s&date=%s&repeat=%s",
+			now, url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`,
+			v.date, v.repeat, v.want)
+	}
+}
+
+func TestNextDateMonthWeekday(t *testing.T) {
+	checkNextDates(t, "20240126", []nextDate{
+		{"20240101", "m w6:1", ""},
+		{"20240101", "m w0:1", ""},
+		{"20240101", "m w1:8", ""},
+		{"20240101", "m w1:1 13", ""},
+		{"20240101", "m w2:2", "20240213"},
+		{"20240101", "m w-1:5", "20240223"},
+		{"20240101", "m w2:2,-1:5 3,6", "20240312"},
+		{"20240101", "m w-5:4 2", "20240201"},
+		{"20240101", "m w5:1 2", "20440229"},
+		{"20240401", "m w1:7", "20240407"},
+	})
+}
+
+func TestNextDateIntervals(t *testing.T) {
+	checkNextDates(t, "20240126", []nextDate{
+		{"20240101", "y 0", ""},
+		{"20240101", "y 101", ""},
+		{"20240101", "w/0 1", ""},
+		{"20240101", "m/101 1", ""},
+		{"20231231", "m/2 15 1", ""},
+		{"20200101", "y 2", "20260101"},
+		{"20240101", "w/2 1,4", "20240129"},
+		{"20240108", "w/2 1,4", "20240205"},
+		{"20240101", "w/3 5", "20240216"},
+		{"20240301", "w/2 3", "20240313"},
+		{"20240101", "m/1 5", "20240205"},
+		{"20240115", "m/3 15", "20240415"},
+		{"20231130", "m/2 -1", "20240131"},
+		{"20240101", "m/2 w1:1", "20240304"},
+	})
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getOccurrences(t *testing.T, query url.Values) map[string]any {
+	query.Set("now", "20240126")
+	body, err := getBody("api/occurrences?" + query.Encode())
+	assert.NoError(t, err)
+
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m
+}
+
+func TestOccurrences(t *testing.T) {
+	tbl := []struct {
+		date, repeat, count, until string
+		want                       []string
+	}{
+		{"20240101", "d 7", "3", "", []string{"20240129", "20240205", "20240212"}},
+		{"20240201", "m 1", "3", "", []string{"20240201", "20240301", "20240401"}},
+		{"20240101", "w 1,5", "", "", []string{"20240129", "20240202", "20240205", "20240209",
+			"20240212", "20240216", "20240219", "20240223", "20240226", "20240301"}},
+		{"20240201", "d 2", "10", "20240206", []string{"20240201", "20240203", "20240205"}},
+		{"20240201", "FREQ=DAILY;COUNT=3", "5", "", []string{"20240201", "20240202", "20240203"}},
+		{"20240210", "d 1", "5", "20240205", []string{}},
+	}
+	for _, v := range tbl {
+		m := getOccurrences(t, url.Values{
+			"date":   {v.date},
+			"repeat": {v.repeat},
+			"count":  {v.count},
+			"until":  {v.until},
+		})
+		dates := []string{}
+		for _, d := range m["dates"].([]any) {
+			dates = append(dates, fmt.Sprint(d))
+		}
+		assert.Equal(t, v.want, dates, "%v", v)
+	}
+
+	m := getOccurrences(t, url.Values{"date": {"20240101"}, "repeat": {"d 1"}, "count": {"1000"}})
+	assert.Len(t, m["dates"], 100, "Количество дат должно быть ограничено")
+
+	for _, v := range []url.Values{
+		{"date": {"20240101"}, "repeat": {"d 1"}, "count": {"0"}},
+		{"date": {"20240101"}, "repeat": {"d 1"}, "count": {"abc"}},
+		{"date": {"20240101"}, "repeat": {"k 34"}},
+		{"date": {"20240101"}, "repeat": {"d 1"}, "until": {"01.02.2024"}},
+		{"date": {"2024-01-01"}, "repeat": {"d 1"}},
+		{"date": {"20240101"}},
+	} {
+		m := getOccurrences(t, v)
+		assert.NotEmpty(t, m["error"], "Ожидается ошибка для %v", v)
+	}
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tasksPage struct {
+	Tasks      []map[string]string `json:"tasks"`
+	NextCursor string              `json:"next_cursor"`
+	Total      int                 `json:"total"`
+}
+
+func getTasksPage(t *testing.T, query url.Values) tasksPage {
+	body, err := requestJSON("api/tasks?"+query.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var page tasksPage
+	assert.NoError(t, json.Unmarshal(body, &page))
+	return page
+}
+
+func TestTasksPages(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	ids := map[string]bool{}
+	for i := range 7 {
+		id := addTask(t, task{date: date, title: fmt.Sprintf("Задача %d", 7-i)})
+		ids[id] = true
+	}
+
+	// Задача, добавленная во время обхода, не должна сбить обход.
+	query := url.Values{"limit": {"3"}, "sort": {"title"}, "order": {"desc"}}
+	var titles []string
+	for range 10 {
+		page := getTasksPage(t, query)
+		for _, task := range page.Tasks {
+			assert.True(t, ids[task["id"]], "Задача %s встретилась дважды или лишняя", task["id"])
+			delete(ids, task["id"])
+			titles = append(titles, task["title"])
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if query.Get("cursor") == "" {
+			assert.Equal(t, 7, page.Total)
+			addTask(t, task{date: date, title: "Задача 9"})
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+	assert.Empty(t, ids, "Обход должен вернуть все задачи")
+	assert.Equal(t, []string{"Задача 7", "Задача 6", "Задача 5", "Задача 4", "Задача 3", "Задача 2", "Задача 1"}, titles)
+
+	page := getTasksPage(t, url.Values{"sort": {"created"}, "limit": {"100"}})
+	assert.Equal(t, 8, page.Total)
+	assert.Len(t, page.Tasks, 8)
+	assert.Empty(t, page.NextCursor, "Последняя страница без курсора")
+	if assert.NotEmpty(t, page.Tasks) {
+		assert.Equal(t, "Задача 9", page.Tasks[len(page.Tasks)-1]["title"])
+		assert.NotEmpty(t, page.Tasks[0]["created"])
+	}
+
+	for _, query := range []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"abc"}},
+		{"sort": {"size"}},
+		{"order": {"up"}},
+		{"cursor": {"abc"}},
+		{"sort": {"id"}, "cursor": {query.Get("cursor")}},
+	} {
+		ret, err := postJSON("api/tasks?"+query.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для параметров %s", query.Encode())
+	}
+}
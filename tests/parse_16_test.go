@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTask(t *testing.T) {
+	// 16 октября 2026 года — пятница.
+	now := "20261016"
+	tbl := []struct {
+		text, title, date, time, repeat string
+	}{
+		{"Pay rent on the last day of every month", "Pay rent", "20261031", "", "m -1"},
+		{"standup every weekday starting tomorrow", "standup", "20261019", "", "w 1,2,3,4,5"},
+		{"Оплатить аренду в последний день месяца", "Оплатить аренду", "20261031", "", "m -1"},
+		{"Планёрка по будням начиная с завтра", "Планёрка", "20261019", "", "w 1,2,3,4,5"},
+		{"Call mom every Sunday at 6pm", "Call mom", "20261018", "18:00", "w 7"},
+		{"Отчёт по понедельникам и пятницам в 9:30", "Отчёт", "20261016", "09:30", "w 1,5"},
+		{"Dentist on October 20 at 14:15", "Dentist", "20261020", "14:15", ""},
+		{"Подать декларацию 30 апреля", "Подать декларацию", "20270430", "", ""},
+		{"Water plants every 3 days", "Water plants", "20261016", "", "d 3"},
+		{"Backup every month from 2026-11-05", "Backup", "20261105", "", "m 5"},
+		{"Встреча через неделю в 7 вечера", "Встреча", "20261023", "19:00", ""},
+		{"Take pills daily at 8am", "Take pills", "20261016", "08:00", "d 1"},
+		{"Проверить почту каждые 2 часа", "Проверить почту", "20261016", "00:00", "h 2"},
+		{"Pay salary on the last business day of the month", "Pay salary", "20261030", "", "b last"},
+		{"Gym every other week on Tue and Thu", "Gym", "20261020", "", "w/2 2,4"},
+		{"Поздравить маму ежегодно 5 марта", "Поздравить маму", "20270305", "", "y"},
+		{"Just a task", "Just a task", "20261016", "", ""},
+	}
+	for _, v := range tbl {
+		ret, err := postJSON("api/task/parse?now="+now, map[string]any{"text": v.text}, http.MethodPost)
+		assert.NoError(t, err)
+		if !assert.Empty(t, ret["error"], "Неожиданная ошибка для %q", v.text) {
+			continue
+		}
+		got := func(key string) string {
+			if ret[key] == nil {
+				return ""
+			}
+			return fmt.Sprint(ret[key])
+		}
+		assert.Equal(t, v.title, got("title"), "Неверный заголовок для %q", v.text)
+		assert.Equal(t, v.date, got("date"), "Неверная дата для %q", v.text)
+		assert.Equal(t, v.time, got("time"), "Неверное время для %q", v.text)
+		assert.Equal(t, v.repeat, got("repeat"), "Неверное правило повторения для %q", v.text)
+		if v.repeat != "" {
+			assert.NotEmpty(t, got("description"), "Нет описания правила для %q", v.text)
+		}
+	}
+
+	for _, text := range []string{"", "   ", "Meeting on 31.02", "Gym at 25:00"} {
+		ret, err := postJSON("api/task/parse?now="+now, map[string]any{"text": text}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для %q", text)
+	}
+}
+
+func TestAddTaskText(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	ret, err := postJSON("api/task", map[string]any{
+		"text":    "Gym every Mon, Wed and Fri at 7:00",
+		"comment": "Ноги",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	id := fmt.Sprint(ret["id"])
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Gym", task.Title)
+	assert.Equal(t, "Ноги", task.Comment)
+	assert.Equal(t, "07:00", task.Time)
+	assert.Equal(t, "w 1,3,5", task.Repeat)
+	date, err := time.Parse("20060102", task.Date)
+	assert.NoError(t, err)
+	assert.Contains(t, []time.Weekday{time.Monday, time.Wednesday, time.Friday}, date.Weekday())
+
+	// Поля, переданные явно, важнее разобранных из текста.
+	ret, err = postJSON("api/task", map[string]any{
+		"text":  "Read every day",
+		"title": "Книга",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, fmt.Sprint(ret["id"]))
+	assert.NoError(t, err)
+	assert.Equal(t, "Книга", task.Title)
+	assert.Equal(t, "d 1", task.Repeat)
+
+	ret, err = postJSON("api/task?"+url.Values{"tz": {"Mars/Olympus"}}.Encode(), map[string]any{
+		"text": "Read every day",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriority(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+
+	now := time.Now()
+	soon := now.AddDate(0, 0, 1).Format(`20060102`)
+	later := now.AddDate(0, 0, 5).Format(`20060102`)
+	milk := addTaskValues(t, map[string]any{"date": soon, "title": "Купить молоко", "priority": "none"})
+	rent := addTaskValues(t, map[string]any{"date": later, "title": "Оплатить аренду", "priority": "urgent"})
+	report := addTaskValues(t, map[string]any{"date": later, "title": "Сдать отчёт", "priority": "high"})
+	flowers := addTaskValues(t, map[string]any{"date": soon, "title": "Полить цветы", "priority": "low"})
+
+	assert.Nil(t, getTask(t, milk)["priority"], "Приоритет none не сохраняется")
+	assert.Equal(t, "urgent", getTask(t, rent)["priority"])
+	ret, err := postJSON("api/task", map[string]any{"id": rent, "date": later, "title": "Оплатить аренду и свет"},
+		http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, "urgent", getTask(t, rent)["priority"], "Изменение без приоритета сохраняет его")
+	for _, priority := range []string{"critical", "High"} {
+		ret, err := postJSON("api/task", map[string]any{"date": soon, "title": "Приоритет", "priority": priority},
+			http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для приоритета %s", priority)
+	}
+
+	ids := func(query url.Values) []string {
+		list := []string{}
+		for _, task := range getTasksPage(t, query).Tasks {
+			list = append(list, task["id"])
+		}
+		return list
+	}
+	assert.Equal(t, []string{rent, report, flowers, milk}, ids(url.Values{"sort": {"priority"}}),
+		"Сортировка по приоритету, затем по дате")
+	assert.Equal(t, []string{milk, flowers, report, rent}, ids(url.Values{"sort": {"priority"}, "order": {"desc"}}))
+	assert.Equal(t, []string{milk, flowers, rent, report}, ids(nil), "Без просроченных задач порядок по дате")
+
+	for id, days := range map[string]int{report: -2, flowers: -3} {
+		_, err = db.Exec("UPDATE scheduler SET date = ? WHERE id = ?", now.AddDate(0, 0, days).Format(`20060102`), id)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{report, flowers, milk, rent}, ids(nil), "Просроченные важные задачи идут первыми")
+	assert.Equal(t, []string{flowers, report, milk, rent}, ids(url.Values{"sort": {"date"}}),
+		"Явная сортировка по дате не поднимает важные задачи")
+
+	page := getTasksPage(t, url.Values{"limit": {"1"}})
+	if assert.Len(t, page.Tasks, 1) && assert.NotEmpty(t, page.NextCursor) {
+		assert.Equal(t, report, page.Tasks[0]["id"])
+		page = getTasksPage(t, url.Values{"limit": {"1"}, "cursor": {page.NextCursor}})
+		if assert.Len(t, page.Tasks, 1, "Вторая страница") {
+			assert.Equal(t, flowers, page.Tasks[0]["id"])
+		}
+	}
+}
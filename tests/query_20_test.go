@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchQuery(t *testing.T) {
+	if !Search {
+		return
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+
+	now := time.Now()
+	past := now.AddDate(0, 0, -3).Format(`20060102`)
+	soon := now.AddDate(0, 0, 3).Format(`20060102`)
+	later := now.AddDate(0, 1, 0).Format(`20060102`)
+	for _, v := range []task{
+		{date: past, title: "Сдать отчёт", comment: "Квартальный", repeat: ""},
+		{date: soon, title: "Купить молоко", comment: "100% жирности", repeat: ""},
+		{date: soon, title: "Полить цветы", comment: "", repeat: "d 3"},
+		{date: later, title: "Оплатить аренду", comment: "До 5 числа", repeat: "m 5"},
+	} {
+		_, err := db.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`,
+			v.date, v.title, v.comment, v.repeat)
+		assert.NoError(t, err)
+	}
+
+	titles := func(search string) []string {
+		list := []string{}
+		for _, task := range getTasks(t, url.QueryEscape(search)) {
+			list = append(list, task["title"])
+		}
+		sort.Strings(list)
+		return list
+	}
+	date := func(t time.Time) string {
+		return t.Format(`2006-01-02`)
+	}
+
+	assert.Equal(t, []string{"Сдать отчёт"}, titles("overdue"), "Просроченные задачи")
+	assert.Equal(t, []string{"Купить молоко", "Полить цветы", "Сдать отчёт"},
+		titles("before:"+date(now.AddDate(0, 0, 10))), "Задачи до даты")
+	assert.Equal(t, []string{"Оплатить аренду"}, titles("after:"+date(now.AddDate(0, 0, 10))), "Задачи после даты")
+	assert.Equal(t, []string{"Оплатить аренду", "Полить цветы"}, titles("repeat:yes"), "Повторяющиеся задачи")
+	assert.Equal(t, []string{"Купить молоко", "Сдать отчёт"}, titles("repeat:no"), "Разовые задачи")
+	assert.Equal(t, []string{"Купить молоко"}, titles(`comment:"100%"`), "Поиск подстроки в комментарии")
+	assert.Empty(t, titles(`comment:"1000%"`), "Символ % ищется буквально")
+	assert.Equal(t, []string{"Оплатить аренду", "Сдать отчёт"}, titles("overdue OR аренду"), "Оператор OR")
+	assert.Equal(t, []string{"Купить молоко", "Оплатить аренду"}, titles("NOT overdue -цветы"), "Оператор NOT")
+	assert.Equal(t, []string{"Полить цветы"}, titles("(молоко OR цветы) AND repeat:yes"), "Скобки")
+	assert.Equal(t, []string{"Купить молоко", "Полить цветы"},
+		titles(now.AddDate(0, 0, 3).Format(`02.01.2006`)), "Поиск по дате")
+
+	for _, query := range []url.Values{{"search": {"молоко ??"}}, {"search": {"молоко ??"}, "sort": {"date"}}} {
+		page := getTasksPage(t, query)
+		assert.Equal(t, 1, page.Total, "Слово без букв и цифр не учитывается в запросе %s", query.Encode())
+		if assert.Len(t, page.Tasks, 1) {
+			assert.Equal(t, "Купить молоко", page.Tasks[0]["title"])
+		}
+	}
+
+	for _, search := range []string{"(молоко", "молоко)", "before:завтра", "repeat:maybe", "title:", `title:"молоко`,
+		"tag:", "NOT", "молоко OR"} {
+		ret, err := postJSON("api/tasks?search="+url.QueryEscape(search), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для запроса %s", search)
+	}
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func addTaskValues(t *testing.T, values map[string]any) string {
+	ret, err := postJSON("api/task", values, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["id"], "Не возвращён id для задачи %v", values)
+	return fmt.Sprint(ret["id"])
+}
+
+func TestRepeatEndValidation(t *testing.T) {
+	now := time.Now()
+	today := now.Format(`20060102`)
+
+	tbl := []map[string]any{
+		{"date": today, "title": "Тест", "until": now.AddDate(0, 0, 5).Format(`20060102`)},
+		{"date": today, "title": "Тест", "remaining": 3},
+		{"date": today, "title": "Тест", "repeat": "d 1", "remaining": -1},
+		{"date": today, "title": "Тест", "repeat": "d 1", "until": "31.12.2024"},
+		{"date": today, "title": "Тест", "repeat": "d 1", "until": now.AddDate(0, 0, -1).Format(`20060102`)},
+		{"date": "20200101", "title": "Тест", "repeat": "d 1", "until": "20200105"},
+	}
+	for _, v := range tbl {
+		m, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+
+		e, ok := m["error"]
+		assert.False(t, !ok || len(fmt.Sprint(e)) == 0,
+			"Ожидается ошибка для задачи %v", v)
+	}
+}
+
+func TestDoneRemaining(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTaskValues(t, map[string]any{
+		"date":      now.Format(`20060102`),
+		"title":     "Курс таблеток",
+		"repeat":    "d 1",
+		"remaining": 2,
+	})
+
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, float64(2), m["remaining"])
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), task.Date)
+	assert.Equal(t, 1, task.Remaining)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+}
+
+func TestDoneUntil(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTaskValues(t, map[string]any{
+		"date":   now.Format(`20060102`),
+		"title":  "Полив рассады",
+		"repeat": "d 2",
+		"until":  now.AddDate(0, 0, 3).Format(`20060102`),
+	})
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 2).Format(`20060102`), task.Date)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+}
+
+func TestUpdateRepeatEnd(t *testing.T) {
+	now := time.Now()
+	today := now.Format(`20060102`)
+	until := now.AddDate(0, 0, 10).Format(`20060102`)
+	id := addTaskValues(t, map[string]any{"date": today, "title": "Курс витаминов", "repeat": "d 1",
+		"until": until, "remaining": 5})
+
+	ret, err := postJSON("api/task", map[string]any{"id": id, "date": today, "title": "Курс витамина D",
+		"repeat": "d 1"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	task := getTask(t, id)
+	assert.Equal(t, until, task["until"], "Изменение без условий окончания сохраняет их")
+	assert.Equal(t, float64(5), task["remaining"])
+
+	ret, err = postJSON("api/task", map[string]any{"id": id, "date": today, "title": "Курс витамина D",
+		"repeat": ""}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	task = getTask(t, id)
+	assert.Nil(t, task["until"], "Без правила повтора условия окончания убираются")
+	assert.Nil(t, task["remaining"])
+
+	ret, err = postJSON("api/task", map[string]any{"id": id, "date": today, "title": "Курс витамина D",
+		"remaining": 3}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Условия окончания без правила повтора недопустимы")
+}
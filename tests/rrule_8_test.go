@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateRRule(t *testing.T) {
+	checkNextDates(t, "20240126", []nextDate{
+		{"20240101", "FREQ=HOURLY", ""},
+		{"20240101", "INTERVAL=2", ""},
+		{"20240101", "FREQ=DAILY;FOO=1", ""},
+		{"20240101", "FREQ=DAILY;INTERVAL=0", ""},
+		{"20240101", "FREQ=DAILY;COUNT=3;UNTIL=20240120", ""},
+		{"20240101", "FREQ=DAILY;BYDAY=2MO", ""},
+		{"20240101", "FREQ=MONTHLY;BYMONTHDAY=30;BYMONTH=2", ""},
+		{"20240101", "FREQ=DAILY;COUNT=5", ""},
+		{"20240101", "FREQ=DAILY;UNTIL=20240120", ""},
+		{"20240101", "FREQ=DAILY", "20240127"},
+		{"20240101", "FREQ=DAILY;INTERVAL=10", "20240131"},
+		{"20240101", "FREQ=DAILY;COUNT=40", "20240127"},
+		{"20240101", "FREQ=DAILY;UNTIL=20240131T000000Z", "20240127"},
+		{"20240101", "FREQ=WEEKLY;BYDAY=MO,TH", "20240129"},
+		{"20240101", "FREQ=WEEKLY;INTERVAL=2;BYDAY=TH", "20240201"},
+		{"20300101", "FREQ=WEEKLY", "20300108"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=2TU", "20240213"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=-1FR", "20240223"},
+		{"20240101", "RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "20240131"},
+		{"20240101", "FREQ=MONTHLY;BYMONTHDAY=-1", "20240131"},
+		{"20240101", "FREQ=MONTHLY;BYMONTHDAY=13;BYDAY=FR", "20240913"},
+		{"20240131", "FREQ=MONTHLY", "20240331"},
+		{"20240229", "FREQ=YEARLY", "20280229"},
+		{"20240101", "freq=yearly;bymonth=11;byday=4th", "20241128"},
+	})
+}
+
+func TestDoneRRuleCount(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Три подхода",
+		repeat: "FREQ=DAILY;COUNT=3",
+	})
+
+	for i := 0; i < 2; i++ {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		now = now.AddDate(0, 0, 1)
+		assert.Equal(t, now.Format(`20060102`), task.Date)
+		assert.Equal(t, fmt.Sprintf("FREQ=DAILY;COUNT=%d", 2-i), task.Repeat)
+	}
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFullTextSearch(t *testing.T) {
+	if !Search {
+		return
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	for _, v := range []task{
+		{date: date, title: "Купить молоко", comment: "и хлеб"},
+		{date: date, title: "Молоко для кота", comment: "Молоко, только молоко"},
+		{date: date, title: "Позвонить маме", comment: "Спросить про <молоко>"},
+		{date: date, title: "Pay rent", comment: "Before the 5th"},
+		{date: date, title: "Молочный коктейль", comment: ""},
+	} {
+		addTask(t, v)
+	}
+
+	titles := func(search string) []string {
+		list := []string{}
+		for _, task := range getTasks(t, url.QueryEscape(search)) {
+			list = append(list, task["title"])
+		}
+		return list
+	}
+
+	found := titles("молоко")
+	if assert.Len(t, found, 3) {
+		assert.Equal(t, "Молоко для кота", found[0], "Задачи должны быть упорядочены по релевантности")
+	}
+	assert.Len(t, titles("МОЛОКО"), 3, "Поиск не должен зависеть от регистра")
+	assert.Len(t, titles("мол*"), 4, "Поиск по префиксу")
+	assert.Empty(t, titles("мол"), "Без звездочки ищутся только целые слова")
+	assert.Equal(t, []string{"Pay rent"}, titles(`"pay rent"`), "Поиск по фразе")
+	assert.Empty(t, titles(`"rent pay"`), "Слова фразы должны идти подряд")
+	assert.Equal(t, []string{"Купить молоко"}, titles("молоко хлеб"), "Должны совпасть все слова")
+	assert.Empty(t, titles(`!!! ???`), "Строка без слов ничего не находит")
+
+	for _, task := range getTasks(t, "маме") {
+		assert.Equal(t, "Позвонить <mark>маме</mark>", task["snippet"])
+	}
+	for _, task := range getTasks(t, "спросить") {
+		assert.Contains(t, task["snippet"], "&lt;молоко&gt;", "Текст фрагмента должен экранироваться")
+	}
+	for _, task := range getTasks(t, "") {
+		assert.Empty(t, task["snippet"], "Фрагмент возвращается только при поиске")
+	}
+}
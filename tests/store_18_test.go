@@ -0,0 +1,614 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mascotmascot1/go-todo/internal/db"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+// storeChecks — проверки хранилища задач по возможностям. Каждая проверка получает хранилище,
+// в котором ещё нет задач, поэтому ошибка в одной из них не влияет на остальные.
+var storeChecks = []struct {
+	name  string
+	check func(t *testing.T, store db.TaskStore)
+}{
+	{"tasks", checkStoreTasks},
+	{"search", checkStoreSearch},
+	{"query", checkStoreQuery},
+	{"pages", checkStorePages},
+	{"trash", checkStoreTrash},
+	{"history", checkStoreHistory},
+	{"undo", checkStoreUndo},
+	{"audit", checkStoreAudit},
+	{"tags", checkStoreTags},
+	{"lists", checkStoreLists},
+	{"priority", checkStorePriority},
+}
+
+// runStoreChecks запускает все проверки хранилища, каждую на хранилище, которое возвращает open.
+func runStoreChecks(t *testing.T, open func(t *testing.T) db.TaskStore) {
+	for _, c := range storeChecks {
+		t.Run(c.name, func(t *testing.T) {
+			store := open(t)
+			if store == nil {
+				return
+			}
+			c.check(t, store)
+		})
+	}
+}
+
+// addStoreTasks добавляет в хранилище три задачи, проверяя, что они сохраняются без изменений,
+// и возвращает их с идентификаторами. Если задачу добавить не удалось, возвращает nil.
+func addStoreTasks(t *testing.T, store db.TaskStore) []*db.Task {
+	ctx := context.Background()
+
+	tasks := []*db.Task{
+		{Date: "20260105", Title: "Купить молоко", Comment: "Обезжиренное", Priority: db.PriorityLow},
+		{Date: "20260105", Time: "09:30", Title: "Standup", Repeat: "w 1,2,3,4,5", Workday: true, Shift: 1,
+			Tags: []string{"работа"}, Priority: db.PriorityUrgent},
+		{Date: "20260110", Title: "Оплатить аренду", Repeat: "m -1", Until: "20261231", Remaining: 3,
+			Exceptions: []string{"20260131", "20260228"}, Tags: []string{"дом", "счета"}},
+	}
+	for _, task := range tasks {
+		id, err := store.AddTask(ctx, task)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		assert.Positive(t, id)
+
+		got, err := store.GetTask(ctx, strconv.FormatInt(id, 10))
+		assert.NoError(t, err)
+		task.ID = strconv.FormatInt(id, 10)
+		assert.Equal(t, task, got)
+	}
+	return tasks
+}
+
+// pageTitles обходит все страницы списка задач, начиная с заданного фильтра, и возвращает заголовки задач.
+func pageTitles(t *testing.T, store db.TaskStore, filter db.TaskFilter) []string {
+	var titles []string
+	for {
+		page, err := store.Tasks(context.Background(), filter)
+		if !assert.NoError(t, err) {
+			break
+		}
+		for _, task := range page.Tasks {
+			titles = append(titles, task.Title)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	return titles
+}
+
+func checkStoreTasks(t *testing.T, store db.TaskStore) {
+	ctx := context.Background()
+	tasks := addStoreTasks(t, store)
+	if tasks == nil {
+		return
+	}
+
+	all, err := store.Tasks(ctx, db.TaskFilter{Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, all.Tasks, 3) {
+		// Задачи упорядочены по дате и времени.
+		assert.Equal(t, "Купить молоко", all.Tasks[0].Title)
+		assert.Equal(t, "Standup", all.Tasks[1].Title)
+	}
+	found, err := store.Tasks(ctx, db.TaskFilter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, found.Tasks, 1, "Ограничение количества задач")
+	assert.Equal(t, 3, found.Total)
+
+	previous, err := store.UpdateTask(ctx, tasks[0].ID, func(task *db.Task) error {
+		task.Title, task.Exceptions = "Купить кефир", nil
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, tasks[0], previous, "Возвращается задача до изменения")
+	got, err := store.GetTask(ctx, tasks[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Купить кефир", got.Title)
+	errInvalid := errors.New("недопустимое изменение")
+	_, err = store.UpdateTask(ctx, tasks[0].ID, func(task *db.Task) error {
+		task.Title = "Купить сливки"
+		return errInvalid
+	})
+	assert.ErrorIs(t, err, errInvalid)
+	got, err = store.GetTask(ctx, tasks[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Купить кефир", got.Title, "Задача не меняется, если изменение не удалось")
+	_, err = store.UpdateTask(ctx, "100500", func(*db.Task) error { return nil })
+	assert.ErrorIs(t, err, db.ErrTaskNotFound)
+
+	assert.NoError(t, store.DeleteTask(ctx, tasks[0].ID))
+	_, err = store.GetTask(ctx, tasks[0].ID)
+	assert.ErrorIs(t, err, db.ErrTaskNotFound)
+	assert.ErrorIs(t, store.DeleteTask(ctx, tasks[0].ID), db.ErrTaskNotFound)
+
+	_, err = store.GetTask(ctx, "abc")
+	assert.ErrorIs(t, err, db.ErrTaskNotFound)
+	_, err = store.GetTask(ctx, "")
+	assert.ErrorIs(t, err, db.ErrEmptyID)
+}
+
+func checkStoreSearch(t *testing.T, store db.TaskStore) {
+	ctx := context.Background()
+	if addStoreTasks(t, store) == nil {
+		return
+	}
+
+	found, err := store.Tasks(ctx, db.TaskFilter{Limit: 10, Search: "STANDUP"})
+	assert.NoError(t, err)
+	assert.Len(t, found.Tasks, 1, "Поиск по заголовку не должен зависеть от регистра")
+	found, err = store.Tasks(ctx, db.TaskFilter{Limit: 10, Search: "обезжир*"})
+	assert.NoError(t, err)
+	assert.Len(t, found.Tasks, 1, "Поиск по комментарию")
+	found, err = store.Tasks(ctx, db.TaskFilter{Limit: 10, Search: "05.01.2026"})
+	assert.NoError(t, err)
+	assert.Len(t, found.Tasks, 2, "Поиск по дате")
+}
+
+func checkStoreQuery(t *testing.T, store db.TaskStore) {
+	ctx := context.Background()
+	if addStoreTasks(t, store) == nil {
+		return
+	}
+
+	found, err := store.Tasks(ctx, db.TaskFilter{Limit: 10, Search: `repeat:yes AND (title:"stand" OR молок*) NOT after:2026-01-09`})
+	assert.NoError(t, err)
+	if assert.Len(t, found.Tasks, 1, "Поиск по запросу") {
+		assert.Equal(t, "Standup", found.Tasks[0].Title)
+	}
+	found, err = store.Tasks(ctx, db.TaskFilter{Limit: 10, Search: "overdue", Today: "20260106"})
+	assert.NoError(t, err)
+	assert.Len(t, found.Tasks, 2, "Просроченные задачи")
+	_, err = store.Tasks(ctx, db.TaskFilter{Limit: 10, Search: "(title:молоко"})
+	assert.ErrorIs(t, err, db.ErrInvalidQuery)
+}
+
+func checkStorePages(t *testing.T, store db.TaskStore) {
+	ctx := context.Background()
+	if addStoreTasks(t, store) == nil {
+		return
+	}
+
+	filter := db.TaskFilter{Limit: 2, Sort: db.SortCreated, Desc: true}
+	assert.Equal(t, []string{"Оплатить аренду", "Standup", "Купить молоко"}, pageTitles(t, store, filter),
+		"Обход страниц по курсору")
+
+	page, err := store.Tasks(ctx, filter)
+	if assert.NoError(t, err) && assert.NotEmpty(t, page.NextCursor) {
+		filter.Sort, filter.Cursor = db.SortID, page.NextCursor
+		_, err = store.Tasks(ctx, filter)
+		assert.ErrorIs(t, err, db.ErrInvalidCursor, "Курсор другого порядка сортировки")
+	}
+}
+
+func checkStoreTrash(t *testing.T, store db.TaskStore) {
+	ctx := context.Background()
+	tasks := addStoreTasks(t, store)
+	if tasks == nil {
+		return
+	}
+
+	assert.NoError(t, store.DeleteTask(ctx, tasks[0].ID))
+	trash, err := store.TrashedTasks(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, trash, 1, "Удалённая задача должна попасть в корзину") {
+		assert.Equal(t, tasks[0].ID, trash[0].ID)
+		assert.NotEmpty(t, trash[0].Deleted)
+	}
+	assert.NoError(t, store.RestoreTask(ctx, tasks[0].ID))
+	assert.ErrorIs(t, store.RestoreTask(ctx, tasks[0].ID), db.ErrTaskNotFound)
+	_, err = store.GetTask(ctx, tasks[0].ID)
+	assert.NoError(t, err, "Восстановленная задача снова доступна")
+	_, err = store.PurgeTask(ctx, tasks[0].ID)
+	assert.ErrorIs(t, err, db.ErrTaskNotFound, "Удалить навсегда можно только задачу из корзины")
+
+	assert.NoError(t, store.DeleteTask(ctx, tasks[0].ID))
+	assert.NoError(t, store.DeleteTask(ctx, tasks[1].ID))
+	purgedTask, err := store.PurgeTask(ctx, tasks[1].ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Standup", purgedTask.Title)
+		assert.Equal(t, []string{"работа"}, purgedTask.Tags, "Удалённая навсегда задача возвращается с метками")
+		assert.NotEmpty(t, purgedTask.Deleted)
+	}
+	purged, err := store.PurgeTrash(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, purged, "Задачи, удалённые недавно, остаются в корзине")
+	purged, err = store.PurgeTrash(ctx, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	if assert.Len(t, purged, 1) {
+		assert.Equal(t, tasks[0].ID, purged[0].ID)
+	}
+	assert.ErrorIs(t, store.RestoreTask(ctx, tasks[0].ID), db.ErrTaskNotFound)
+}
+
+func checkStoreHistory(t *testing.T, store db.TaskStore) {
+	ctx := context.Background()
+	tasks := addStoreTasks(t, store)
+	if tasks == nil {
+		return
+	}
+	rent := tasks[2]
+
+	next := *rent
+	next.Date, next.Remaining = "20260227", 2
+	done := &db.Completion{TaskID: rent.ID, Title: rent.Title, Date: rent.Date}
+	assert.NoError(t, store.CompleteTask(ctx, done, &next))
+	assert.NotEmpty(t, done.ID)
+	assert.NotEmpty(t, done.Completed)
+	got, err := store.GetTask(ctx, rent.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "20260227", got.Date, "Повторяющаяся задача переносится на следующую дату")
+	last := &db.Completion{TaskID: rent.ID, Title: rent.Title, Date: next.Date}
+	assert.NoError(t, store.CompleteTask(ctx, last, nil))
+	_, err = store.GetTask(ctx, rent.ID)
+	assert.ErrorIs(t, err, db.ErrTaskNotFound, "Завершённая задача попадает в корзину")
+	assert.ErrorIs(t, store.CompleteTask(ctx, &db.Completion{TaskID: rent.ID}, nil), db.ErrTaskNotFound)
+
+	history, err := store.History(ctx, db.HistoryFilter{TaskID: rent.ID})
+	assert.NoError(t, err)
+	if assert.Len(t, history, 2, "Выполнение несуществующей задачи не записывается") {
+		assert.Equal(t, last.ID, history[0].ID, "Последние выполнения идут первыми")
+		assert.Equal(t, *done, *history[1])
+	}
+	history, err = store.History(ctx, db.HistoryFilter{From: "20260201", To: "20260228"})
+	assert.NoError(t, err)
+	assert.Len(t, history, 1, "Отбор по запланированной дате")
+	history, err = store.History(ctx, db.HistoryFilter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+}
+
+func checkStoreUndo(t *testing.T, store db.TaskStore) {
+	ctx := context.Background()
+	tasks := addStoreTasks(t, store)
+	if tasks == nil {
+		return
+	}
+	rent := tasks[2]
+	next := *rent
+	next.Date, next.Remaining = "20260227", 2
+	assert.NoError(t, store.CompleteTask(ctx, &db.Completion{TaskID: rent.ID, Title: rent.Title, Date: rent.Date}, &next))
+	assert.NoError(t, store.CompleteTask(ctx, &db.Completion{TaskID: rent.ID, Title: rent.Title, Date: next.Date}, nil))
+
+	since := time.Now().Add(-time.Hour)
+	changes, err := store.Changes(ctx, since)
+	assert.NoError(t, err)
+	if !assert.GreaterOrEqual(t, len(changes), 2) {
+		return
+	}
+	assert.Equal(t, db.ChangeDone, changes[0].Operation, "Последние изменения идут первыми")
+	_, _, err = store.UndoChange(ctx, changes[0].ID, time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, db.ErrNothingToUndo, "Изменения вне окна отмены не отменяются")
+	_, _, err = store.UndoChange(ctx, changes[1].ID, since)
+	assert.ErrorIs(t, err, db.ErrUndoConflict)
+	undone, current, err := store.UndoChange(ctx, "", since)
+	assert.NoError(t, err)
+	assert.Equal(t, changes[0].ID, undone.ID)
+	assert.Nil(t, current, "Задача в корзине до отмены не возвращается")
+	got, err := store.GetTask(ctx, rent.ID)
+	assert.NoError(t, err, "Отмена выполнения возвращает задачу из корзины")
+	assert.Equal(t, "20260227", got.Date)
+	history, err := store.History(ctx, db.HistoryFilter{TaskID: rent.ID})
+	assert.NoError(t, err)
+	assert.Len(t, history, 1, "Отмена выполнения удаляет его из истории")
+	pruned, err := store.PruneChanges(ctx, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Positive(t, pruned)
+}
+
+func checkStoreAudit(t *testing.T, store db.TaskStore) {
+	ctx := context.Background()
+
+	entry := &db.AuditEntry{Actor: "Анна", Remote: "127.0.0.1:1234", Operation: db.AuditAdd, TaskID: "100",
+		Before: json.RawMessage("null"), After: json.RawMessage(`{"title":"Оплатить аренду"}`)}
+	assert.NoError(t, store.AddAuditEntry(ctx, entry))
+	assert.NotEmpty(t, entry.ID)
+	entries, err := store.Audit(ctx, db.AuditFilter{Actor: "Анна", Operation: db.AuditAdd})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, *entry, *entries[0])
+	}
+	entries, err = store.Audit(ctx, db.AuditFilter{AfterID: entry.ID})
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "Записи после последней нет")
+
+	actx := db.WithActor(ctx, "Борис", "10.0.0.1:5678")
+	id, err := store.AddTask(actx, &db.Task{Date: "20260105", Title: "Купить молоко"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	taskID := strconv.FormatInt(id, 10)
+	_, err = store.UpdateTask(actx, taskID, func(task *db.Task) error {
+		task.Title = "Купить кефир"
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, store.DeleteTask(ctx, taskID))
+	entries, err = store.Audit(ctx, db.AuditFilter{TaskID: taskID})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3, "Изменения задачи записываются в журнал") {
+		assert.Equal(t, db.AuditDelete, entries[0].Operation)
+		assert.Empty(t, entries[0].Actor, "Изменение без участника записывается без него")
+		updated := entries[1]
+		assert.Equal(t, db.AuditUpdate, updated.Operation)
+		assert.Equal(t, "Борис", updated.Actor)
+		assert.Equal(t, "10.0.0.1:5678", updated.Remote)
+		var after db.Task
+		assert.NoError(t, json.Unmarshal(updated.After, &after))
+		assert.Equal(t, "Купить кефир", after.Title)
+		assert.NotEmpty(t, after.Created, "Задача после изменения записывается такой, какой сохранена")
+	}
+}
+
+func checkStoreTags(t *testing.T, store db.TaskStore) {
+	ctx := context.Background()
+	tasks := addStoreTasks(t, store)
+	if tasks == nil {
+		return
+	}
+
+	tags, err := store.Tags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*db.TagCount{{Name: "дом", Count: 1}, {Name: "работа", Count: 1}, {Name: "счета", Count: 1}}, tags)
+	found, err := store.Tasks(ctx, db.TaskFilter{Limit: 10, Tags: []string{"счета", "дом"}})
+	assert.NoError(t, err)
+	if assert.Len(t, found.Tasks, 1, "Отбор по всем меткам") {
+		assert.Equal(t, "Оплатить аренду", found.Tasks[0].Title)
+	}
+	found, err = store.Tasks(ctx, db.TaskFilter{Limit: 10, Tags: []string{"работа", "дом"}})
+	assert.NoError(t, err)
+	assert.Empty(t, found.Tasks)
+	found, err = store.Tasks(ctx, db.TaskFilter{Limit: 10, Search: "tag:Работа"})
+	assert.NoError(t, err)
+	assert.Len(t, found.Tasks, 1, "Поиск по метке не должен зависеть от регистра")
+
+	_, err = store.RenameTag(ctx, "работа", "счета")
+	assert.ErrorIs(t, err, db.ErrTagExists)
+	_, err = store.RenameTag(ctx, "отдых", "спорт")
+	assert.ErrorIs(t, err, db.ErrTagNotFound)
+	renamed, err := store.RenameTag(ctx, "работа", "офис")
+	assert.NoError(t, err)
+	if assert.Len(t, renamed, 1) {
+		assert.Equal(t, []string{"работа"}, renamed[0].Tags, "Задачи возвращаются с метками до переименования")
+	}
+	got, err := store.GetTask(ctx, tasks[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"офис"}, got.Tags)
+	_, err = store.MergeTags(ctx, []string{"офис", "отдых"}, "счета")
+	assert.ErrorIs(t, err, db.ErrTagNotFound)
+	merged, err := store.MergeTags(ctx, []string{"офис"}, "счета")
+	assert.NoError(t, err)
+	if assert.Len(t, merged, 1) {
+		assert.Equal(t, tasks[1].ID, merged[0].ID)
+	}
+	tags, err = store.Tags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*db.TagCount{{Name: "дом", Count: 1}, {Name: "счета", Count: 2}}, tags)
+
+	assert.NoError(t, store.DeleteTask(ctx, tasks[1].ID))
+	_, err = store.PurgeTask(ctx, tasks[1].ID)
+	assert.NoError(t, err)
+	tags, err = store.Tags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*db.TagCount{{Name: "дом", Count: 1}, {Name: "счета", Count: 1}}, tags,
+		"Метки удалённых навсегда задач не учитываются")
+}
+
+func checkStoreLists(t *testing.T, store db.TaskStore) {
+	ctx := context.Background()
+	tasks := addStoreTasks(t, store)
+	if tasks == nil {
+		return
+	}
+
+	home, work := &db.List{Name: "Дом"}, &db.List{Name: "Работа"}
+	for _, list := range []*db.List{home, work} {
+		id, err := store.AddList(ctx, list)
+		if !assert.NoError(t, err) {
+			return
+		}
+		list.ID = strconv.FormatInt(id, 10)
+	}
+	_, err := store.AddList(ctx, &db.List{Name: "Дом"})
+	assert.ErrorIs(t, err, db.ErrListExists)
+	moved, err := store.MoveTasks(ctx, []string{tasks[1].ID, tasks[2].ID}, work.ID)
+	assert.NoError(t, err)
+	assert.Len(t, moved, 2)
+	_, err = store.MoveTasks(ctx, []string{tasks[0].ID, "100500"}, work.ID)
+	assert.ErrorIs(t, err, db.ErrTaskNotFound)
+	_, err = store.MoveTasks(ctx, []string{tasks[0].ID}, "100500")
+	assert.ErrorIs(t, err, db.ErrListNotFound)
+	found, err := store.Tasks(ctx, db.TaskFilter{Limit: 10, ListID: work.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, found.Total, "Отбор задач по списку")
+	list, err := store.GetList(ctx, work.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, list.Count)
+	work.Name = "Дом"
+	assert.ErrorIs(t, store.UpdateList(ctx, work), db.ErrListExists)
+	work.Name = "Офис"
+	assert.NoError(t, store.UpdateList(ctx, work))
+	lists, err := store.Lists(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, lists, 2) {
+		assert.Equal(t, "Дом", lists[0].Name)
+		assert.Equal(t, "Офис", lists[1].Name)
+	}
+	_, err = store.DeleteList(ctx, work.ID, work.ID, false)
+	assert.ErrorIs(t, err, db.ErrMoveToSameList)
+	assert.NotErrorIs(t, err, db.ErrListNotFound, "Перенос задач в удаляемый список — ошибка запроса")
+	moved, err = store.DeleteList(ctx, work.ID, home.ID, false)
+	assert.NoError(t, err)
+	assert.Len(t, moved, 2)
+	_, err = store.GetList(ctx, work.ID)
+	assert.ErrorIs(t, err, db.ErrListNotFound)
+	got, err := store.GetTask(ctx, tasks[2].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, home.ID, got.ListID, "Задачи удалённого списка переносятся в другой список")
+}
+
+func checkStorePriority(t *testing.T, store db.TaskStore) {
+	if addStoreTasks(t, store) == nil {
+		return
+	}
+
+	for _, f := range []struct {
+		filter db.TaskFilter
+		titles []string
+		msg    string
+	}{
+		{db.TaskFilter{Limit: 1, Sort: db.SortPriority}, []string{"Standup", "Купить молоко", "Оплатить аренду"},
+			"Сортировка по приоритету"},
+		{db.TaskFilter{Limit: 1, Today: "20260106"}, []string{"Standup", "Купить молоко", "Оплатить аренду"},
+			"Просроченные срочные задачи идут первыми"},
+		{db.TaskFilter{Limit: 1, Today: "20260105"}, []string{"Купить молоко", "Standup", "Оплатить аренду"},
+			"Без просроченных задач порядок по дате"},
+	} {
+		assert.Equal(t, f.titles, pageTitles(t, store, f.filter), f.msg)
+	}
+}
+
+func TestSQLiteStore(t *testing.T) {
+	runStoreChecks(t, func(t *testing.T) db.TaskStore {
+		store, err := db.NewSQLiteStore(context.Background(), filepath.Join(t.TempDir(), "store.db"))
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+// TestSQLiteStoreAuditFailure проверяет, что изменение задачи не сохраняется, если его не удалось записать в журнал.
+func TestSQLiteStoreAuditFailure(t *testing.T) {
+	ctx := context.Background()
+	dbfile := filepath.Join(t.TempDir(), "store.db")
+	store, err := db.NewSQLiteStore(ctx, dbfile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer store.Close()
+	id, err := store.AddTask(ctx, &db.Task{Date: "20260105", Title: "Купить молоко"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	taskID := strconv.FormatInt(id, 10)
+
+	conn, err := sql.Open("sqlite", dbfile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	_, err = conn.Exec(`CREATE TRIGGER audit_broken BEFORE INSERT ON audit BEGIN SELECT RAISE(ABORT, 'broken'); END`)
+	assert.NoError(t, err)
+
+	_, err = store.UpdateTask(ctx, taskID, func(task *db.Task) error {
+		task.Title = "Купить кефир"
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Error(t, store.DeleteTask(ctx, taskID))
+	_, err = store.AddTask(ctx, &db.Task{Date: "20260105", Title: "Оплатить аренду"})
+	assert.Error(t, err)
+
+	task, err := store.GetTask(ctx, taskID)
+	assert.NoError(t, err, "Задача не удаляется без записи в журнале")
+	assert.Equal(t, "Купить молоко", task.Title, "Задача не меняется без записи в журнале")
+	page, err := store.Tasks(ctx, db.TaskFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page.Total, "Задача не добавляется без записи в журнале")
+	changes, err := store.Changes(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, changes, "Отменять нечего")
+}
+
+// TestPostgresStore проверяет хранилище PostgreSQL и копирование задач из SQLite.
+// Тест запускается, только если задана переменная окружения TODO_TEST_DSN. Она должна указывать
+// на отдельную базу данных: тест удаляет из неё все задачи.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("TODO_TEST_DSN")
+	if dsn == "" {
+		t.Skip("TODO_TEST_DSN не задана")
+	}
+	ctx := context.Background()
+
+	store, err := db.NewPostgresStore(ctx, dsn)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer store.Close()
+
+	clean := func(t *testing.T) {
+		pg, err := sql.Open("pgx", dsn)
+		assert.NoError(t, err)
+		defer pg.Close()
+		_, err = pg.Exec(`TRUNCATE scheduler, completions, changes, audit, tags, task_tags, lists RESTART IDENTITY`)
+		assert.NoError(t, err)
+	}
+	runStoreChecks(t, func(t *testing.T) db.TaskStore {
+		clean(t)
+		return store
+	})
+	clean(t)
+
+	source, err := db.NewSQLiteStore(ctx, filepath.Join(t.TempDir(), "source.db"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer source.Close()
+	_, err = source.AddList(ctx, &db.List{Name: "Дом"})
+	assert.NoError(t, err)
+	for _, title := range []string{"Первая", "Вторая", "Третья"} {
+		_, err := source.AddTask(ctx, &db.Task{Date: "20260105", Title: title, Tags: []string{"копия"}, ListID: "1"})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, source.DeleteTask(ctx, "2"))
+	logged := &db.AuditEntry{Actor: "Анна", Operation: db.AuditDelete, TaskID: "2",
+		Before: json.RawMessage(`{"title":"Вторая"}`), After: json.RawMessage("null")}
+	assert.NoError(t, source.AddAuditEntry(ctx, logged))
+
+	copied, err := db.CopyTasks(ctx, source, store)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, copied)
+
+	task, err := store.GetTask(ctx, "3")
+	assert.NoError(t, err, "Идентификаторы задач должны сохраниться")
+	assert.Equal(t, "Третья", task.Title)
+	assert.Equal(t, []string{"копия"}, task.Tags, "Метки задач тоже копируются")
+	assert.Equal(t, "1", task.ListID, "Списки задач тоже копируются")
+
+	id, err := store.AddTask(ctx, &db.Task{Date: "20260105", Title: "Новая"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), id, "Новые задачи получают идентификаторы после скопированных")
+
+	entries, err := store.Audit(ctx, db.AuditFilter{})
+	assert.NoError(t, err)
+	// The source has the additions and the deletion of its tasks logged before the entry added by hand.
+	if assert.Len(t, entries, 6, "Журнал изменений тоже копируется") {
+		assert.Equal(t, db.AuditAdd, entries[0].Operation, "Добавление задачи после копирования")
+		assert.Equal(t, *logged, *entries[1])
+		assert.Equal(t, "6", entries[0].ID, "Новые записи журнала получают идентификаторы после скопированных")
+	}
+
+	_, err = db.CopyTasks(ctx, source, store)
+	assert.Error(t, err, "Копировать можно только в пустую базу данных")
+}
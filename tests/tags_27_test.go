@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func getTags(t *testing.T) []tagCount {
+	body, err := requestJSON("api/tags", nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m struct {
+		Tags []tagCount `json:"tags"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m.Tags
+}
+
+// getTaggedTasks returns the tasks selected by the query along with their tags, which getTasksPage can't decode.
+func getTaggedTasks(t *testing.T, query url.Values) []map[string]any {
+	body, err := requestJSON("api/tasks?"+query.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m struct {
+		Tasks []map[string]any `json:"tasks"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m.Tasks
+}
+
+func TestTags(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	for _, table := range []string{"scheduler", "task_tags", "tags"} {
+		_, err := db.Exec("DELETE FROM " + table)
+		assert.NoError(t, err)
+	}
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	rent := addTaskValues(t, map[string]any{"date": date, "title": "Оплатить аренду",
+		"tags": []string{" Дом ", "счета", "дом"}})
+	report := addTaskValues(t, map[string]any{"date": date, "title": "Отчёт", "tags": []string{"работа"}})
+	addTask(t, task{date: date, title: "Без меток"})
+
+	assert.Equal(t, []any{"дом", "счета"}, getTask(t, rent)["tags"], "Метки приводятся к нижнему регистру без повторов")
+	assert.Equal(t, []tagCount{{"дом", 1}, {"работа", 1}, {"счета", 1}}, getTags(t))
+
+	tasks := getTaggedTasks(t, url.Values{"tag": {"ДОМ"}})
+	if assert.Len(t, tasks, 1, "Отбор задач по метке") {
+		assert.Equal(t, rent, tasks[0]["id"])
+	}
+	assert.Empty(t, getTaggedTasks(t, url.Values{"tag": {"дом", "работа"}}), "Задача должна иметь все метки")
+	assert.Len(t, getTaggedTasks(t, url.Values{"search": {"tag:работа"}}), 1)
+
+	ret, err := postJSON("api/task", map[string]any{"id": report, "date": date, "title": "Отчёт",
+		"tags": []string{"работа", "срочно"}}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []any{"работа", "срочно"}, getTask(t, report)["tags"])
+	ret, err = postJSON("api/task", map[string]any{"id": report, "date": date, "title": "Квартальный отчёт"},
+		http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []any{"работа", "срочно"}, getTask(t, report)["tags"], "Изменение без меток сохраняет их")
+
+	for _, tags := range [][]string{{""}, {"два слова"}, {"a,b"}, {"очень-длинная-метка-больше-32-символов"}} {
+		ret, err := postJSON("api/task", map[string]any{"date": date, "title": "Метки", "tags": tags}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для меток %v", tags)
+	}
+
+	ret, err = postJSON("api/tags/rename", map[string]any{"from": "срочно", "to": "Важно"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []any{"важно", "работа"}, getTask(t, report)["tags"], "Метка переименована")
+	ret, err = postJSON("api/tags/rename", map[string]any{"from": "важно", "to": "дом"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Нельзя переименовать метку в существующую")
+	ret, err = postJSON("api/tags/rename", map[string]any{"from": "отпуск", "to": "отдых"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Метки не существует")
+
+	ret, err = postJSON("api/tags/merge", map[string]any{"from": []string{"дом", "счета", "важно"}, "to": "личное"},
+		http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []any{"личное"}, getTask(t, rent)["tags"])
+	assert.Equal(t, []tagCount{{"личное", 2}, {"работа", 1}}, getTags(t), "Объединённые метки удаляются")
+	ret, err = postJSON("api/tags/merge", map[string]any{"from": []string{}, "to": "личное"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Нечего объединять")
+
+	ret, err = postJSON("api/task?id="+report, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []tagCount{{"личное", 1}}, getTags(t), "Задачи в корзине не учитываются")
+}
+
+func TestUndoAfterTagRename(t *testing.T) {
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	id := addTaskValues(t, map[string]any{"date": date, "title": "Тренировка", "tags": []string{"спортзал", "вечер"}})
+	ret, err := postJSON("api/task", map[string]any{"id": id, "date": date, "title": "Силовая тренировка"},
+		http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	ret, err = postJSON("api/tags/rename", map[string]any{"from": "спортзал", "to": "фитнес"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/tags/merge", map[string]any{"from": []string{"вечер"}, "to": "фитнес"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	assert.NotEmpty(t, undo(t, "")["change"])
+	task := getTask(t, id)
+	assert.Equal(t, "Тренировка", task["title"])
+	assert.Equal(t, []any{"фитнес"}, task["tags"], "Отмена изменения возвращает метки с новыми названиями")
+}
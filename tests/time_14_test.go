@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateTime(t *testing.T) {
+	tbl := []struct {
+		now, date, time, repeat, want string
+	}{
+		{"20240126 10:15", "20240126", "09:30", "h 0", ""},
+		{"20240126 10:15", "20240126", "09:30", "h 25", ""},
+		{"20240126 10:15", "20240126", "09:30", "min 1441", ""},
+		{"20240126 10:15", "20240126", "09:30", "h 2 18:00-09:00", ""},
+		{"20240126 10:15", "20240126", "09:30", "h 2", "20240126 11:30"},
+		{"20240126 10:15", "20240126", "", "h 4", "20240126 12:00"},
+		{"20240126 10:15", "20240126", "09:30", "min 45", "20240126 11:00"},
+		{"20240126 10:15", "20240127", "09:30", "h 1", "20240127 10:30"},
+		{"20240126 23:30", "20240126", "22:00", "h 1", "20240127 00:00"},
+		{"20240126 10:15", "20240126", "09:00", "h 3 09:00-18:00", "20240126 12:00"},
+		{"20240126 16:00", "20240126", "09:00", "h 3 09:00-18:00", "20240126 18:00"},
+		{"20240126 18:00", "20240126", "09:00", "h 3 09:00-18:00", "20240127 09:00"},
+		{"20240126 07:00", "20240126", "07:30", "min 30 08:00-09:00", "20240126 08:00"},
+		{"20240126 07:00", "20240126", "09:00", "min 30 08:00-09:00", "20240127 08:00"},
+		{"20240126 10:15", "20240126", "09:30", "d 1", "20240127 09:30"},
+		{"20240126", "20240120", "", "d 7", "20240127"},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=%s&date=%s&time=%s&repeat=%s",
+			url.QueryEscape(v.now), v.date, url.QueryEscape(v.time), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		if len(v.want) == 0 {
+			_, errDate := time.Parse("20060102", next)
+			_, errTime := time.Parse("20060102 15:04", next)
+			assert.False(t, errDate == nil || errTime == nil,
+				"Ожидается ошибка для правила %q, получено %q", v.repeat, next)
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q, %q}`, v.now, v.date, v.time, v.repeat)
+	}
+}
+
+func TestTaskTime(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+
+	m, err := postJSON("api/task", map[string]any{
+		"date":  tomorrow,
+		"title": "Тест",
+		"time":  "25:00",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m["error"], "Ожидается ошибка для неверного времени")
+
+	m, err = postJSON("api/task", map[string]any{
+		"date":       tomorrow,
+		"title":      "Тест",
+		"repeat":     "h 2",
+		"exceptions": []string{tomorrow},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m["error"], "Ожидается ошибка для исключений с правилом по часам")
+
+	id := addTaskValues(t, map[string]any{
+		"date":   tomorrow,
+		"time":   "9:30",
+		"title":  "Созвон",
+		"repeat": "d 1",
+	})
+	body, err := postJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "09:30", body["time"])
+
+	id = addTaskValues(t, map[string]any{
+		"date":      tomorrow,
+		"time":      "09:00",
+		"title":     "Выпить воды",
+		"repeat":    "h 3 09:00-15:00",
+		"remaining": 4,
+	})
+
+	var task Task
+	for _, want := range []string{"12:00", "15:00"} {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, tomorrow, task.Date)
+		assert.Equal(t, want, task.Time)
+	}
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Now().AddDate(0, 0, 2).Format(`20060102`), task.Date)
+	assert.Equal(t, "09:00", task.Time)
+	assert.Equal(t, 1, task.Remaining)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+}
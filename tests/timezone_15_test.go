@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateTimezone(t *testing.T) {
+	tbl := []struct {
+		tz, now, date, time, repeat, want string
+	}{
+		// В Нью-Йорке 10 марта 2024 года часы переводятся с 02:00 на 03:00.
+		{"America/New_York", "20240310 01:45", "20240310", "01:30", "h 1", "20240310 03:30"},
+		{"America/New_York", "20240310 01:40", "20240310", "00:00", "min 90", "20240310 04:00"},
+		{"America/New_York", "20240310 01:00", "20240310", "00:00", "h 3 00:00-23:59", "20240310 03:00"},
+		{"America/New_York", "20240310 23:30", "20240309", "", "d 1", "20240311"},
+		// 3 ноября 2024 года часы переводятся с 02:00 обратно на 01:00.
+		{"America/New_York", "20241103 00:45", "20241103", "00:30", "h 1", "20241103 01:30"},
+		{"America/New_York", "20241103 01:40", "20241103", "01:30", "h 1", "20241103 02:30"},
+		{"Europe/Berlin", "20241027 01:30", "20241027", "01:00", "h 2", "20241027 02:00"},
+		{"UTC", "20240310 01:45", "20240310", "01:30", "h 1", "20240310 02:30"},
+		{"Mars/Olympus", "20240310 01:45", "20240310", "01:30", "h 1", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?tz=%s&now=%s&date=%s&time=%s&repeat=%s", url.QueryEscape(v.tz),
+			url.QueryEscape(v.now), v.date, url.QueryEscape(v.time), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		if len(v.want) == 0 {
+			assert.Contains(t, next, "timezone", "Ожидается ошибка для часового пояса %q", v.tz)
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q, %q, %q}`, v.tz, v.now, v.date, v.time, v.repeat)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, getURL("api/nextdate?now="+url.QueryEscape("20240310 01:45")+
+		"&date=20240310&time=01:30&repeat=h+1"), nil)
+	assert.NoError(t, err)
+	req.Header.Set("X-Timezone", "America/New_York")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "20240310 03:30", strings.TrimSpace(string(body)))
+}
+
+func TestTaskTimezone(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	east, err := time.LoadLocation("Pacific/Kiritimati")
+	assert.NoError(t, err)
+	west, err := time.LoadLocation("Pacific/Pago_Pago")
+	assert.NoError(t, err)
+
+	now := time.Now()
+	eastToday, westToday := now.In(east).Format(`20060102`), now.In(west).Format(`20060102`)
+
+	// Между часовыми поясами 25 часов, поэтому даты в них всегда различаются.
+	for _, v := range []struct {
+		tz, want string
+	}{
+		{"Pacific/Kiritimati", eastToday},
+		{"Pacific/Pago_Pago", westToday},
+	} {
+		ret, err := postJSON("api/task?tz="+url.QueryEscape(v.tz), map[string]any{
+			"date":  westToday,
+			"title": "Часовой пояс",
+		}, http.MethodPost)
+		assert.NoError(t, err)
+		id := fmt.Sprint(ret["id"])
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, v.want, task.Date, "Неверная дата задачи для часового пояса %q", v.tz)
+	}
+
+	m, err := postJSON("api/task?tz=Mars/Olympus", map[string]any{
+		"date":  westToday,
+		"title": "Часовой пояс",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m["error"], "Ожидается ошибка для неизвестного часового пояса")
+}
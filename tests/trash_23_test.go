@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTrash(t *testing.T) []map[string]string {
+	body, err := requestJSON("api/trash", nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m struct {
+		Tasks []map[string]string `json:"tasks"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m.Tasks
+}
+
+func TestTrash(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	id := addTask(t, task{date: date, title: "Удалить по ошибке"})
+	done := addTask(t, task{date: date, title: "Сделать и забыть"})
+
+	ret, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/task/done?id="+done, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	notFoundTask(t, id)
+	assert.Empty(t, getTasks(t, ""), "Задачи из корзины не должны попадать в список")
+	var count int
+	assert.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM scheduler WHERE deleted <> ''`))
+	assert.Equal(t, 2, count, "Задачи должны остаться в базе данных")
+
+	trash := getTrash(t)
+	if assert.Len(t, trash, 2, "Удалённая и выполненная задачи должны быть в корзине") {
+		assert.Equal(t, "Сделать и забыть", trash[0]["title"], "Последние удалённые задачи идут первыми")
+		assert.NotEmpty(t, trash[0]["deleted"])
+	}
+
+	ret, err = postJSON("api/task/restore?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var task map[string]any
+	assert.NoError(t, json.Unmarshal(body, &task))
+	assert.Equal(t, "Удалить по ошибке", task["title"], "Восстановленная задача снова доступна")
+	assert.Len(t, getTrash(t), 1)
+
+	ret, err = postJSON("api/task/restore?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Восстановить можно только задачу из корзины")
+	ret, err = postJSON("api/trash?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "Удалить навсегда можно только задачу из корзины")
+
+	ret, err = postJSON("api/trash?id="+done, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, ret["purged"])
+	assert.Empty(t, getTrash(t))
+
+	ret, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/trash", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, ret["purged"], "Очистка всей корзины")
+	assert.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM scheduler`))
+	assert.Zero(t, count)
+}
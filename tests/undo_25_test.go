@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTask(t *testing.T, id string) map[string]any {
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var task map[string]any
+	assert.NoError(t, json.Unmarshal(body, &task))
+	return task
+}
+
+func undo(t *testing.T, id string) map[string]any {
+	path := "api/undo"
+	if id != "" {
+		path += "?id=" + id
+	}
+	ret, err := postJSON(path, nil, http.MethodPost)
+	assert.NoError(t, err)
+	return ret
+}
+
+func TestUndo(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	for _, table := range []string{"scheduler", "completions", "changes"} {
+		_, err := db.Exec("DELETE FROM " + table)
+		assert.NoError(t, err)
+	}
+
+	today := time.Now().Format(`20060102`)
+	once := addTask(t, task{date: today, title: "Разовая"})
+	ret, err := postJSON("api/task/done?id="+once, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, once)
+
+	ret = undo(t, "")
+	if change, ok := ret["change"].(map[string]any); assert.True(t, ok, "Ожидается отменённое изменение") {
+		assert.Equal(t, "done", change["operation"])
+		assert.Equal(t, once, change["task_id"])
+	}
+	assert.Equal(t, "Разовая", getTask(t, once)["title"], "Выполненная задача должна вернуться")
+	assert.Empty(t, getHistory(t, nil), "Отменённое выполнение удаляется из истории")
+
+	every := addTask(t, task{date: today, title: "Через день", repeat: "d 2"})
+	ret, err = postJSON("api/task/done?id="+every, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	moved := getTask(t, every)["date"]
+	assert.NotEqual(t, today, moved)
+	ret, err = postJSON("api/task", map[string]any{"id": every, "date": moved, "title": "Переименованная",
+		"repeat": "d 2"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	body, err := requestJSON("api/undo", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m struct {
+		Changes []struct {
+			ID        string         `json:"id"`
+			Operation string         `json:"operation"`
+			Previous  map[string]any `json:"previous"`
+		} `json:"changes"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m))
+	if !assert.Len(t, m.Changes, 2, "Отменённое изменение больше не показывается") {
+		return
+	}
+	assert.Equal(t, "update", m.Changes[0].Operation, "Последние изменения идут первыми")
+	assert.Equal(t, "Через день", m.Changes[0].Previous["title"])
+	done := m.Changes[1].ID
+
+	ret = undo(t, done)
+	assert.NotEmpty(t, ret["error"], "Сначала нужно отменить более позднее изменение задачи")
+	assert.Equal(t, moved, getTask(t, every)["date"])
+	undo(t, m.Changes[0].ID)
+	assert.Equal(t, "Через день", getTask(t, every)["title"], "Изменение задачи должно отмениться")
+	undo(t, done)
+	assert.Equal(t, today, getTask(t, every)["date"], "Выполнение повторяющейся задачи должно отмениться")
+
+	ret, err = postJSON("api/task?id="+once, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	undo(t, "")
+	assert.Equal(t, "Разовая", getTask(t, once)["title"], "Удалённая задача должна вернуться")
+	assert.Empty(t, getTrash(t))
+
+	ret = undo(t, "")
+	assert.NotEmpty(t, ret["error"], "Все изменения уже отменены")
+	ret = undo(t, done)
+	assert.NotEmpty(t, ret["error"], "Изменение нельзя отменить дважды")
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateBusinessDays(t *testing.T) {
+	checkNextDates(t, "20240126", []nextDate{
+		{"20240101", "b", ""},
+		{"20240101", "b 0", ""},
+		{"20240101", "b 401", ""},
+		{"20240101", "b first", ""},
+		{"20240126", "b 1", "20240129"},
+		{"20240101", "b 5", "20240129"},
+		{"20240125", "b 3", "20240130"},
+		{"20240101", "b last", "20240131"},
+	})
+	checkNextDates(t, "20240301", []nextDate{
+		{"20240101", "b last", "20240329"},
+		{"20240601", "b last", "20240628"},
+	})
+}
+
+func TestWorkdayTask(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	m, err := postJSON("api/task", map[string]any{
+		"date":    "20301005",
+		"title":   "Тест",
+		"workday": true,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m["error"], "Ожидается ошибка для задачи без правила повторения")
+
+	// 5 октября 2030 года — суббота, ближайший рабочий день — пятница.
+	id := addTaskValues(t, map[string]any{
+		"date":    "20301005",
+		"title":   "Отчёт",
+		"repeat":  "d 7",
+		"workday": true,
+	})
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "20301004", task.Date)
+	assert.True(t, task.Workday)
+	assert.Equal(t, -1, task.Shift)
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "20301011", task.Date)
+	assert.Equal(t, -1, task.Shift)
+
+	// 6 октября 2030 года — воскресенье, ближайший рабочий день — понедельник.
+	id = addTaskValues(t, map[string]any{
+		"date":    "20301006",
+		"title":   "Планёрка",
+		"repeat":  "d 1",
+		"workday": true,
+	})
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "20301007", task.Date)
+	assert.Equal(t, 1, task.Shift)
+}